@@ -0,0 +1,89 @@
+// Package cache holds the rendered responses of the peer, the caches of all the peers are kept in sync via the broker
+package cache
+
+import (
+	"fmt"
+	lru "github.com/hashicorp/golang-lru"
+	"time"
+)
+
+// Cache is safe for concurrent use, keys & values are whatever the handlers put in
+type Cache interface {
+	Get(key interface{}) (value interface{}, ok bool)
+	// Contains doesn't count as the use of the entry, unlike Get
+	Contains(key interface{}) bool
+	Add(key, value interface{})
+	Remove(key interface{})
+	Purge()
+	// Len is the number of entries, including the expired ones which haven't been looked up since
+	Len() int
+	// Keys include the expired entries as well
+	Keys() []interface{}
+}
+
+type Strategy string
+
+const (
+	// 2Q keeps the frequently used entries apart from the recently used ones
+	Strategy2Q Strategy = "2q"
+	// ARC adapts the balance between the frequently and the recently used entries to the load
+	StrategyARC Strategy = "arc"
+	// LRU evicts the least recently used entries, usually along with TTL
+	StrategyLRU Strategy = "lru"
+	// nothing is cached at all
+	StrategyNone Strategy = "none"
+)
+
+type Config struct {
+	// 2Q by default
+	Strategy Strategy
+	// maximum number of entries
+	Size int
+	// entries expire after TTL, they never do when it's zero
+	TTL time.Duration
+}
+
+func New(config Config) (Cache, error) {
+	var cache Cache
+	var err error
+
+	switch config.Strategy {
+	case "", Strategy2Q:
+		cache, err = lru.New2Q(config.Size)
+	case StrategyARC:
+		cache, err = lru.NewARC(config.Size)
+	case StrategyLRU:
+		var plain *lru.Cache
+		plain, err = lru.New(config.Size)
+		cache = lruCache{plain}
+	case StrategyNone:
+		return NewNoop(), nil
+	default:
+		return nil, fmt.Errorf("cache strategy %v doesn't exist", config.Strategy)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if config.TTL < 0 {
+		return nil, fmt.Errorf("cache TTL must be non-negative, got %v", config.TTL)
+	}
+	if config.TTL > 0 {
+		cache = NewExpiring(cache, config.TTL)
+	}
+
+	return cache, nil
+}
+
+// lruCache drops the results which tell whether an entry has been evicted or removed
+type lruCache struct {
+	*lru.Cache
+}
+
+func (c lruCache) Add(key, value interface{}) {
+	c.Cache.Add(key, value)
+}
+
+func (c lruCache) Remove(key interface{}) {
+	c.Cache.Remove(key)
+}
@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"fmt"
+	lru "github.com/hashicorp/golang-lru"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		wantType string
+		wantErr  bool
+	}{
+		{"2q by default", Config{Size: 2}, fmt.Sprintf("%T", &lru.TwoQueueCache{}), false},
+		{"2q", Config{Strategy: Strategy2Q, Size: 2}, fmt.Sprintf("%T", &lru.TwoQueueCache{}), false},
+		{"arc", Config{Strategy: StrategyARC, Size: 2}, fmt.Sprintf("%T", &lru.ARCCache{}), false},
+		{"lru", Config{Strategy: StrategyLRU, Size: 2}, fmt.Sprintf("%T", lruCache{}), false},
+		{"lru with ttl", Config{Strategy: StrategyLRU, Size: 2, TTL: time.Minute}, fmt.Sprintf("%T", &Expiring{}), false},
+		{"none", Config{Strategy: StrategyNone}, fmt.Sprintf("%T", Noop{}), false},
+		{"unknown strategy", Config{Strategy: "fifo", Size: 2}, "", true},
+		{"zero size", Config{Strategy: StrategyLRU}, "", true},
+		{"negative ttl", Config{Strategy: StrategyLRU, Size: 2, TTL: -time.Minute}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, err := New(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := fmt.Sprintf("%T", cache); got != tt.wantType {
+				t.Errorf("got %v, want %v", got, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestNewBoundsSize(t *testing.T) {
+	for _, strategy := range []Strategy{Strategy2Q, StrategyARC, StrategyLRU} {
+		t.Run(string(strategy), func(t *testing.T) {
+			cache, err := New(Config{Strategy: strategy, Size: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 5; i++ {
+				cache.Add(i, i)
+			}
+			if cache.Len() != 2 {
+				t.Errorf("got %v entries, want 2", cache.Len())
+			}
+			if value, ok := cache.Get(4); !ok || value != 4 {
+				t.Errorf("got %v, %v for the latest entry, want 4, true", value, ok)
+			}
+		})
+	}
+}
+
+func TestExpiring(t *testing.T) {
+	tests := []struct {
+		name string
+		// how long after the entry is added it's looked up
+		after     time.Duration
+		wantValue interface{}
+		wantOK    bool
+		// whether the entry is still in the underlying cache after the lookup
+		wantKept bool
+	}{
+		{"fresh", 0, "value", true, true},
+		{"just before ttl", time.Minute, "value", true, true},
+		{"expired", time.Minute + time.Nanosecond, nil, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, err := New(Config{Strategy: StrategyLRU, Size: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			now := time.Now()
+			cache := NewExpiring(next, time.Minute)
+			cache.now = func() time.Time { return now }
+
+			cache.Add("key", "value")
+			// expired entries are reported until they're looked up
+			now = now.Add(tt.after)
+			if !cache.Contains("key") || cache.Len() != 1 {
+				t.Fatalf("got contains %v, len %v before the lookup, want true, 1", cache.Contains("key"), cache.Len())
+			}
+
+			value, ok := cache.Get("key")
+			if value != tt.wantValue || ok != tt.wantOK {
+				t.Errorf("got %v, %v, want %v, %v", value, ok, tt.wantValue, tt.wantOK)
+			}
+			if kept := next.Contains("key"); kept != tt.wantKept {
+				t.Errorf("got entry kept %v, want %v", kept, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestExpiringDropsForeignEntries(t *testing.T) {
+	next, err := New(Config{Strategy: StrategyLRU, Size: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := NewExpiring(next, time.Minute)
+
+	// e.g. left by the cache before it was wrapped
+	next.Add("key", "value")
+	if value, ok := cache.Get("key"); ok {
+		t.Errorf("got %v, want a miss", value)
+	}
+	if next.Contains("key") {
+		t.Error("got the foreign entry kept, want it removed")
+	}
+}
+
+func TestNoop(t *testing.T) {
+	cache := NewNoop()
+	cache.Add("key", "value")
+
+	if value, ok := cache.Get("key"); ok {
+		t.Errorf("got %v, want a miss", value)
+	}
+	if cache.Contains("key") {
+		t.Error("got contains, want nothing kept")
+	}
+	if cache.Len() != 0 || len(cache.Keys()) != 0 {
+		t.Errorf("got len %v, keys %v, want nothing kept", cache.Len(), cache.Keys())
+	}
+
+	// nothing to remove or purge, but they're safe to call
+	cache.Remove("key")
+	cache.Purge()
+}
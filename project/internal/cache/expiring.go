@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"time"
+)
+
+type expiringEntry struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+// Expiring drops the entries of the underlying cache once they are older than TTL,
+// the expired entries are removed when they're looked up or evicted by the underlying cache
+type Expiring struct {
+	next Cache
+	ttl  time.Duration
+	now  func() time.Time
+}
+
+func NewExpiring(next Cache, ttl time.Duration) *Expiring {
+	return &Expiring{
+		next: next,
+		ttl:  ttl,
+		now:  time.Now,
+	}
+}
+
+func (c *Expiring) Get(key interface{}) (interface{}, bool) {
+	value, ok := c.next.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	entry, ok := value.(expiringEntry)
+	if !ok || c.now().After(entry.expiresAt) {
+		c.next.Remove(key)
+		return nil, false
+	}
+
+	return entry.value, true
+}
+
+// Contains reports the expired entries as well, they're only dropped on lookup
+func (c *Expiring) Contains(key interface{}) bool {
+	return c.next.Contains(key)
+}
+
+func (c *Expiring) Add(key, value interface{}) {
+	c.next.Add(key, expiringEntry{value: value, expiresAt: c.now().Add(c.ttl)})
+}
+
+func (c *Expiring) Remove(key interface{}) {
+	c.next.Remove(key)
+}
+
+func (c *Expiring) Purge() {
+	c.next.Purge()
+}
+
+func (c *Expiring) Len() int {
+	return c.next.Len()
+}
+
+func (c *Expiring) Keys() []interface{} {
+	return c.next.Keys()
+}
@@ -0,0 +1,30 @@
+package cache
+
+// Noop doesn't keep anything, every lookup is a miss
+type Noop struct{}
+
+func NewNoop() Noop {
+	return Noop{}
+}
+
+func (Noop) Get(key interface{}) (interface{}, bool) {
+	return nil, false
+}
+
+func (Noop) Contains(key interface{}) bool {
+	return false
+}
+
+func (Noop) Add(key, value interface{}) {}
+
+func (Noop) Remove(key interface{}) {}
+
+func (Noop) Purge() {}
+
+func (Noop) Len() int {
+	return 0
+}
+
+func (Noop) Keys() []interface{} {
+	return nil
+}
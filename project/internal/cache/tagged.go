@@ -0,0 +1,394 @@
+package cache
+
+import (
+	"context"
+	"golang.org/x/sync/singleflight"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// the index is pruned of the keys evicted by the underlying cache once it has this many more keys than the cache
+	taggedIndexSlack = 64
+	// loads are shared by all the callers waiting for them, so they don't end with the request of the first one
+	loadTimeout = 10 * time.Second
+)
+
+// Lookup tells where the value returned by GetOrLoad comes from
+type Lookup int
+
+const (
+	// the value has been loaded
+	LookupMiss Lookup = iota
+	LookupHit
+	// the value was invalidated recently, it's being refreshed in the background
+	LookupStale
+)
+
+func (l Lookup) String() string {
+	switch l {
+	case LookupHit:
+		return "hit"
+	case LookupStale:
+		return "stale"
+	default:
+		return "miss"
+	}
+}
+
+// Loader builds the value to be cached along with its tags. The values without tags aren't cached,
+// as there would be no way to invalidate them, but they're still returned to everyone waiting for the load
+type Loader func(ctx context.Context) (value interface{}, tags []string, err error)
+
+type staleEntry struct {
+	value interface{}
+	until time.Time
+}
+
+// Stats tell how the cache has been doing since the peer has started
+type Stats struct {
+	// results of GetOrLoad
+	Hits   uint64
+	Misses uint64
+	Stale  uint64
+	// LastPurge is zero if the cache hasn't been purged yet
+	LastPurge time.Time
+	// peer which has requested the last purge, empty if it's unknown
+	LastPurgeOrigin string
+}
+
+type TaggedOption func(c *Tagged)
+
+// WithStaleGrace keeps serving the invalidated entries for the grace period while they're loaded again
+// (stale-while-revalidate), so that the writes don't make all the readers wait for the store at once
+func WithStaleGrace(grace time.Duration) TaggedOption {
+	return func(c *Tagged) {
+		c.staleGrace = grace
+	}
+}
+
+// TaggedCache is what the handlers & the brokers depend on, so that a fake could be given to them instead of *Tagged
+type TaggedCache interface {
+	Cache
+	// GetOrLoad returns the cached value or the one built by load, concurrent misses of the key share a single load
+	GetOrLoad(ctx context.Context, key string, load Loader) (interface{}, Lookup, error)
+	// Load builds the value even if it's cached, concurrent loads of the key are still shared
+	Load(ctx context.Context, key string, load Loader) (interface{}, error)
+	AddWithTags(key, value interface{}, tags ...string)
+	// InvalidateTags removes the entries with any of the tags, returns how many of them have been removed
+	InvalidateTags(tags ...string) int
+	// PurgeFrom purges the cache on request of the origin peer
+	PurgeFrom(origin string)
+	// OnPurge registers the hook called after every purge
+	OnPurge(hook func())
+	Stats() Stats
+}
+
+var _ TaggedCache = (*Tagged)(nil)
+
+// Tagged indexes the entries of the underlying cache by tags (e.g. song:42 or list:songs),
+// so that only the entries depending on the changed data are invalidated
+type Tagged struct {
+	// counters of the lookups, updated atomically (first in the struct to be 64-bit aligned)
+	hits      uint64
+	misses    uint64
+	staleHits uint64
+
+	next       Cache
+	staleGrace time.Duration
+	now        func() time.Time
+	// concurrent misses of the same key share a single load
+	loads singleflight.Group
+
+	mu        sync.Mutex
+	keysByTag map[string]map[interface{}]struct{}
+	tagsByKey map[interface{}][]string
+	stale     map[interface{}]staleEntry
+	// incremented on every invalidation, the loads started before one aren't cached as they might be stale
+	generation uint64
+	// called after every purge
+	purgeHooks      []func()
+	lastPurge       time.Time
+	lastPurgeOrigin string
+}
+
+func NewTagged(next Cache, opts ...TaggedOption) *Tagged {
+	c := &Tagged{
+		next:      next,
+		now:       time.Now,
+		keysByTag: make(map[string]map[interface{}]struct{}),
+		tagsByKey: make(map[interface{}][]string),
+		stale:     make(map[interface{}]staleEntry),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+func (c *Tagged) Get(key interface{}) (interface{}, bool) {
+	return c.next.Get(key)
+}
+
+func (c *Tagged) Contains(key interface{}) bool {
+	return c.next.Contains(key)
+}
+
+// GetOrLoad returns the cached value or the one built by load. Waiting for the load ends with the context,
+// but the load itself goes on for the others waiting for it
+func (c *Tagged) GetOrLoad(ctx context.Context, key string, load Loader) (interface{}, Lookup, error) {
+	if value, ok := c.next.Get(key); ok {
+		atomic.AddUint64(&c.hits, 1)
+		return value, LookupHit, nil
+	}
+
+	if value, ok := c.staleValue(key); ok {
+		atomic.AddUint64(&c.staleHits, 1)
+		// whoever comes while it's being refreshed gets the stale value as well
+		c.loads.DoChan(key, c.loadFunc(ctx, key, load))
+		return value, LookupStale, nil
+	}
+
+	atomic.AddUint64(&c.misses, 1)
+	value, err := c.Load(ctx, key, load)
+	return value, LookupMiss, err
+}
+
+// Load builds the value with load even if it's cached, e.g. when the cached one is too old for the caller;
+// concurrent loads of the same key are still shared
+func (c *Tagged) Load(ctx context.Context, key string, load Loader) (interface{}, error) {
+	select {
+	case result := <-c.loads.DoChan(key, c.loadFunc(ctx, key, load)):
+		return result.Val, result.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *Tagged) loadFunc(ctx context.Context, key string, load Loader) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(Detach(ctx), loadTimeout)
+		defer cancel()
+
+		c.mu.Lock()
+		generation := c.generation
+		c.mu.Unlock()
+
+		value, tags, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.generation == generation && len(tags) > 0 {
+			c.add(key, value, tags)
+		}
+
+		return value, nil
+	}
+}
+
+func (c *Tagged) staleValue(key interface{}) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.stale[key]
+	if !ok || c.now().After(entry.until) {
+		return nil, false
+	}
+
+	return entry.value, true
+}
+
+// Add caches the entry without tags, it's only invalidated by the key or by purge
+func (c *Tagged) Add(key, value interface{}) {
+	c.AddWithTags(key, value)
+}
+
+func (c *Tagged) AddWithTags(key, value interface{}, tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.add(key, value, tags)
+}
+
+func (c *Tagged) add(key, value interface{}, tags []string) {
+	c.next.Add(key, value)
+	c.untrack(key)
+	c.track(key, tags)
+	delete(c.stale, key)
+
+	if len(c.tagsByKey) > c.next.Len()+taggedIndexSlack {
+		c.prune()
+	}
+}
+
+func (c *Tagged) Remove(key interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.dropExpiredStale()
+	c.invalidate(key)
+}
+
+// InvalidateTags removes the entries with any of the tags, returns how many of them have been removed
+func (c *Tagged) InvalidateTags(tags ...string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.dropExpiredStale()
+	removed := 0
+	for _, tag := range tags {
+		for key := range c.keysByTag[tag] {
+			c.invalidate(key)
+			removed++
+		}
+	}
+
+	return removed
+}
+
+// OnPurge registers the hook called after every purge, e.g. to warm the cache up again
+func (c *Tagged) OnPurge(hook func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.purgeHooks = append(c.purgeHooks, hook)
+}
+
+func (c *Tagged) Purge() {
+	c.PurgeFrom("")
+}
+
+// PurgeFrom purges the cache on request of the origin peer, it's reported by Stats
+func (c *Tagged) PurgeFrom(origin string) {
+	c.mu.Lock()
+	c.lastPurge, c.lastPurgeOrigin = c.now(), origin
+	c.generation++
+	c.dropExpiredStale()
+	if c.staleGrace > 0 {
+		for key := range c.tagsByKey {
+			c.keepStale(key)
+		}
+	}
+	c.next.Purge()
+	c.keysByTag = make(map[string]map[interface{}]struct{})
+	c.tagsByKey = make(map[interface{}][]string)
+	hooks := c.purgeHooks
+	c.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+func (c *Tagged) Len() int {
+	return c.next.Len()
+}
+
+func (c *Tagged) Keys() []interface{} {
+	return c.next.Keys()
+}
+
+func (c *Tagged) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return Stats{
+		Hits:            atomic.LoadUint64(&c.hits),
+		Misses:          atomic.LoadUint64(&c.misses),
+		Stale:           atomic.LoadUint64(&c.staleHits),
+		LastPurge:       c.lastPurge,
+		LastPurgeOrigin: c.lastPurgeOrigin,
+	}
+}
+
+func (c *Tagged) invalidate(key interface{}) {
+	if c.staleGrace > 0 {
+		c.keepStale(key)
+	}
+	c.untrack(key)
+	c.next.Remove(key)
+}
+
+// keepStale keeps the entry being invalidated for the grace period
+func (c *Tagged) keepStale(key interface{}) {
+	value, ok := c.next.Get(key)
+	if !ok {
+		return
+	}
+
+	c.stale[key] = staleEntry{value: value, until: c.now().Add(c.staleGrace)}
+}
+
+func (c *Tagged) dropExpiredStale() {
+	now := c.now()
+	for key, entry := range c.stale {
+		if now.After(entry.until) {
+			delete(c.stale, key)
+		}
+	}
+}
+
+func (c *Tagged) track(key interface{}, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+
+	c.tagsByKey[key] = tags
+	for _, tag := range tags {
+		keys, ok := c.keysByTag[tag]
+		if !ok {
+			keys = make(map[interface{}]struct{})
+			c.keysByTag[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+func (c *Tagged) untrack(key interface{}) {
+	for _, tag := range c.tagsByKey[key] {
+		delete(c.keysByTag[tag], key)
+		if len(c.keysByTag[tag]) == 0 {
+			delete(c.keysByTag, tag)
+		}
+	}
+	delete(c.tagsByKey, key)
+}
+
+// prune drops the keys the underlying cache has evicted on its own
+func (c *Tagged) prune() {
+	for key := range c.tagsByKey {
+		if !c.next.Contains(key) {
+			c.untrack(key)
+		}
+	}
+}
+
+// detachedContext keeps the values of the parent (logger, span), but not its cancellation
+type detachedContext struct {
+	context.Context
+}
+
+// Detach is for the work shared by several requests, which mustn't end along with the first one of them
+func Detach(ctx context.Context) context.Context {
+	return detachedContext{ctx}
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
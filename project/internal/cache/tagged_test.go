@@ -0,0 +1,273 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestTagged(t *testing.T, opts ...TaggedOption) *Tagged {
+	t.Helper()
+
+	lru, err := New(Config{Strategy: StrategyLRU, Size: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewTagged(lru, opts...)
+}
+
+// loaded returns the loader of the value with the tags
+func loaded(value interface{}, tags ...string) Loader {
+	return func(ctx context.Context) (interface{}, []string, error) {
+		return value, tags, nil
+	}
+}
+
+func TestTaggedInvalidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		invalidate  func(c *Tagged) int
+		wantRemoved int
+		wantKept    []string
+	}{
+		{"single tag", func(c *Tagged) int { return c.InvalidateTags("song:1") }, 2, []string{"song 2", "artist 1", "plain"}},
+		{"shared tag", func(c *Tagged) int { return c.InvalidateTags("list:songs") }, 1, []string{"song 1", "song 2", "artist 1", "plain"}},
+		{"several tags", func(c *Tagged) int { return c.InvalidateTags("song:2", "artist:1") }, 3, []string{"songs", "plain"}},
+		{"unknown tag", func(c *Tagged) int { return c.InvalidateTags("song:404") }, 0, []string{"song 1", "song 2", "songs", "artist 1", "plain"}},
+		{"no tags", func(c *Tagged) int { return c.InvalidateTags() }, 0, []string{"song 1", "song 2", "songs", "artist 1", "plain"}},
+		{"by key", func(c *Tagged) int { c.Remove("songs"); return 1 }, 1, []string{"song 1", "song 2", "artist 1", "plain"}},
+		{"purge", func(c *Tagged) int { c.Purge(); return 5 }, 5, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestTagged(t)
+			c.AddWithTags("song 1", "Waterloo", "song:1", "artist:1")
+			c.AddWithTags("song 2", "Mamma Mia", "song:2", "artist:1")
+			c.AddWithTags("songs", "Waterloo, Mamma Mia", "list:songs", "song:1")
+			c.AddWithTags("artist 1", "ABBA", "artist:1")
+			// only invalidated by the key or by purge
+			c.Add("plain", "Hello world")
+
+			if removed := tt.invalidate(c); removed != tt.wantRemoved {
+				t.Errorf("got %v removed, want %v", removed, tt.wantRemoved)
+			}
+
+			for _, key := range []string{"song 1", "song 2", "songs", "artist 1", "plain"} {
+				want := false
+				for _, kept := range tt.wantKept {
+					want = want || kept == key
+				}
+				if got := c.Contains(key); got != want {
+					t.Errorf("%v: got cached %v, want %v", key, got, want)
+				}
+			}
+
+			// the entry cached again with other tags isn't invalidated by the old ones
+			c.AddWithTags("song 1", "Waterloo", "song:10")
+			c.InvalidateTags("song:1", "artist:1")
+			if !c.Contains("song 1") {
+				t.Error("got the entry invalidated by its former tags")
+			}
+		})
+	}
+}
+
+func TestTaggedLoad(t *testing.T) {
+	errLoad := errors.New("store is down")
+
+	tests := []struct {
+		name string
+		// called while the value is being loaded
+		meanwhile func(c *Tagged)
+		load      Loader
+		wantValue interface{}
+		wantErr   error
+		// whether the loaded value is cached
+		wantCached bool
+	}{
+		{"cached", func(c *Tagged) {}, loaded("Waterloo", "song:1"), "Waterloo", nil, true},
+		{"without tags", func(c *Tagged) {}, loaded("Waterloo"), "Waterloo", nil, false},
+		{"failed", func(c *Tagged) {}, func(ctx context.Context) (interface{}, []string, error) { return nil, nil, errLoad }, nil, errLoad, false},
+		// the value might have been read before the write, so it isn't cached;
+		// the tags of the write aren't compared, whatever invalidation is enough
+		{"invalidated during load", func(c *Tagged) { c.InvalidateTags("song:1") }, loaded("Waterloo", "song:1"), "Waterloo", nil, false},
+		{"other tag invalidated during load", func(c *Tagged) { c.InvalidateTags("song:2") }, loaded("Waterloo", "song:1"), "Waterloo", nil, false},
+		{"removed during load", func(c *Tagged) { c.Remove("song 1") }, loaded("Waterloo", "song:1"), "Waterloo", nil, false},
+		{"purged during load", func(c *Tagged) { c.Purge() }, loaded("Waterloo", "song:1"), "Waterloo", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestTagged(t)
+			load := func(ctx context.Context) (interface{}, []string, error) {
+				tt.meanwhile(c)
+				return tt.load(ctx)
+			}
+
+			value, lookup, err := c.GetOrLoad(context.Background(), "song 1", load)
+			if value != tt.wantValue || !errors.Is(err, tt.wantErr) || lookup != LookupMiss {
+				t.Errorf("got %v, %v, %v, want %v, %v, %v", value, lookup, err, tt.wantValue, LookupMiss, tt.wantErr)
+			}
+			if cached := c.Contains("song 1"); cached != tt.wantCached {
+				t.Errorf("got cached %v, want %v", cached, tt.wantCached)
+			}
+		})
+	}
+}
+
+func TestTaggedConcurrentLoads(t *testing.T) {
+	const callers = 10
+
+	tests := []struct {
+		name string
+		// the first caller goes away before the value is loaded
+		cancelFirst bool
+	}{
+		{"all waiting", false},
+		{"first caller gone", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestTagged(t)
+			var loads int32
+			started := make(chan struct{})
+			release := make(chan struct{})
+			load := func(ctx context.Context) (interface{}, []string, error) {
+				if atomic.AddInt32(&loads, 1) == 1 {
+					close(started)
+				}
+				<-release
+				// the load goes on for the others, whatever happens to the caller which has started it
+				if err := ctx.Err(); err != nil {
+					return nil, nil, err
+				}
+				return "Waterloo", []string{"song:1"}, nil
+			}
+
+			firstCtx, cancelFirst := context.WithCancel(context.Background())
+			defer cancelFirst()
+			firstErr := make(chan error, 1)
+			go func() {
+				_, _, err := c.GetOrLoad(firstCtx, "song 1", load)
+				firstErr <- err
+			}()
+			<-started
+
+			var wg sync.WaitGroup
+			values := make(chan interface{}, callers)
+			for i := 0; i < callers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					value, _, err := c.GetOrLoad(context.Background(), "song 1", load)
+					if err != nil {
+						t.Error(err)
+					}
+					values <- value
+				}()
+			}
+
+			if tt.cancelFirst {
+				cancelFirst()
+				if err := <-firstErr; !errors.Is(err, context.Canceled) {
+					t.Errorf("got error %v for the first caller, want %v", err, context.Canceled)
+				}
+			}
+			// giving the others a moment to join the load
+			time.Sleep(10 * time.Millisecond)
+			close(release)
+			wg.Wait()
+			close(values)
+
+			if loads := atomic.LoadInt32(&loads); loads != 1 {
+				t.Errorf("got %v loads, want 1", loads)
+			}
+			for value := range values {
+				if value != "Waterloo" {
+					t.Errorf("got %v, want Waterloo", value)
+				}
+			}
+			if !c.Contains("song 1") {
+				t.Error("got the loaded value not cached")
+			}
+		})
+	}
+}
+
+func TestTaggedStaleGrace(t *testing.T) {
+	tests := []struct {
+		name       string
+		grace      time.Duration
+		invalidate func(c *Tagged)
+		// how long after the invalidation the entry is looked up
+		after      time.Duration
+		wantValue  interface{}
+		wantLookup Lookup
+	}{
+		{"within grace", time.Minute, func(c *Tagged) { c.InvalidateTags("song:1") }, time.Second, "old", LookupStale},
+		{"purged within grace", time.Minute, func(c *Tagged) { c.Purge() }, time.Second, "old", LookupStale},
+		{"removed within grace", time.Minute, func(c *Tagged) { c.Remove("song 1") }, time.Second, "old", LookupStale},
+		{"grace expired", time.Minute, func(c *Tagged) { c.InvalidateTags("song:1") }, time.Minute + time.Second, "new", LookupMiss},
+		{"no grace", 0, func(c *Tagged) { c.InvalidateTags("song:1") }, 0, "new", LookupMiss},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestTagged(t, WithStaleGrace(tt.grace))
+			now := time.Now()
+			c.now = func() time.Time { return now }
+
+			c.AddWithTags("song 1", "old", "song:1")
+			tt.invalidate(c)
+			now = now.Add(tt.after)
+
+			refreshed := make(chan struct{})
+			load := func(ctx context.Context) (interface{}, []string, error) {
+				defer close(refreshed)
+				return "new", []string{"song:1"}, nil
+			}
+			value, lookup, err := c.GetOrLoad(context.Background(), "song 1", load)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != tt.wantValue || lookup != tt.wantLookup {
+				t.Errorf("got %v, %v, want %v, %v", value, lookup, tt.wantValue, tt.wantLookup)
+			}
+
+			// the stale value is refreshed in the background
+			select {
+			case <-refreshed:
+			case <-time.After(time.Second):
+				t.Fatal("the value isn't loaded again")
+			}
+			deadline := time.Now().Add(time.Second)
+			for !c.Contains("song 1") && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			if value, lookup, _ := c.GetOrLoad(context.Background(), "song 1", load); value != "new" || lookup != LookupHit {
+				t.Errorf("got %v, %v after the refresh, want new, %v", value, lookup, LookupHit)
+			}
+		})
+	}
+}
+
+func TestTaggedStats(t *testing.T) {
+	c := newTestTagged(t)
+
+	_, _, _ = c.GetOrLoad(context.Background(), "song 1", loaded("Waterloo", "song:1"))
+	_, _, _ = c.GetOrLoad(context.Background(), "song 1", loaded("Waterloo", "song:1"))
+	c.PurgeFrom("peer1")
+
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Stale != 0 {
+		t.Errorf("got %v hits, %v misses, %v stale, want 1, 1, 0", stats.Hits, stats.Misses, stats.Stale)
+	}
+	if stats.LastPurgeOrigin != "peer1" || stats.LastPurge.IsZero() {
+		t.Errorf("got last purge at %v from %q, want it from peer1", stats.LastPurge, stats.LastPurgeOrigin)
+	}
+}
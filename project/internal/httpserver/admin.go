@@ -0,0 +1,180 @@
+package httpserver
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"embed"
+	"example/hello/project/internal/cache"
+	"example/hello/project/internal/logger"
+	"example/hello/project/internal/message_broker"
+	"fmt"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// single-page admin UI, it's plain HTML & JS working with /v1 API, so there's nothing to build
+//
+//go:embed ui
+var adminUI embed.FS
+
+// AdminResource serves the admin UI and the operations which don't belong to any of the resources
+type AdminResource struct {
+	broker message_broker.MessageBroker
+	cache  cache.TaggedCache
+	// level of the logger of the peer, it's not exposed when nil
+	logLevel *zap.AtomicLevel
+	// required from every request, the routes are closed to everyone when it's empty
+	token  string
+	logger *zap.Logger
+}
+
+func NewAdminResource(broker message_broker.MessageBroker, cache cache.TaggedCache, logLevel *zap.AtomicLevel, token string, logger *zap.Logger) *AdminResource {
+	return &AdminResource{
+		broker:   broker,
+		cache:    cache,
+		logLevel: logLevel,
+		token:    token,
+		logger:   logger,
+	}
+}
+
+// cacheState is the state of the cache of this peer, the other peers might have other entries
+type cacheState struct {
+	Size   int      `json:"size"`
+	Keys   []string `json:"keys"`
+	Hits   uint64   `json:"hits"`
+	Misses uint64   `json:"misses"`
+	Stale  uint64   `json:"stale"`
+	// last purge is omitted if there hasn't been any
+	LastPurge       *time.Time `json:"last_purge,omitempty"`
+	LastPurgeOrigin string     `json:"last_purge_origin,omitempty"`
+}
+
+func (ar *AdminResource) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(adminOnly(ar.token))
+
+	r.Get("/cache", ar.CacheState)
+	// the key is path-escaped, as the keys of the responses contain slashes
+	r.Delete("/cache/{key}", ar.RemoveCacheEntry)
+	r.Post("/cache/purge", ar.PurgeCache)
+
+	// GET returns the current log level, PUT with {"level":"debug"} body changes it
+	if ar.logLevel != nil {
+		r.Handle("/log/level", ar.logLevel)
+	}
+
+	ui, err := fs.Sub(adminUI, "ui")
+	if err != nil {
+		// the directory is embedded at compile time, so it's always there
+		panic(err)
+	}
+	files := http.StripPrefix("/admin", http.FileServer(http.FS(ui)))
+	r.Get("/*", func(rw http.ResponseWriter, r *http.Request) {
+		// relative links of the UI only work with the trailing slash
+		if r.URL.Path == "/admin" {
+			http.Redirect(rw, r, "/admin/", http.StatusMovedPermanently)
+			return
+		}
+		files.ServeHTTP(rw, r)
+	})
+
+	return r
+}
+
+// adminOnly lets in the requests carrying the token, either as a bearer token or as the password of basic auth,
+// so that the browser asks for it when the UI is opened. Nobody is let in if the token isn't configured
+func adminOnly(token string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				rw.WriteHeader(http.StatusForbidden)
+				_, _ = fmt.Fprint(rw, "Admin routes are disabled, the admin token is not configured")
+				return
+			}
+
+			if !validAdminToken(r, token) {
+				rw.Header().Set("WWW-Authenticate", `Basic realm="Lostify admin", charset="UTF-8"`)
+				rw.WriteHeader(http.StatusUnauthorized)
+				_, _ = fmt.Fprint(rw, "Admin token is required")
+				return
+			}
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+func validAdminToken(r *http.Request, token string) bool {
+	given := ""
+	if _, password, ok := r.BasicAuth(); ok {
+		given = password
+	} else if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		given = strings.TrimPrefix(auth, "Bearer ")
+	}
+	if given == "" {
+		return false
+	}
+
+	// hashes are of the same length, so the comparison doesn't tell the length of the token either
+	givenSum, tokenSum := sha256.Sum256([]byte(given)), sha256.Sum256([]byte(token))
+	return subtle.ConstantTimeCompare(givenSum[:], tokenSum[:]) == 1
+}
+
+// CacheState lists the entries of the cache of this peer along with the lookup counters
+func (ar *AdminResource) CacheState(rw http.ResponseWriter, r *http.Request) {
+	stats := ar.cache.Stats()
+	state := cacheState{
+		Size:            ar.cache.Len(),
+		Keys:            []string{},
+		Hits:            stats.Hits,
+		Misses:          stats.Misses,
+		Stale:           stats.Stale,
+		LastPurgeOrigin: stats.LastPurgeOrigin,
+	}
+	for _, key := range ar.cache.Keys() {
+		state.Keys = append(state.Keys, fmt.Sprint(key))
+	}
+	if !stats.LastPurge.IsZero() {
+		state.LastPurge = &stats.LastPurge
+	}
+
+	render.JSON(rw, r, state)
+}
+
+// RemoveCacheEntry removes the entry from the caches of every peer
+func (ar *AdminResource) RemoveCacheEntry(rw http.ResponseWriter, r *http.Request) {
+	key, err := url.PathUnescape(chi.URLParam(r, "key"))
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintf(rw, "Unknown err: %v", err)
+		return
+	}
+
+	if err := ar.broker.Cache().Remove(r.Context(), key); err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprintf(rw, "Received error while removing from cache: %v", err)
+		return
+	}
+
+	logger.FromContext(r.Context(), ar.logger).Info("removed cache entry on request", zap.String("key", key))
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// PurgeCache purges the caches of every peer
+func (ar *AdminResource) PurgeCache(rw http.ResponseWriter, r *http.Request) {
+	if err := ar.broker.Cache().Purge(r.Context()); err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprintf(rw, "Received error while purging cache: %v", err)
+		return
+	}
+
+	logger.FromContext(r.Context(), ar.logger).Info("purged cache on request")
+	rw.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,116 @@
+package httpserver
+
+import (
+	"context"
+	"example/hello/project/internal/cache"
+	inmemorybroker "example/hello/project/internal/message_broker/inmemory"
+	"example/hello/project/internal/store/inmemory"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestServer(t *testing.T, opts ...ServerOption) (*httptest.Server, *cache.Tagged) {
+	t.Helper()
+
+	lru, err := cache.New(cache.Config{Strategy: cache.StrategyLRU, Size: 128})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appCache := cache.NewTagged(lru)
+	broker := inmemorybroker.NewBroker(appCache, "peer0", nil, zap.NewNop())
+	opts = append([]ServerOption{
+		WithStore(inmemory.NewDB()),
+		WithCache(appCache),
+		WithBroker(broker),
+	}, opts...)
+
+	srv := httptest.NewServer(NewServer(context.Background(), opts...).basicHandler())
+	t.Cleanup(srv.Close)
+
+	return srv, appCache
+}
+
+func TestAdminRoutesRequireToken(t *testing.T) {
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/admin/"},
+		{http.MethodGet, "/admin/cache"},
+		{http.MethodDelete, "/admin/cache/GET%20application%2Fjson%20anonymous%20%2Fv1%2Fsongs"},
+		{http.MethodPost, "/admin/cache/purge"},
+		{http.MethodPut, "/admin/log/level"},
+	}
+	tests := []struct {
+		name          string
+		token         string
+		authorization func(r *http.Request)
+		want          int
+	}{
+		{"no token configured", "", func(r *http.Request) { r.Header.Set("Authorization", "Bearer ") }, http.StatusForbidden},
+		{"anonymous", "secret", func(r *http.Request) {}, http.StatusUnauthorized},
+		{"wrong bearer token", "secret", func(r *http.Request) { r.Header.Set("Authorization", "Bearer guess") }, http.StatusUnauthorized},
+		{"wrong basic auth password", "secret", func(r *http.Request) { r.SetBasicAuth("admin", "guess") }, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newTestServer(t, WithAdminToken(tt.token), WithLogLevel(zap.NewAtomicLevel()))
+			for _, route := range routes {
+				req, err := http.NewRequest(route.method, srv.URL+route.path, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				tt.authorization(req)
+
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				_ = resp.Body.Close()
+				if resp.StatusCode != tt.want {
+					t.Errorf("%v %v: got status %v, want %v", route.method, route.path, resp.StatusCode, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestAdminRoutesWithToken(t *testing.T) {
+	srv, appCache := newTestServer(t, WithAdminToken("secret"))
+	appCache.AddWithTags("key", "value", "tag")
+
+	tests := []struct {
+		method        string
+		path          string
+		authorization func(r *http.Request)
+		want          int
+	}{
+		{http.MethodGet, "/admin/cache", func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") }, http.StatusOK},
+		{http.MethodGet, "/admin/", func(r *http.Request) { r.SetBasicAuth("admin", "secret") }, http.StatusOK},
+		{http.MethodPost, "/admin/cache/purge", func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") }, http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, srv.URL+tt.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tt.authorization(req)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%v %v: got status %v, want %v", tt.method, tt.path, resp.StatusCode, tt.want)
+		}
+	}
+
+	if stats := appCache.Stats(); stats.LastPurge.IsZero() {
+		t.Error("cache hasn't been purged")
+	}
+}
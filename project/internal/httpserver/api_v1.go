@@ -0,0 +1,156 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"example/hello/project/internal/models"
+	"example/hello/project/internal/store"
+	"io"
+	"strconv"
+)
+
+// apiV1 is the original representation, the same the unversioned routes have always had
+type apiV1 struct{}
+
+type songV1 struct {
+	ID        int    `json:"id" yaml:"id"`
+	Title     string `json:"title" yaml:"title"`
+	ArtistID  int    `json:"artist_id" yaml:"artist_id"`
+	Lyrics    string `json:"lyrics" yaml:"lyrics"`
+	AlbumName string `json:"album_name" yaml:"album_name"`
+}
+
+type songsV1 []*songV1
+
+type artistV1 struct {
+	ID       int    `json:"id" yaml:"id"`
+	FullName string `json:"full_name" yaml:"full_name"`
+}
+
+type artistsV1 []*artistV1
+
+type artistWithSongsV1 struct {
+	artistV1 `yaml:",inline"`
+	Songs    songsV1 `json:"songs" yaml:"songs"`
+}
+
+func (apiV1) decodeSong(body io.Reader) (*models.Song, error) {
+	in := new(songV1)
+	if err := json.NewDecoder(body).Decode(in); err != nil {
+		return nil, err
+	}
+
+	return &models.Song{
+		ID:        in.ID,
+		Title:     in.Title,
+		ArtistID:  in.ArtistID,
+		Lyrics:    in.Lyrics,
+		AlbumName: in.AlbumName,
+	}, nil
+}
+
+func (apiV1) decodeArtist(body io.Reader) (*models.Artist, error) {
+	in := new(artistV1)
+	if err := json.NewDecoder(body).Decode(in); err != nil {
+		return nil, err
+	}
+
+	return &models.Artist{
+		ID:       in.ID,
+		FullName: in.FullName,
+	}, nil
+}
+
+func toSongV1(song *models.Song) *songV1 {
+	return &songV1{
+		ID:        song.ID,
+		Title:     song.Title,
+		ArtistID:  song.ArtistID,
+		Lyrics:    song.Lyrics,
+		AlbumName: song.AlbumName,
+	}
+}
+
+func (apiV1) song(ctx context.Context, store store.Store, song *models.Song) (interface{}, error) {
+	return toSongV1(song), nil
+}
+
+func (apiV1) songs(ctx context.Context, store store.Store, songs []*models.Song) (interface{}, error) {
+	out := make(songsV1, 0, len(songs))
+	for _, song := range songs {
+		out = append(out, toSongV1(song))
+	}
+	return out, nil
+}
+
+func toArtistV1(artist *models.Artist) *artistV1 {
+	return &artistV1{
+		ID:       artist.ID,
+		FullName: artist.FullName,
+	}
+}
+
+func (apiV1) artist(artist *models.Artist) interface{} {
+	return toArtistV1(artist)
+}
+
+func (apiV1) artists(artists []*models.Artist) interface{} {
+	out := make(artistsV1, 0, len(artists))
+	for _, artist := range artists {
+		out = append(out, toArtistV1(artist))
+	}
+	return out
+}
+
+func (v apiV1) artistWithSongs(ctx context.Context, store store.Store, artist *models.Artist, songs []*models.Song) (interface{}, error) {
+	out, err := v.songs(ctx, store, songs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &artistWithSongsV1{
+		artistV1: *toArtistV1(artist),
+		Songs:    out.(songsV1),
+	}, nil
+}
+
+var (
+	songV1Header   = []string{"id", "title", "artist_id", "lyrics", "album_name"}
+	artistV1Header = []string{"id", "full_name"}
+)
+
+func (s *songV1) csvRecord() []string {
+	return []string{strconv.Itoa(s.ID), s.Title, strconv.Itoa(s.ArtistID), s.Lyrics, s.AlbumName}
+}
+
+func (s *songV1) csvRecords() [][]string {
+	return [][]string{songV1Header, s.csvRecord()}
+}
+
+func (s songsV1) csvRecords() [][]string {
+	records := [][]string{songV1Header}
+	for _, song := range s {
+		records = append(records, song.csvRecord())
+	}
+	return records
+}
+
+func (a *artistV1) csvRecord() []string {
+	return []string{strconv.Itoa(a.ID), a.FullName}
+}
+
+func (a *artistV1) csvRecords() [][]string {
+	return [][]string{artistV1Header, a.csvRecord()}
+}
+
+func (a artistsV1) csvRecords() [][]string {
+	records := [][]string{artistV1Header}
+	for _, artist := range a {
+		records = append(records, artist.csvRecord())
+	}
+	return records
+}
+
+func (a *artistWithSongsV1) csvRecords() [][]string {
+	return a.Songs.csvRecords()
+}
@@ -0,0 +1,224 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"example/hello/project/internal/models"
+	"example/hello/project/internal/store"
+	"io"
+	"strconv"
+)
+
+// apiV2 embeds the artist into the song and reports the status of the lyrics separately from the lyrics themselves
+type apiV2 struct{}
+
+const (
+	lyricsV2Pending  = "pending"
+	lyricsV2Fetching = "fetching"
+	lyricsV2Failed   = "failed"
+	lyricsV2Ready    = "ready"
+)
+
+type songV2 struct {
+	ID        int       `json:"id" yaml:"id"`
+	Title     string    `json:"title" yaml:"title"`
+	Artist    *artistV2 `json:"artist" yaml:"artist"`
+	AlbumName string    `json:"album_name,omitempty" yaml:"album_name,omitempty"`
+	Lyrics    lyricsV2  `json:"lyrics" yaml:"lyrics"`
+}
+
+type lyricsV2 struct {
+	Status string `json:"status" yaml:"status"`
+	Text   string `json:"text,omitempty" yaml:"text,omitempty"`
+}
+
+type songsV2 []*songV2
+
+type artistV2 struct {
+	ID       int    `json:"id" yaml:"id"`
+	FullName string `json:"full_name,omitempty" yaml:"full_name,omitempty"`
+}
+
+type artistsV2 []*artistV2
+
+type artistWithSongsV2 struct {
+	artistV2 `yaml:",inline"`
+	Songs    songsV2 `json:"songs" yaml:"songs"`
+}
+
+func (apiV2) decodeSong(body io.Reader) (*models.Song, error) {
+	// only the ID of the artist is taken into account, the artist itself is managed at /v2/artists
+	in := new(songV2)
+	if err := json.NewDecoder(body).Decode(in); err != nil {
+		return nil, err
+	}
+
+	song := &models.Song{
+		ID:        in.ID,
+		Title:     in.Title,
+		AlbumName: in.AlbumName,
+	}
+	if in.Artist != nil {
+		song.ArtistID = in.Artist.ID
+	}
+	return song, nil
+}
+
+func (apiV2) decodeArtist(body io.Reader) (*models.Artist, error) {
+	in := new(artistV2)
+	if err := json.NewDecoder(body).Decode(in); err != nil {
+		return nil, err
+	}
+
+	return &models.Artist{
+		ID:       in.ID,
+		FullName: in.FullName,
+	}, nil
+}
+
+func toLyricsV2(lyrics string) lyricsV2 {
+	switch lyrics {
+	case "":
+		return lyricsV2{Status: lyricsV2Pending}
+	case models.LyricsStatusFetching:
+		return lyricsV2{Status: lyricsV2Fetching}
+	case models.LyricsStatusFailed:
+		return lyricsV2{Status: lyricsV2Failed}
+	default:
+		return lyricsV2{Status: lyricsV2Ready, Text: lyrics}
+	}
+}
+
+// toSongV2 embeds the artist if found, only its ID is set otherwise
+func toSongV2(song *models.Song, artists map[int]*models.Artist) *songV2 {
+	artist := &artistV2{ID: song.ArtistID}
+	if found, ok := artists[song.ArtistID]; ok {
+		artist.FullName = found.FullName
+	}
+
+	return &songV2{
+		ID:        song.ID,
+		Title:     song.Title,
+		Artist:    artist,
+		AlbumName: song.AlbumName,
+		Lyrics:    toLyricsV2(song.Lyrics),
+	}
+}
+
+func (v apiV2) song(ctx context.Context, store store.Store, song *models.Song) (interface{}, error) {
+	songs, err := v.songs(ctx, store, []*models.Song{song})
+	if err != nil {
+		return nil, err
+	}
+
+	return songs.(songsV2)[0], nil
+}
+
+// songs loads the artists of all the songs at once
+func (apiV2) songs(ctx context.Context, store store.Store, songs []*models.Song) (interface{}, error) {
+	ids := make([]int, 0, len(songs))
+	seen := make(map[int]bool, len(songs))
+	for _, song := range songs {
+		if !seen[song.ArtistID] {
+			seen[song.ArtistID] = true
+			ids = append(ids, song.ArtistID)
+		}
+	}
+
+	artists := make(map[int]*models.Artist, len(ids))
+	if len(ids) > 0 {
+		found, err := store.Artists().ByIDs(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, artist := range found {
+			artists[artist.ID] = artist
+		}
+	}
+
+	out := make(songsV2, 0, len(songs))
+	for _, song := range songs {
+		out = append(out, toSongV2(song, artists))
+	}
+	return out, nil
+}
+
+func toArtistV2(artist *models.Artist) *artistV2 {
+	return &artistV2{
+		ID:       artist.ID,
+		FullName: artist.FullName,
+	}
+}
+
+func (apiV2) artist(artist *models.Artist) interface{} {
+	return toArtistV2(artist)
+}
+
+func (apiV2) artists(artists []*models.Artist) interface{} {
+	out := make(artistsV2, 0, len(artists))
+	for _, artist := range artists {
+		out = append(out, toArtistV2(artist))
+	}
+	return out
+}
+
+func (v apiV2) artistWithSongs(ctx context.Context, store store.Store, artist *models.Artist, songs []*models.Song) (interface{}, error) {
+	out, err := v.songs(ctx, store, songs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &artistWithSongsV2{
+		artistV2: *toArtistV2(artist),
+		Songs:    out.(songsV2),
+	}, nil
+}
+
+var (
+	songV2Header   = []string{"id", "title", "artist_id", "artist_full_name", "album_name", "lyrics_status", "lyrics"}
+	artistV2Header = []string{"id", "full_name"}
+)
+
+func (s *songV2) csvRecord() []string {
+	return []string{
+		strconv.Itoa(s.ID),
+		s.Title,
+		strconv.Itoa(s.Artist.ID),
+		s.Artist.FullName,
+		s.AlbumName,
+		s.Lyrics.Status,
+		s.Lyrics.Text,
+	}
+}
+
+func (s *songV2) csvRecords() [][]string {
+	return [][]string{songV2Header, s.csvRecord()}
+}
+
+func (s songsV2) csvRecords() [][]string {
+	records := [][]string{songV2Header}
+	for _, song := range s {
+		records = append(records, song.csvRecord())
+	}
+	return records
+}
+
+func (a *artistV2) csvRecord() []string {
+	return []string{strconv.Itoa(a.ID), a.FullName}
+}
+
+func (a *artistV2) csvRecords() [][]string {
+	return [][]string{artistV2Header, a.csvRecord()}
+}
+
+func (a artistsV2) csvRecords() [][]string {
+	records := [][]string{artistV2Header}
+	for _, artist := range a {
+		records = append(records, artist.csvRecord())
+	}
+	return records
+}
+
+func (a *artistWithSongsV2) csvRecords() [][]string {
+	return a.Songs.csvRecords()
+}
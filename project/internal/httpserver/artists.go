@@ -1,44 +1,61 @@
 package httpserver
 
 import (
-	"encoding/json"
+	"context"
+	"example/hello/project/internal/cache"
 	"example/hello/project/internal/message_broker"
+	"example/hello/project/internal/metrics"
 	"example/hello/project/internal/models"
 	"example/hello/project/internal/store"
 	"fmt"
 	"github.com/go-chi/chi"
-	"github.com/go-chi/render"
 	validation "github.com/go-ozzo/ozzo-validation/v4"
-	lru "github.com/hashicorp/golang-lru"
-	"log"
+	"go.uber.org/zap"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 type ArtistResource struct {
-	store  store.Store
-	broker message_broker.MessageBroker
-	cache  *lru.TwoQueueCache
+	store   store.Store
+	broker  message_broker.MessageBroker
+	cache   cache.TaggedCache
+	metrics *metrics.Metrics
+	logger  *zap.Logger
+	// representation of the resource
+	version apiVersion
+	// discography of the artist is served by the songs resource
+	songs *SongResource
 }
 
-func NewArtistResource(store store.Store, broker message_broker.MessageBroker, cache *lru.TwoQueueCache) *ArtistResource {
+func NewArtistResource(store store.Store, broker message_broker.MessageBroker, cache cache.TaggedCache, metrics *metrics.Metrics, logger *zap.Logger, version apiVersion, songs *SongResource) *ArtistResource {
 	return &ArtistResource{
-		store:  store,
-		broker: broker,
-		cache:  cache,
+		store:   store,
+		broker:  broker,
+		cache:   cache,
+		metrics: metrics,
+		logger:  logger,
+		version: version,
+		songs:   songs,
 	}
 }
 
 func (ar *ArtistResource) Routes() chi.Router {
 	r := chi.NewRouter()
 
+	cached := r.With(responseCache(ar.cache, ar.metrics, ar.logger, "artists"))
+
 	// RESTy routes for "artists" resource
 	r.Post("/", ar.CreateArtist)
-	r.Get("/", ar.AllArtists)
-	r.Get("/{id}", ar.ByID)
+	cached.Get("/", ar.AllArtists)
+	cached.Get("/{id}", ar.ByID)
 	r.Put("/", ar.UpdateArtist)
 	r.Delete("/{id}", ar.DeleteArtist)
 
+	// discography of the artist, paginated the same way as /songs
+	r.With(responseCache(ar.cache, ar.metrics, ar.logger, "songs")).Get("/{id}/songs", ar.songs.ArtistSongs)
+	r.Post("/{id}/songs", ar.songs.CreateArtistSong)
+
 	return r
 }
 
@@ -51,58 +68,68 @@ func validateArtist(artist *models.Artist) error {
 }
 
 func (ar *ArtistResource) CreateArtist(rw http.ResponseWriter, r *http.Request) {
-	artist := new(models.Artist)
-	if err := json.NewDecoder(r.Body).Decode(artist); err != nil {
+	artist, err := ar.version.decodeArtist(r.Body)
+	if err != nil {
 		rw.WriteHeader(http.StatusUnprocessableEntity)
 		_, _ = fmt.Fprintf(rw, "Unknown err: %v", err)
 		return
 	}
 
-	if err := validateArtist(artist); err != nil {
-		rw.WriteHeader(http.StatusUnprocessableEntity)
-		_, _ = fmt.Fprintf(rw, "Validation err: %v", err)
+	if err := ar.createArtist(r.Context(), artist); err != nil {
+		writeFailed(rw, err)
 		return
 	}
 
-	if err := ar.store.Artists().Create(r.Context(), artist); err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		_, _ = fmt.Fprintf(rw, "DB err: %v", err)
-		return
+	rw.WriteHeader(http.StatusCreated)
+}
+
+// createArtist is the same for REST & GraphQL, so are the updates & deletes below
+func (ar *ArtistResource) createArtist(ctx context.Context, artist *models.Artist) error {
+	if err := validateArtist(artist); err != nil {
+		return validationError(err)
 	}
 
-	if err := ar.broker.Cache().Purge(); err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		_, _ = fmt.Fprintf(rw, "Received error while purging cache: %v", err)
-		return
+	if err := ar.store.Artists().Create(ctx, artist); err != nil {
+		return dbError(err)
 	}
 
-	rw.WriteHeader(http.StatusCreated)
+	event := &models.Event{Type: models.EventArtistCreated, Artist: artist}
+	if err := invalidateCache(ctx, ar.broker, event); err != nil {
+		return cacheError(err)
+	}
+
+	publishEvent(ctx, ar.broker, ar.logger, event)
+	return nil
 }
 
 func (ar *ArtistResource) AllArtists(rw http.ResponseWriter, r *http.Request) {
-	dataFromCache, ok := ar.cache.Get(r.RequestURI)
-	if ok {
-		log.Println("found data from cache with URI =", r.RequestURI)
-		render.JSON(rw, r, dataFromCache)
+	mediaType, ok := negotiate(r)
+	if !ok {
+		notAcceptable(rw)
 		return
 	}
 
-	artists, err := ar.store.Artists().All(r.Context())
+	page, err := pageFromQuery(r)
 	if err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		_, _ = fmt.Fprintf(rw, "DB err: %v", err)
+		rw.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintf(rw, "Pagination err: %v", err)
 		return
 	}
 
-	ar.cache.Add(r.RequestURI, artists)
-	render.JSON(rw, r, artists)
+	respondTagged(rw, r, mediaType, func(ctx context.Context) (interface{}, []string, error) {
+		artists, err := ar.store.Artists().All(ctx, &page)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return ar.version.artists(artists), []string{tagArtistList}, nil
+	})
 }
 
 func (ar *ArtistResource) ByID(rw http.ResponseWriter, r *http.Request) {
-	dataFromCache, ok := ar.cache.Get(r.RequestURI)
-	if ok {
-		log.Println("found data from cache with URI =", r.RequestURI)
-		render.JSON(rw, r, dataFromCache)
+	mediaType, ok := negotiate(r)
+	if !ok {
+		notAcceptable(rw)
 		return
 	}
 
@@ -113,42 +140,79 @@ func (ar *ArtistResource) ByID(rw http.ResponseWriter, r *http.Request) {
 		_, _ = fmt.Fprintf(rw, "Unknown err: %v", err)
 		return
 	}
-	artist, err := ar.store.Artists().ByID(r.Context(), id)
-	if err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		_, _ = fmt.Fprintf(rw, "DB err: %v", err)
-		return
-	}
 
-	ar.cache.Add(r.RequestURI, artist)
-	render.JSON(rw, r, artist)
+	// ?expand=songs embeds a page of the songs of the artist (?limit= & ?offset= apply to them)
+	expandSongs := false
+	for _, expand := range strings.Split(r.URL.Query().Get("expand"), ",") {
+		switch strings.TrimSpace(expand) {
+		case "":
+		case "songs":
+			expandSongs = true
+		default:
+			rw.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintf(rw, "Unknown expand: %v", expand)
+			return
+		}
+	}
+
+	var page models.Page
+	if expandSongs {
+		if page, err = pageFromQuery(r); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintf(rw, "Pagination err: %v", err)
+			return
+		}
+	}
+
+	respondTagged(rw, r, mediaType, func(ctx context.Context) (interface{}, []string, error) {
+		artist, err := ar.store.Artists().ByID(ctx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !expandSongs {
+			return ar.version.artist(artist), []string{artistTag(id)}, nil
+		}
+
+		songs, err := ar.store.Songs().ByArtistID(ctx, id, &page)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		body, err := ar.version.artistWithSongs(ctx, ar.store, artist, songs)
+		return body, []string{artistTag(id), tagSongList}, err
+	})
 }
 
 func (ar *ArtistResource) UpdateArtist(rw http.ResponseWriter, r *http.Request) {
-	artist := new(models.Artist)
-	if err := json.NewDecoder(r.Body).Decode(artist); err != nil {
+	artist, err := ar.version.decodeArtist(r.Body)
+	if err != nil {
 		rw.WriteHeader(http.StatusUnprocessableEntity)
 		_, _ = fmt.Fprintf(rw, "Unknown err: %v", err)
 		return
 	}
 
+	if err := ar.updateArtist(r.Context(), artist); err != nil {
+		writeFailed(rw, err)
+	}
+}
+
+func (ar *ArtistResource) updateArtist(ctx context.Context, artist *models.Artist) error {
 	if err := validateArtist(artist); err != nil {
-		rw.WriteHeader(http.StatusUnprocessableEntity)
-		_, _ = fmt.Fprintf(rw, "Validation err: %v", err)
-		return
+		return validationError(err)
 	}
 
-	if err := ar.store.Artists().Update(r.Context(), artist); err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		_, _ = fmt.Fprintf(rw, "DB err: %v", err)
-		return
+	if err := ar.store.Artists().Update(ctx, artist); err != nil {
+		return dbError(err)
 	}
 
-	if err := ar.broker.Cache().Purge(); err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		_, _ = fmt.Fprintf(rw, "Received error while purging cache: %v", err)
-		return
+	event := &models.Event{Type: models.EventArtistUpdated, Artist: artist}
+	if err := invalidateCache(ctx, ar.broker, event); err != nil {
+		return cacheError(err)
 	}
+
+	publishEvent(ctx, ar.broker, ar.logger, event)
+	return nil
 }
 
 func (ar *ArtistResource) DeleteArtist(rw http.ResponseWriter, r *http.Request) {
@@ -160,15 +224,21 @@ func (ar *ArtistResource) DeleteArtist(rw http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := ar.store.Artists().Delete(r.Context(), id); err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		_, _ = fmt.Fprintf(rw, "DB err: %v", err)
-		return
+	if err := ar.deleteArtist(r.Context(), id); err != nil {
+		writeFailed(rw, err)
+	}
+}
+
+func (ar *ArtistResource) deleteArtist(ctx context.Context, id int) error {
+	if err := ar.store.Artists().Delete(ctx, id); err != nil {
+		return dbError(err)
 	}
 
-	if err := ar.broker.Cache().Purge(); err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		_, _ = fmt.Fprintf(rw, "Received error while purging cache: %v", err)
-		return
+	event := &models.Event{Type: models.EventArtistDeleted, Artist: &models.Artist{ID: id}}
+	if err := invalidateCache(ctx, ar.broker, event); err != nil {
+		return cacheError(err)
 	}
+
+	publishEvent(ctx, ar.broker, ar.logger, event)
+	return nil
 }
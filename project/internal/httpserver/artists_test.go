@@ -0,0 +1,111 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"example/hello/project/internal/models"
+	"example/hello/project/internal/store/inmemory"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// songIDs returns the IDs of the songs in the order of the response
+func songIDs(songs []map[string]interface{}) []int {
+	ids := []int{}
+	for _, song := range songs {
+		ids = append(ids, int(song["id"].(float64)))
+	}
+	return ids
+}
+
+func TestArtistSongs(t *testing.T) {
+	db := inmemory.NewDB()
+	ctx := context.Background()
+	for _, artist := range []*models.Artist{{ID: 1, FullName: "ABBA"}, {ID: 2, FullName: "Queen"}} {
+		if err := db.Artists().Create(ctx, artist); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for id := 1; id <= 13; id++ {
+		song := &models.Song{ID: id, Title: fmt.Sprintf("Song #%v", id), ArtistID: 1}
+		if id == 13 {
+			song.ArtistID = 2
+		}
+		if err := db.Songs().Create(ctx, song); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv, _ := newTestServer(t, WithStore(db))
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		// songs of the page
+		wantSongs []int
+		// the artist is only expected with ?expand=songs
+		wantArtist bool
+	}{
+		{"/v1/artists/1/songs", http.StatusOK, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, false},
+		{"/v1/artists/1/songs?limit=5&offset=10", http.StatusOK, []int{11, 12}, false},
+		{"/v1/artists/1/songs?offset=20", http.StatusOK, []int{}, false},
+		{"/v1/artists/2/songs", http.StatusOK, []int{13}, false},
+		{"/v2/artists/1/songs?limit=3&offset=1", http.StatusOK, []int{2, 3, 4}, false},
+		{"/v1/artists/1/songs?limit=0", http.StatusBadRequest, nil, false},
+		{"/v1/artists/1/songs?limit=101", http.StatusBadRequest, nil, false},
+		{"/v1/artists/1/songs?offset=-1", http.StatusBadRequest, nil, false},
+		{"/v1/artists/one/songs", http.StatusBadRequest, nil, false},
+		{"/v1/artists/1?expand=songs", http.StatusOK, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, true},
+		{"/v1/artists/1?expand=songs&limit=2&offset=11", http.StatusOK, []int{12}, true},
+		{"/v2/artists/2?expand=songs", http.StatusOK, []int{13}, true},
+		{"/v1/artists/1?expand=songs&limit=101", http.StatusBadRequest, nil, false},
+		{"/v1/artists/1?expand=albums", http.StatusBadRequest, nil, false},
+		{"/v1/artists/404?expand=songs", http.StatusNotFound, nil, false},
+		// the pagination only applies to the expanded songs
+		{"/v1/artists/1?limit=101", http.StatusOK, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("got status %v, want %v", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var songs []map[string]interface{}
+			if tt.wantArtist {
+				artist := new(struct {
+					ID       int                      `json:"id"`
+					FullName string                   `json:"full_name"`
+					Songs    []map[string]interface{} `json:"songs"`
+				})
+				if err := json.NewDecoder(resp.Body).Decode(artist); err != nil {
+					t.Fatal(err)
+				}
+				if artist.ID == 0 || artist.FullName == "" {
+					t.Errorf("got artist %+v", artist)
+				}
+				songs = artist.Songs
+			} else if err := json.NewDecoder(resp.Body).Decode(&songs); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.wantSongs == nil {
+				if songs != nil {
+					t.Errorf("got songs %v embedded, want none", songIDs(songs))
+				}
+				return
+			}
+			if got := songIDs(songs); fmt.Sprint(got) != fmt.Sprint(tt.wantSongs) {
+				t.Errorf("got songs %v, want %v", got, tt.wantSongs)
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"example/hello/project/internal/logger"
+	"example/hello/project/internal/models"
+	"example/hello/project/internal/shutdown"
+	"example/hello/project/internal/store"
+	"fmt"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// more operations have to be split into several batches
+const maxBatchSize = 1000
+
+const (
+	batchActionCreate = "create"
+	batchActionUpdate = "update"
+	batchActionDelete = "delete"
+
+	batchResourceSongs   = "songs"
+	batchResourceArtists = "artists"
+)
+
+// BatchResource applies many creates, updates & deletes of songs and artists in a single request,
+// the caches of every peer are purged once at the end instead of after every operation
+type BatchResource struct {
+	// lyrics of the created songs are fetched in background, drained on shutdown
+	jobs    *shutdown.Manager
+	songs   *SongResource
+	artists *ArtistResource
+}
+
+type batchOperation struct {
+	Action   string `json:"action"`
+	Resource string `json:"resource"`
+	// song or artist to create or update
+	Song   *models.Song   `json:"song,omitempty"`
+	Artist *models.Artist `json:"artist,omitempty"`
+	// ID of song or artist to delete
+	ID int `json:"id,omitempty"`
+}
+
+type batchRequest struct {
+	// either all the operations are applied or none of them
+	Atomic     bool              `json:"atomic"`
+	Operations []*batchOperation `json:"operations"`
+}
+
+type batchResult struct {
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Results []*batchResult `json:"results"`
+}
+
+func NewBatchResource(jobs *shutdown.Manager, songs *SongResource, artists *ArtistResource) *BatchResource {
+	return &BatchResource{
+		jobs:    jobs,
+		songs:   songs,
+		artists: artists,
+	}
+}
+
+func (br *BatchResource) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Post("/", br.Batch)
+
+	return r
+}
+
+func validateBatchOperation(op *batchOperation) error {
+	switch op.Resource {
+	case batchResourceSongs:
+		switch op.Action {
+		case batchActionCreate, batchActionUpdate:
+			if op.Song == nil {
+				return errors.New("song is required")
+			}
+			return validateSong(op.Song)
+		case batchActionDelete:
+			if op.ID == 0 {
+				return errors.New("id is required")
+			}
+			return nil
+		}
+	case batchResourceArtists:
+		switch op.Action {
+		case batchActionCreate, batchActionUpdate:
+			if op.Artist == nil {
+				return errors.New("artist is required")
+			}
+			return validateArtist(op.Artist)
+		case batchActionDelete:
+			if op.ID == 0 {
+				return errors.New("id is required")
+			}
+			return nil
+		}
+	default:
+		return fmt.Errorf("unknown resource %q", op.Resource)
+	}
+
+	return fmt.Errorf("unknown action %q", op.Action)
+}
+
+// apply makes the change in the store and returns the event describing it
+func (br *BatchResource) apply(ctx context.Context, op *batchOperation) (*models.Event, error) {
+	switch op.Resource {
+	case batchResourceSongs:
+		switch op.Action {
+		case batchActionCreate:
+			// the song tells its lyrics are being fetched until the saga is over, the same as the one created alone
+			op.Song.Lyrics = models.LyricsStatusFetching
+			return &models.Event{Type: models.EventSongCreated, Song: op.Song}, br.songs.store.Songs().Create(ctx, op.Song)
+		case batchActionUpdate:
+			return &models.Event{Type: models.EventSongUpdated, Song: op.Song}, br.songs.store.Songs().Update(ctx, op.Song)
+		case batchActionDelete:
+			return &models.Event{Type: models.EventSongDeleted, Song: &models.Song{ID: op.ID}}, br.songs.store.Songs().Delete(ctx, op.ID)
+		}
+	case batchResourceArtists:
+		switch op.Action {
+		case batchActionCreate:
+			return &models.Event{Type: models.EventArtistCreated, Artist: op.Artist}, br.artists.store.Artists().Create(ctx, op.Artist)
+		case batchActionUpdate:
+			return &models.Event{Type: models.EventArtistUpdated, Artist: op.Artist}, br.artists.store.Artists().Update(ctx, op.Artist)
+		case batchActionDelete:
+			return &models.Event{Type: models.EventArtistDeleted, Artist: &models.Artist{ID: op.ID}}, br.artists.store.Artists().Delete(ctx, op.ID)
+		}
+	}
+
+	// unreachable for the validated operations
+	return nil, fmt.Errorf("unknown operation %v %v", op.Action, op.Resource)
+}
+
+func successStatus(op *batchOperation) int {
+	if op.Action == batchActionCreate {
+		return http.StatusCreated
+	}
+	return http.StatusOK
+}
+
+// Batch responds with the result of every operation in the same order.
+// In atomic mode, nothing is applied if any of the operations fails, the rest of them are marked with 424 status;
+// the batch fails with 500 as a whole if the transaction itself does (e.g. it can't be started or committed).
+func (br *BatchResource) Batch(rw http.ResponseWriter, r *http.Request) {
+	request := new(batchRequest)
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		rw.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = fmt.Fprintf(rw, "Unknown err: %v", err)
+		return
+	}
+
+	if len(request.Operations) == 0 || len(request.Operations) > maxBatchSize {
+		rw.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = fmt.Fprintf(rw, "Validation err: batch must contain between 1 and %v operations", maxBatchSize)
+		return
+	}
+
+	response := &batchResponse{Results: make([]*batchResult, len(request.Operations))}
+	valid := true
+	for i, op := range request.Operations {
+		response.Results[i] = &batchResult{}
+		if err := validateBatchOperation(op); err != nil {
+			response.Results[i] = &batchResult{Status: http.StatusUnprocessableEntity, Error: fmt.Sprintf("Validation err: %v", err)}
+			valid = false
+		}
+	}
+
+	if request.Atomic && !valid {
+		markFailedDependencies(response)
+		render.Status(r, http.StatusUnprocessableEntity)
+		render.JSON(rw, r, response)
+		return
+	}
+
+	events := make([]*models.Event, len(request.Operations))
+	// tells the failures of the operations from the ones of the transaction,
+	// the error isn't wrapped as the store might retry the transaction depending on it
+	var failedOp bool
+	applyAll := func(ctx context.Context) error {
+		for i, op := range request.Operations {
+			if response.Results[i].Status == http.StatusUnprocessableEntity {
+				continue
+			}
+
+			event, err := br.apply(ctx, op)
+			if err != nil {
+				response.Results[i] = failedBatchResult(err)
+				if request.Atomic {
+					failedOp = true
+					return err
+				}
+				continue
+			}
+
+			response.Results[i] = &batchResult{Status: successStatus(op)}
+			events[i] = event
+		}
+		return nil
+	}
+
+	if request.Atomic {
+		err := br.songs.store.Transaction(r.Context(), func(ctx context.Context) error {
+			// the transaction might be retried, so the results of the previous attempt are discarded
+			for i := range response.Results {
+				response.Results[i], events[i] = &batchResult{}, nil
+			}
+			failedOp = false
+			return applyAll(ctx)
+		})
+		if err != nil && !failedOp {
+			logger.FromContext(r.Context(), br.songs.logger).Error("batch transaction failed", zap.Int("operations", len(request.Operations)), zap.Error(err))
+			rw.WriteHeader(http.StatusInternalServerError)
+			_, _ = fmt.Fprintf(rw, "DB err: %v", err)
+			return
+		}
+		if err != nil {
+			markFailedDependencies(response)
+			render.Status(r, http.StatusUnprocessableEntity)
+			render.JSON(rw, r, response)
+			return
+		}
+	} else {
+		_ = applyAll(r.Context())
+	}
+
+	var applied []*models.Event
+	var createdSongs []*models.Song
+	for i, event := range events {
+		if event == nil {
+			continue
+		}
+		applied = append(applied, event)
+		if event.Type == models.EventSongCreated {
+			createdSongs = append(createdSongs, request.Operations[i].Song)
+		}
+	}
+
+	if len(applied) > 0 {
+		// single invalidation for the whole batch
+		if err := invalidateCache(r.Context(), br.songs.broker, applied...); err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			_, _ = fmt.Fprintf(rw, "Received error while invalidating cache: %v", err)
+			return
+		}
+
+		for _, event := range applied {
+			publishEvent(r.Context(), br.songs.broker, br.songs.logger, event)
+		}
+	}
+
+	if len(createdSongs) > 0 {
+		log := logger.FromContext(r.Context(), br.songs.logger)
+		br.jobs.Go("batch lyrics", func(ctx context.Context) {
+			br.fetchLyrics(logger.WithContext(ctx, log), createdSongs)
+		})
+	}
+
+	render.JSON(rw, r, response)
+}
+
+// failedBatchResult is 404 for the operations on songs or artists which don't exist, 500 for the rest
+func failedBatchResult(err error) *batchResult {
+	if errors.Is(err, store.ErrNotFound) {
+		return &batchResult{Status: http.StatusNotFound, Error: fmt.Sprintf("Not found: %v", err)}
+	}
+	return &batchResult{Status: http.StatusInternalServerError, Error: fmt.Sprintf("DB err: %v", err)}
+}
+
+// markFailedDependencies marks the operations which haven't failed themselves, but weren't applied because of the others
+func markFailedDependencies(response *batchResponse) {
+	for _, result := range response.Results {
+		if result.Error == "" {
+			result.Status = http.StatusFailedDependency
+			result.Error = "not applied since another operation of the atomic batch has failed"
+		}
+	}
+}
+
+// fetchLyrics runs the lyrics saga for the created songs one by one, a batch is too large to wait for them.
+// The cache is invalidated after every song, so that its lyrics are served as soon as they're fetched
+func (br *BatchResource) fetchLyrics(ctx context.Context, songs []*models.Song) {
+	log := logger.FromContext(ctx, br.songs.logger)
+
+	for _, song := range songs {
+		if ctx.Err() != nil {
+			break
+		}
+		// the published song.created events still refer to the original
+		song := *song
+
+		if err := br.songs.fetchTheLyrics(ctx, &song); err != nil {
+			log.Error("failed to fetch the lyrics of the batch", zap.Int("song_id", song.ID), zap.Error(err))
+			continue
+		}
+		event := lyricsEvent(&song)
+		if err := invalidateCache(ctx, br.songs.broker, event); err != nil {
+			log.Error("failed to invalidate cache after fetching the lyrics", zap.Int("song_id", song.ID), zap.Error(err))
+		}
+		publishEvent(ctx, br.songs.broker, br.songs.logger, event)
+	}
+}
@@ -0,0 +1,245 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"example/hello/project/internal/models"
+	"example/hello/project/internal/shutdown"
+	"example/hello/project/internal/store"
+	"example/hello/project/internal/store/inmemory"
+	"fmt"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// uncommittableStore applies the transactions, but fails to commit them
+type uncommittableStore struct {
+	store.Store
+}
+
+func (s uncommittableStore) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := s.Store.Transaction(ctx, fn); err != nil {
+		return err
+	}
+	return errors.New("transaction has been aborted")
+}
+
+func TestBatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		store      func() store.Store
+		batch      string
+		wantStatus int
+		// statuses of the operations
+		wantResults []int
+		wantLogged  bool
+		// artists there are after the batch
+		wantArtists []int
+	}{
+		{
+			name:  "atomic",
+			store: inmemory.NewDB,
+			batch: `{"atomic": true, "operations": [
+				{"action": "create", "resource": "artists", "artist": {"id": 2, "full_name": "Queen"}},
+				{"action": "update", "resource": "artists", "artist": {"id": 1, "full_name": "ABBA"}}
+			]}`,
+			wantStatus: http.StatusOK, wantResults: []int{http.StatusCreated, http.StatusOK}, wantArtists: []int{1, 2},
+		},
+		{
+			name:  "atomic with missing artist",
+			store: inmemory.NewDB,
+			batch: `{"atomic": true, "operations": [
+				{"action": "create", "resource": "artists", "artist": {"id": 2, "full_name": "Queen"}},
+				{"action": "delete", "resource": "artists", "id": 404}
+			]}`,
+			wantStatus: http.StatusUnprocessableEntity, wantResults: []int{http.StatusFailedDependency, http.StatusNotFound}, wantArtists: []int{1},
+		},
+		{
+			name:  "atomic with failed operation",
+			store: inmemory.NewDB,
+			batch: `{"atomic": true, "operations": [
+				{"action": "create", "resource": "artists", "artist": {"id": 1, "full_name": "Queen"}},
+				{"action": "create", "resource": "artists", "artist": {"id": 2, "full_name": "ABBA"}}
+			]}`,
+			wantStatus: http.StatusUnprocessableEntity, wantResults: []int{http.StatusInternalServerError, http.StatusFailedDependency}, wantArtists: []int{1},
+		},
+		{
+			name:  "atomic not committed",
+			store: func() store.Store { return uncommittableStore{inmemory.NewDB()} },
+			batch: `{"atomic": true, "operations": [
+				{"action": "create", "resource": "artists", "artist": {"id": 2, "full_name": "Queen"}}
+			]}`,
+			wantStatus: http.StatusInternalServerError, wantLogged: true,
+		},
+		{
+			name:  "not atomic",
+			store: inmemory.NewDB,
+			batch: `{"operations": [
+				{"action": "create", "resource": "artists", "artist": {"id": 2, "full_name": "Queen"}},
+				{"action": "update", "resource": "artists", "artist": {"id": 404, "full_name": "ABBA"}},
+				{"action": "create", "resource": "artists", "artist": {"id": 3}}
+			]}`,
+			wantStatus: http.StatusOK, wantResults: []int{http.StatusCreated, http.StatusNotFound, http.StatusUnprocessableEntity}, wantArtists: []int{1, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.ErrorLevel)
+			db := tt.store()
+			srv, _ := newTestServer(t, WithStore(db), WithLogger(zap.New(core)))
+
+			resp, err := http.Post(srv.URL+"/v1/artists", "application/json", strings.NewReader(`{"id": 1, "full_name": "The Beatles"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+
+			resp, err = http.Post(srv.URL+"/v1/batch", "application/json", strings.NewReader(tt.batch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := ioutil.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("got status %v, want %v: %s", resp.StatusCode, tt.wantStatus, body)
+			}
+			if tt.wantResults != nil {
+				response := new(batchResponse)
+				if err := json.Unmarshal(body, response); err != nil {
+					t.Fatal(err)
+				}
+				if len(response.Results) != len(tt.wantResults) {
+					t.Fatalf("got %v results, want %v", len(response.Results), len(tt.wantResults))
+				}
+				for i, result := range response.Results {
+					if result.Status != tt.wantResults[i] {
+						t.Errorf("operation %v: got status %v, want %v (%v)", i, result.Status, tt.wantResults[i], result.Error)
+					}
+				}
+			}
+			if logged := logs.FilterMessage("batch transaction failed").Len() > 0; logged != tt.wantLogged {
+				t.Errorf("got the transaction error logged %v, want %v", logged, tt.wantLogged)
+			}
+
+			if tt.wantArtists != nil {
+				artists, err := db.Artists().All(context.Background(), &models.Page{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				var ids []int
+				for _, artist := range artists {
+					ids = append(ids, artist.ID)
+				}
+				if len(ids) != len(tt.wantArtists) {
+					t.Fatalf("got artists %v, want %v", ids, tt.wantArtists)
+				}
+				for i := range ids {
+					if ids[i] != tt.wantArtists[i] {
+						t.Fatalf("got artists %v, want %v", ids, tt.wantArtists)
+					}
+				}
+			}
+		})
+	}
+}
+
+// getSongLyrics returns the lyrics of the song served by the API, i.e. the cached ones if there are any
+func getSongLyrics(t *testing.T, srv *httptest.Server, id int) string {
+	t.Helper()
+
+	resp, err := http.Get(fmt.Sprintf("%v/v1/songs/%v", srv.URL, id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	song := new(models.Song)
+	if err := json.NewDecoder(resp.Body).Decode(song); err != nil {
+		t.Fatal(err)
+	}
+	return song.Lyrics
+}
+
+func TestBatchFetchesLyrics(t *testing.T) {
+	// every search waits for its turn, so that the lyrics of the songs are fetched one at a time
+	release := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws/1.1/track.search", func(rw http.ResponseWriter, r *http.Request) {
+		<-release
+		_, _ = fmt.Fprint(rw, trackFound)
+	})
+	mux.HandleFunc("/ws/1.1/track.lyrics.get", func(rw http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(rw, lyricsFound)
+	})
+	musixmatch := httptest.NewServer(mux)
+	defer musixmatch.Close()
+	var releaseAll sync.Once
+	// the searches still waiting don't keep the fake from closing when the test fails
+	defer releaseAll.Do(func() { close(release) })
+
+	db := inmemory.NewDB()
+	if err := db.Artists().Create(context.Background(), &models.Artist{ID: 1, FullName: "ABBA"}); err != nil {
+		t.Fatal(err)
+	}
+	jobs := shutdown.NewManager(time.Second, zap.NewNop())
+	srv, _ := newTestServer(t,
+		WithStore(db),
+		WithShutdownManager(jobs),
+		WithLyricsAPI(LyricsAPI{RootURL: musixmatch.URL + "/ws/1.1", Client: musixmatch.Client()}),
+	)
+
+	resp, err := http.Post(srv.URL+"/v1/batch", "application/json", strings.NewReader(`{"operations": [
+		{"action": "create", "resource": "songs", "song": {"id": 1, "title": "Waterloo", "artist_id": 1, "lyrics": "la la la"}},
+		{"action": "create", "resource": "songs", "song": {"id": 2, "title": "Waterloo", "artist_id": 1}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+
+	// the created songs tell their lyrics are being fetched, the responses are cached
+	for _, id := range []int{1, 2} {
+		if lyrics := getSongLyrics(t, srv, id); lyrics != models.LyricsStatusFetching {
+			t.Errorf("song %v: got lyrics %q, want %q", id, lyrics, models.LyricsStatusFetching)
+		}
+	}
+
+	// the lyrics of the first song are served while the ones of the second are still being fetched
+	release <- struct{}{}
+	deadline := time.Now().Add(2 * time.Second)
+	for getSongLyrics(t, srv, 1) != "My, my, at Waterloo Napoleon did surrender" {
+		if time.Now().After(deadline) {
+			t.Fatalf("got lyrics %q of the first song", getSongLyrics(t, srv, 1))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if lyrics := getSongLyrics(t, srv, 2); lyrics != models.LyricsStatusFetching {
+		t.Errorf("got lyrics %q of the second song, want %q", lyrics, models.LyricsStatusFetching)
+	}
+
+	releaseAll.Do(func() { close(release) })
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelDrain()
+	if err := jobs.Drain(drainCtx); err != nil {
+		t.Fatal(err)
+	}
+	if lyrics := getSongLyrics(t, srv, 2); lyrics != "My, my, at Waterloo Napoleon did surrender" {
+		t.Errorf("got lyrics %q of the second song", lyrics)
+	}
+}
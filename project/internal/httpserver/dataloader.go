@@ -0,0 +1,119 @@
+package httpserver
+
+import (
+	"context"
+	"example/hello/project/internal/cache"
+	"example/hello/project/internal/models"
+	"example/hello/project/internal/store"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// how long the loader waits for other keys before hitting the store
+	artistLoaderWait = time.Millisecond * 2
+	// the batch is dispatched right away once it has this many keys
+	artistLoaderMaxBatch = 100
+	// the batch is shared by all the callers, so it doesn't end with the context of the first one
+	artistLoaderTimeout = 5 * time.Second
+)
+
+type artistLoaderCtxKey struct{}
+
+// artistLoader batches the lookups of artists made within a short window of time into one
+// store call and memoizes the results, so that resolving the artist of every song in a list
+// doesn't turn into N+1 queries. It lives for the duration of a single request.
+type artistLoader struct {
+	fetch func(ctx context.Context, ids []int) ([]*models.Artist, error)
+
+	mu    sync.Mutex
+	batch *artistBatch
+	// finished batches by artist id
+	loaded map[int]*artistBatch
+}
+
+type artistBatch struct {
+	ids     []int
+	full    chan struct{}
+	done    chan struct{}
+	artists map[int]*models.Artist
+	err     error
+}
+
+func newArtistLoader(artists store.ArtistsRepository) *artistLoader {
+	return &artistLoader{
+		fetch:  artists.ByIDs,
+		loaded: make(map[int]*artistBatch),
+	}
+}
+
+// withArtistLoader puts a fresh loader into the context of every request
+func withArtistLoader(store store.Store) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), artistLoaderCtxKey{}, newArtistLoader(store.Artists()))
+			next.ServeHTTP(rw, r.WithContext(ctx))
+		})
+	}
+}
+
+func artistLoaderFromContext(ctx context.Context) (*artistLoader, bool) {
+	loader, ok := ctx.Value(artistLoaderCtxKey{}).(*artistLoader)
+	return loader, ok
+}
+
+// Load returns the artist with the given id or nil if it doesn't exist
+func (l *artistLoader) Load(ctx context.Context, id int) (*models.Artist, error) {
+	l.mu.Lock()
+	batch, ok := l.loaded[id]
+	if !ok {
+		if l.batch == nil {
+			l.batch = &artistBatch{
+				full:    make(chan struct{}),
+				done:    make(chan struct{}),
+				artists: make(map[int]*models.Artist),
+			}
+			go l.dispatch(cache.Detach(ctx), l.batch)
+		}
+		batch = l.batch
+		batch.ids = append(batch.ids, id)
+		l.loaded[id] = batch
+
+		if len(batch.ids) >= artistLoaderMaxBatch {
+			// detaching the batch, so that the following keys go to the new one
+			l.batch = nil
+			close(batch.full)
+		}
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-batch.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return batch.artists[id], batch.err
+}
+
+func (l *artistLoader) dispatch(ctx context.Context, batch *artistBatch) {
+	select {
+	case <-batch.full:
+	case <-time.After(artistLoaderWait):
+		l.mu.Lock()
+		if l.batch == batch {
+			l.batch = nil
+		}
+		l.mu.Unlock()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, artistLoaderTimeout)
+	defer cancel()
+
+	artists, err := l.fetch(ctx, batch.ids)
+	for _, artist := range artists {
+		batch.artists[artist.ID] = artist
+	}
+	batch.err = err
+	close(batch.done)
+}
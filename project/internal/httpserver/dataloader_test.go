@@ -0,0 +1,105 @@
+package httpserver
+
+import (
+	"context"
+	"example/hello/project/internal/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestArtistLoaderBatchesLoads(t *testing.T) {
+	var mu sync.Mutex
+	var calls [][]int
+	loader := &artistLoader{
+		fetch: func(ctx context.Context, ids []int) ([]*models.Artist, error) {
+			mu.Lock()
+			calls = append(calls, ids)
+			mu.Unlock()
+
+			artists := make([]*models.Artist, 0, len(ids))
+			for _, id := range ids {
+				if id != 404 {
+					artists = append(artists, &models.Artist{ID: id})
+				}
+			}
+			return artists, nil
+		},
+		loaded: make(map[int]*artistBatch),
+	}
+
+	var wg sync.WaitGroup
+	for _, id := range []int{1, 2, 1, 404} {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+
+			artist, err := loader.Load(context.Background(), id)
+			switch {
+			case err != nil:
+				t.Errorf("artist %v: %v", id, err)
+			case id == 404 && artist != nil:
+				t.Errorf("got artist %v, want nil", artist.ID)
+			case id != 404 && (artist == nil || artist.ID != id):
+				t.Errorf("got artist %+v, want %v", artist, id)
+			}
+		}(id)
+	}
+	wg.Wait()
+
+	if len(calls) != 1 || len(calls[0]) != 3 {
+		t.Errorf("got store calls %v, want one with the distinct ids", calls)
+	}
+}
+
+// the batch is shared, so the caller which started it going away doesn't fail the others
+func TestArtistLoaderDetachesBatch(t *testing.T) {
+	fetched := make(chan struct{})
+	loader := &artistLoader{
+		fetch: func(ctx context.Context, ids []int) ([]*models.Artist, error) {
+			<-fetched
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if _, ok := ctx.Deadline(); !ok {
+				t.Error("the batch has no timeout")
+			}
+
+			artists := make([]*models.Artist, 0, len(ids))
+			for _, id := range ids {
+				artists = append(artists, &models.Artist{ID: id})
+			}
+			return artists, nil
+		},
+		loaded: make(map[int]*artistBatch),
+	}
+
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error)
+	go func() {
+		_, err := loader.Load(first, 1)
+		firstErr <- err
+	}()
+
+	// the second caller joins the batch of the first one
+	time.Sleep(artistLoaderWait / 4)
+	secondDone := make(chan *models.Artist)
+	go func() {
+		artist, err := loader.Load(context.Background(), 2)
+		if err != nil {
+			t.Errorf("second caller: %v", err)
+		}
+		secondDone <- artist
+	}()
+
+	time.Sleep(artistLoaderWait * 2)
+	cancel()
+	if err := <-firstErr; err != context.Canceled {
+		t.Errorf("got %v for the first caller, want %v", err, context.Canceled)
+	}
+	close(fetched)
+
+	if artist := <-secondDone; artist == nil || artist.ID != 2 {
+		t.Errorf("got artist %+v for the second caller", artist)
+	}
+}
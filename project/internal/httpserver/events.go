@@ -0,0 +1,180 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"example/hello/project/internal/logger"
+	"example/hello/project/internal/message_broker"
+	"example/hello/project/internal/models"
+	"fmt"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// the stream is ended before the server's WriteTimeout kicks in, browsers reconnect on their own
+	// (with Last-Event-ID header, so that the missed events are replayed)
+	sseMaxDuration = time.Second * 25
+	sseRetry       = time.Second
+	// comments are sent periodically to keep the idle connection from being closed by proxies
+	sseHeartbeat = time.Second * 10
+)
+
+// EventsResource streams catalog changes made on every peer over Server-Sent Events and WebSocket
+type EventsResource struct {
+	// streams are ended once the server's context is done, otherwise they'd hold up the shutdown
+	ctx    context.Context
+	broker message_broker.MessageBroker
+	logger *zap.Logger
+}
+
+func NewEventsResource(ctx context.Context, broker message_broker.MessageBroker, logger *zap.Logger) *EventsResource {
+	return &EventsResource{
+		ctx:    ctx,
+		broker: broker,
+		logger: logger,
+	}
+}
+
+func (er *EventsResource) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	// both accept optional ?types=song.created,artist.deleted filter
+	r.Get("/", er.ServerSentEvents)
+	r.Handle("/ws", websocket.Handler(er.WebSocket))
+
+	return r
+}
+
+// eventFilter returns whether the event is of one of the types listed in ?types= query parameter
+func eventFilter(r *http.Request) func(event *models.Event) bool {
+	types := make(map[models.EventType]bool)
+	for _, eventType := range strings.Split(r.URL.Query().Get("types"), ",") {
+		if eventType = strings.TrimSpace(eventType); eventType != "" {
+			types[models.EventType(eventType)] = true
+		}
+	}
+
+	return func(event *models.Event) bool {
+		return len(types) == 0 || types[event.Type]
+	}
+}
+
+func (er *EventsResource) ServerSentEvents(rw http.ResponseWriter, r *http.Request) {
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		rw.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprint(rw, "Streaming is not supported")
+		return
+	}
+
+	// the ID of the last received event is sent by browsers on reconnection
+	afterSeq, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+	matches := eventFilter(r)
+
+	events, cancel := er.broker.Events().Subscribe(afterSeq)
+	defer cancel()
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Header().Set("Connection", "keep-alive")
+	rw.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprintf(rw, "retry: %d\n\n", sseRetry.Milliseconds())
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	deadline := time.NewTimer(sseMaxDuration)
+	defer deadline.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-er.ctx.Done():
+			return
+		case <-deadline.C:
+			return
+		case <-heartbeat.C:
+			_, _ = fmt.Fprint(rw, ": heartbeat\n\n")
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if !matches(event) {
+				continue
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				logger.FromContext(r.Context(), er.logger).Error("failed to marshal event", zap.Error(err))
+				continue
+			}
+			_, _ = fmt.Fprintf(rw, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, data)
+		}
+		flusher.Flush()
+	}
+}
+
+func (er *EventsResource) WebSocket(ws *websocket.Conn) {
+	defer func() {
+		_ = ws.Close()
+	}()
+
+	// the connection is hijacked, so the deadlines set by the server (ReadTimeout/WriteTimeout) are lifted
+	if err := ws.SetDeadline(time.Time{}); err != nil {
+		return
+	}
+
+	r := ws.Request()
+	matches := eventFilter(r)
+
+	events, cancel := er.broker.Events().Subscribe(0)
+	defer cancel()
+
+	// the client isn't expected to send anything; reading is only needed to notice that it's gone
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		var discarded string
+		for {
+			if err := websocket.Message.Receive(ws, &discarded); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-er.ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if !matches(event) {
+				continue
+			}
+
+			if err := websocket.JSON.Send(ws, event); err != nil {
+				logger.FromContext(r.Context(), er.logger).Debug("websocket client is gone", zap.Error(err))
+				return
+			}
+		}
+	}
+}
+
+// publishEvent notifies every peer about the change. Failing to do so doesn't fail the request,
+// since the change itself has already been made.
+func publishEvent(ctx context.Context, broker message_broker.MessageBroker, log *zap.Logger, event *models.Event) {
+	if err := broker.Events().Publish(ctx, event); err != nil {
+		logger.FromContext(ctx, log).Warn("failed to publish event", zap.String("type", string(event.Type)), zap.Error(err))
+	}
+}
@@ -0,0 +1,297 @@
+// GraphQL server for Go: https://github.com/graph-gophers/graphql-go
+
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"example/hello/project/internal/models"
+	"fmt"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+	"net/http"
+)
+
+const graphqlSchema = `
+schema {
+	query: Query
+	mutation: Mutation
+}
+
+type Query {
+	# songs with title containing the query (case insensitive) and/or of the given artist
+	# both lists are ordered by ID and paginated the same way as REST endpoints
+	songs(query: String, artistID: Int, limit: Int = 10, offset: Int = 0): [Song!]!
+	song(id: Int!): Song
+	artists(limit: Int = 10, offset: Int = 0): [Artist!]!
+	artist(id: Int!): Artist
+}
+
+type Mutation {
+	# creates the song and fetches its lyrics
+	createSong(input: SongInput!): Song!
+	updateSong(input: SongInput!): Song!
+	deleteSong(id: Int!): Boolean!
+	createArtist(input: ArtistInput!): Artist!
+	updateArtist(input: ArtistInput!): Artist!
+	deleteArtist(id: Int!): Boolean!
+}
+
+type Song {
+	id: Int!
+	title: String!
+	artistID: Int!
+	artist: Artist
+	lyrics: String!
+	albumName: String!
+}
+
+type Artist {
+	id: Int!
+	fullName: String!
+}
+
+input SongInput {
+	id: Int!
+	title: String!
+	artistID: Int!
+}
+
+input ArtistInput {
+	id: Int!
+	fullName: String!
+}
+`
+
+type GraphQLResource struct {
+	songs   *SongResource
+	artists *ArtistResource
+}
+
+func NewGraphQLResource(songs *SongResource, artists *ArtistResource) *GraphQLResource {
+	return &GraphQLResource{
+		songs:   songs,
+		artists: artists,
+	}
+}
+
+// Handler serves POST requests with {"query": "...", "variables": {...}} body
+func (gr *GraphQLResource) Handler() http.Handler {
+	schema := graphql.MustParseSchema(graphqlSchema, &graphqlResolver{gr: gr})
+
+	return withArtistLoader(gr.artists.store)(&relay.Handler{Schema: schema})
+}
+
+type graphqlResolver struct {
+	gr *GraphQLResource
+}
+
+func graphqlPage(limit, offset int32) (models.Page, error) {
+	if limit < 1 || limit > maxPageSize {
+		return models.Page{}, fmt.Errorf("limit must be a number between 1 and %v", maxPageSize)
+	}
+	if offset < 0 {
+		return models.Page{}, errors.New("offset must be a non-negative number")
+	}
+
+	return models.Page{Limit: int(limit), Offset: int(offset)}, nil
+}
+
+func (q *graphqlResolver) Songs(ctx context.Context, args struct {
+	Query    *string
+	ArtistID *int32
+	Limit    int32
+	Offset   int32
+}) ([]*songResolver, error) {
+	page, err := graphqlPage(args.Limit, args.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := &models.Filter{Query: args.Query, Page: page}
+	if args.ArtistID != nil {
+		artistID := int(*args.ArtistID)
+		filter.ArtistID = &artistID
+	}
+
+	songs, err := q.gr.songs.store.Songs().All(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := make([]*songResolver, 0, len(songs))
+	for _, song := range songs {
+		resolvers = append(resolvers, &songResolver{song: song})
+	}
+	return resolvers, nil
+}
+
+func (q *graphqlResolver) Song(ctx context.Context, args struct{ ID int32 }) (*songResolver, error) {
+	song, err := q.gr.songs.store.Songs().ByID(ctx, int(args.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	return &songResolver{song: song}, nil
+}
+
+func (q *graphqlResolver) Artists(ctx context.Context, args struct {
+	Limit  int32
+	Offset int32
+}) ([]*artistResolver, error) {
+	page, err := graphqlPage(args.Limit, args.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	artists, err := q.gr.artists.store.Artists().All(ctx, &page)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := make([]*artistResolver, 0, len(artists))
+	for _, artist := range artists {
+		resolvers = append(resolvers, &artistResolver{artist: artist})
+	}
+	return resolvers, nil
+}
+
+func (q *graphqlResolver) Artist(ctx context.Context, args struct{ ID int32 }) (*artistResolver, error) {
+	artist, err := q.gr.artists.store.Artists().ByID(ctx, int(args.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	return &artistResolver{artist: artist}, nil
+}
+
+type songInput struct {
+	ID       int32
+	Title    string
+	ArtistID int32
+}
+
+func (in songInput) toModel() *models.Song {
+	return &models.Song{
+		ID:       int(in.ID),
+		Title:    in.Title,
+		ArtistID: int(in.ArtistID),
+	}
+}
+
+type artistInput struct {
+	ID       int32
+	FullName string
+}
+
+func (in artistInput) toModel() *models.Artist {
+	return &models.Artist{
+		ID:       int(in.ID),
+		FullName: in.FullName,
+	}
+}
+
+// the mutations below share the steps with the corresponding REST handlers
+
+func (q *graphqlResolver) CreateSong(ctx context.Context, args struct{ Input songInput }) (*songResolver, error) {
+	song := args.Input.toModel()
+	if err := q.gr.songs.createSong(ctx, song); err != nil {
+		return nil, err
+	}
+
+	return &songResolver{song: song}, nil
+}
+
+func (q *graphqlResolver) UpdateSong(ctx context.Context, args struct{ Input songInput }) (*songResolver, error) {
+	song := args.Input.toModel()
+	if err := q.gr.songs.updateSong(ctx, song); err != nil {
+		return nil, err
+	}
+
+	return &songResolver{song: song}, nil
+}
+
+func (q *graphqlResolver) DeleteSong(ctx context.Context, args struct{ ID int32 }) (bool, error) {
+	if err := q.gr.songs.deleteSong(ctx, int(args.ID)); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (q *graphqlResolver) CreateArtist(ctx context.Context, args struct{ Input artistInput }) (*artistResolver, error) {
+	artist := args.Input.toModel()
+	if err := q.gr.artists.createArtist(ctx, artist); err != nil {
+		return nil, err
+	}
+
+	return &artistResolver{artist: artist}, nil
+}
+
+func (q *graphqlResolver) UpdateArtist(ctx context.Context, args struct{ Input artistInput }) (*artistResolver, error) {
+	artist := args.Input.toModel()
+	if err := q.gr.artists.updateArtist(ctx, artist); err != nil {
+		return nil, err
+	}
+
+	return &artistResolver{artist: artist}, nil
+}
+
+func (q *graphqlResolver) DeleteArtist(ctx context.Context, args struct{ ID int32 }) (bool, error) {
+	if err := q.gr.artists.deleteArtist(ctx, int(args.ID)); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+type songResolver struct {
+	song *models.Song
+}
+
+func (s *songResolver) ID() int32 {
+	return int32(s.song.ID)
+}
+
+func (s *songResolver) Title() string {
+	return s.song.Title
+}
+
+func (s *songResolver) ArtistID() int32 {
+	return int32(s.song.ArtistID)
+}
+
+// Artist goes through the per-request loader, so that artists of all the songs are fetched at once
+func (s *songResolver) Artist(ctx context.Context) (*artistResolver, error) {
+	loader, ok := artistLoaderFromContext(ctx)
+	if !ok {
+		return nil, errors.New("artist loader is missing in the request context")
+	}
+
+	artist, err := loader.Load(ctx, s.song.ArtistID)
+	if err != nil || artist == nil {
+		return nil, err
+	}
+
+	return &artistResolver{artist: artist}, nil
+}
+
+func (s *songResolver) Lyrics() string {
+	return s.song.Lyrics
+}
+
+func (s *songResolver) AlbumName() string {
+	return s.song.AlbumName
+}
+
+type artistResolver struct {
+	artist *models.Artist
+}
+
+func (a *artistResolver) ID() int32 {
+	return int32(a.artist.ID)
+}
+
+func (a *artistResolver) FullName() string {
+	return a.artist.FullName
+}
@@ -0,0 +1,123 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"example/hello/project/internal/models"
+	"example/hello/project/internal/shutdown"
+	"example/hello/project/internal/store/inmemory"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type graphqlResponse struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func postGraphQL(t *testing.T, url, query string) *graphqlResponse {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]string{"query": query})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(url+"/graphql", "application/json", strings.NewReader(string(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	response := new(graphqlResponse)
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+// the mutations go through the same steps as REST, including the lyrics saga in background
+func TestGraphQLMutations(t *testing.T) {
+	tests := []struct {
+		name     string
+		mutation string
+		// the error the mutation fails with, if any
+		wantError string
+		// lyrics of the song 1 once the background jobs are done, empty when it doesn't exist
+		wantLyrics string
+	}{
+		{
+			name:       "create song",
+			mutation:   `mutation { createSong(input: {id: 1, title: "Waterloo", artistID: 1}) { id lyrics } }`,
+			wantLyrics: "My, my, at Waterloo Napoleon did surrender",
+		},
+		{
+			name:      "create invalid song",
+			mutation:  `mutation { createSong(input: {id: 1, title: "", artistID: 1}) { id } }`,
+			wantError: "title: cannot be blank.",
+		},
+		{
+			name:      "delete missing song",
+			mutation:  `mutation { deleteSong(id: 404) }`,
+			wantError: "not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			musixmatch := newFakeMusixmatch(t, trackFound, lyricsFound)
+			db := inmemory.NewDB()
+			if err := db.Artists().Create(context.Background(), &models.Artist{ID: 1, FullName: "ABBA"}); err != nil {
+				t.Fatal(err)
+			}
+			jobs := shutdown.NewManager(time.Second, zap.NewNop())
+			srv, _ := newTestServer(t,
+				WithStore(db),
+				WithShutdownManager(jobs),
+				WithLyricsAPI(LyricsAPI{RootURL: musixmatch.URL + "/ws/1.1", Client: musixmatch.Client()}),
+			)
+
+			response := postGraphQL(t, srv.URL, tt.mutation)
+			switch {
+			case tt.wantError == "" && len(response.Errors) > 0:
+				t.Fatalf("got errors %+v", response.Errors)
+			case tt.wantError != "" && (len(response.Errors) != 1 || response.Errors[0].Message != tt.wantError):
+				t.Fatalf("got errors %+v, want %q", response.Errors, tt.wantError)
+			}
+			// the response doesn't wait for the lyrics
+			if created, ok := response.Data["createSong"]; ok && tt.wantError == "" {
+				var song struct{ Lyrics string }
+				if err := json.Unmarshal(created, &song); err != nil {
+					t.Fatal(err)
+				}
+				if song.Lyrics != models.LyricsStatusFetching {
+					t.Errorf("got lyrics %q in the response, want %q", song.Lyrics, models.LyricsStatusFetching)
+				}
+			}
+
+			drainCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := jobs.Drain(drainCtx); err != nil {
+				t.Fatal(err)
+			}
+
+			song, err := db.Songs().ByID(context.Background(), 1)
+			if tt.wantLyrics == "" {
+				if err == nil {
+					t.Errorf("got song %+v, want none", song)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if song.Lyrics != tt.wantLyrics {
+				t.Errorf("got lyrics %q, want %q", song.Lyrics, tt.wantLyrics)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package httpserver
+
+import (
+	"context"
+	"example/hello/project/internal/message_broker"
+	"example/hello/project/internal/store"
+	"github.com/go-chi/render"
+	"net/http"
+	"time"
+)
+
+const (
+	healthStatusUp   = "up"
+	healthStatusDown = "down"
+
+	// readiness checks shouldn't hang longer than orchestrator's probe timeout
+	readinessTimeout = time.Second * 3
+)
+
+type HealthResource struct {
+	store  store.Store
+	broker message_broker.MessageBroker
+}
+
+type dependencyStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type healthResponse struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]dependencyStatus `json:"dependencies,omitempty"`
+}
+
+func NewHealthResource(store store.Store, broker message_broker.MessageBroker) *HealthResource {
+	return &HealthResource{
+		store:  store,
+		broker: broker,
+	}
+}
+
+// Liveness only tells that the process is up and able to serve HTTP requests
+func (hr *HealthResource) Liveness(rw http.ResponseWriter, r *http.Request) {
+	render.JSON(rw, r, healthResponse{Status: healthStatusUp})
+}
+
+// Readiness checks every dependency and responds with 503 if any of them is down,
+// so that the traffic isn't routed to this peer
+func (hr *HealthResource) Readiness(rw http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := map[string]func(ctx context.Context) error{
+		"store":  hr.store.Ping,
+		"broker": hr.broker.Ping,
+	}
+
+	response := healthResponse{
+		Status:       healthStatusUp,
+		Dependencies: make(map[string]dependencyStatus, len(checks)),
+	}
+	for name, check := range checks {
+		if err := check(ctx); err != nil {
+			response.Status = healthStatusDown
+			response.Dependencies[name] = dependencyStatus{Status: healthStatusDown, Error: err.Error()}
+			continue
+		}
+		response.Dependencies[name] = dependencyStatus{Status: healthStatusUp}
+	}
+
+	if response.Status != healthStatusUp {
+		render.Status(r, http.StatusServiceUnavailable)
+	}
+	render.JSON(rw, r, response)
+}
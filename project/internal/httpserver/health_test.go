@@ -0,0 +1,104 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"example/hello/project/internal/cache"
+	"example/hello/project/internal/message_broker"
+	inmemorybroker "example/hello/project/internal/message_broker/inmemory"
+	"example/hello/project/internal/store"
+	"example/hello/project/internal/store/inmemory"
+	"go.uber.org/zap"
+	"net/http"
+	"testing"
+)
+
+// unreachableStore is the store which can't be pinged, e.g. MongoDB is down
+type unreachableStore struct {
+	store.Store
+}
+
+func (s unreachableStore) Ping(ctx context.Context) error {
+	return errors.New("server selection timeout")
+}
+
+// unreachableBroker is the broker which can't be pinged, e.g. Kafka is down
+type unreachableBroker struct {
+	message_broker.MessageBroker
+}
+
+func (b unreachableBroker) Ping(ctx context.Context) error {
+	return errors.New("producer can't reach brokers")
+}
+
+func TestHealth(t *testing.T) {
+	tests := []struct {
+		name       string
+		store      store.Store
+		brokerDown bool
+		path       string
+		wantStatus int
+		want       healthResponse
+	}{
+		{
+			"ready", inmemory.NewDB(), false, "/readyz", http.StatusOK,
+			healthResponse{Status: healthStatusUp, Dependencies: map[string]dependencyStatus{
+				"store":  {Status: healthStatusUp},
+				"broker": {Status: healthStatusUp},
+			}},
+		},
+		{
+			"store is down", unreachableStore{inmemory.NewDB()}, false, "/readyz", http.StatusServiceUnavailable,
+			healthResponse{Status: healthStatusDown, Dependencies: map[string]dependencyStatus{
+				"store":  {Status: healthStatusDown, Error: "server selection timeout"},
+				"broker": {Status: healthStatusUp},
+			}},
+		},
+		{
+			"broker is down", inmemory.NewDB(), true, "/readyz", http.StatusServiceUnavailable,
+			healthResponse{Status: healthStatusDown, Dependencies: map[string]dependencyStatus{
+				"store":  {Status: healthStatusUp},
+				"broker": {Status: healthStatusDown, Error: "producer can't reach brokers"},
+			}},
+		},
+		// the peer is alive, even though it can't serve the traffic
+		{
+			"alive with store down", unreachableStore{inmemory.NewDB()}, true, "/healthz", http.StatusOK,
+			healthResponse{Status: healthStatusUp},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []ServerOption{WithStore(tt.store)}
+			if tt.brokerDown {
+				broker := inmemorybroker.NewBroker(cache.NewTagged(cache.Noop{}), "peer0", nil, zap.NewNop())
+				opts = append(opts, WithBroker(unreachableBroker{broker}))
+			}
+			srv, _ := newTestServer(t, opts...)
+
+			resp, err := http.Get(srv.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("got status %v, want %v", resp.StatusCode, tt.wantStatus)
+			}
+			var got healthResponse
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.want.Status || len(got.Dependencies) != len(tt.want.Dependencies) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			for name, want := range tt.want.Dependencies {
+				if got.Dependencies[name] != want {
+					t.Errorf("%v: got %+v, want %+v", name, got.Dependencies[name], want)
+				}
+			}
+		})
+	}
+}
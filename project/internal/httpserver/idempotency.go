@@ -0,0 +1,150 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"example/hello/project/internal/logger"
+	"example/hello/project/internal/models"
+	"example/hello/project/internal/store"
+	"fmt"
+	"github.com/go-chi/chi/middleware"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	// set on the replayed responses
+	idempotentReplayedHeader = "Idempotent-Replayed"
+
+	defaultIdempotencyWindow = time.Hour * 24
+	// the request is considered lost if it hasn't been completed in time (e.g. the peer has died),
+	// a retry with the same key is processed then. It's well beyond WriteTimeout of the server
+	idempotencyLease = time.Minute
+	// the response is saved even if the client has gone away, that's the whole point
+	idempotencySaveTimeout = time.Second * 5
+	// the body is read in full to be hashed, the largest batch is far below that
+	maxIdempotentBodySize = 1 << 20
+)
+
+// idempotent replays the first response to POST requests with the same Idempotency-Key header,
+// so that retrying a request which has timed out doesn't create the song (and run the lyrics saga) twice.
+// The records are kept in the store, so the duplicates are caught by any peer.
+// The keys are scoped by the credentials, so that the clients choosing the same key don't get each other's responses.
+// https://datatracker.ietf.org/doc/draft-ietf-httpapi-idempotency-key-header/
+func idempotent(store store.Store, window time.Duration, l *zap.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(idempotencyKeyHeader)
+			if r.Method != http.MethodPost || key == "" {
+				next.ServeHTTP(rw, r)
+				return
+			}
+
+			body, err := ioutil.ReadAll(http.MaxBytesReader(rw, r.Body, maxIdempotentBodySize))
+			if err != nil {
+				// the reader has stopped at the limit
+				status := http.StatusBadRequest
+				if len(body) >= maxIdempotentBodySize {
+					status = http.StatusRequestEntityTooLarge
+				}
+				rw.WriteHeader(status)
+				_, _ = fmt.Fprintf(rw, "Unknown err: %v", err)
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			now := time.Now().UTC()
+			record := &models.IdempotencyRecord{
+				Key:         credentialsScope(r) + " " + key,
+				RequestHash: requestHash(r, body),
+				CreatedAt:   now,
+				ExpiresAt:   now.Add(window),
+				LockedUntil: now.Add(idempotencyLease),
+			}
+
+			existing, err := store.Idempotency().Reserve(r.Context(), record)
+			if err != nil {
+				rw.WriteHeader(http.StatusInternalServerError)
+				_, _ = fmt.Fprintf(rw, "DB err: %v", err)
+				return
+			}
+
+			if existing != nil {
+				switch {
+				case existing.RequestHash != record.RequestHash:
+					rw.WriteHeader(http.StatusUnprocessableEntity)
+					_, _ = fmt.Fprintf(rw, "%v has already been used for a different request", idempotencyKeyHeader)
+				case !existing.Completed:
+					rw.WriteHeader(http.StatusConflict)
+					_, _ = fmt.Fprintf(rw, "A request with the same %v is still being processed", idempotencyKeyHeader)
+				default:
+					if existing.ContentType != "" {
+						rw.Header().Set("Content-Type", existing.ContentType)
+					}
+					rw.Header().Set(idempotentReplayedHeader, "true")
+					rw.WriteHeader(existing.StatusCode)
+					_, _ = rw.Write(existing.Body)
+				}
+				return
+			}
+
+			reqLogger := logger.FromContext(r.Context(), l).With(zap.String("idempotency_key", key))
+			release := func() {
+				ctx, cancel := context.WithTimeout(context.Background(), idempotencySaveTimeout)
+				defer cancel()
+
+				if err := store.Idempotency().Release(ctx, record.Key); err != nil {
+					reqLogger.Error("failed to release idempotency key", zap.Error(err))
+				}
+			}
+			// the panicking handler doesn't leave the key reserved, the panic goes on to the recoverer
+			defer func() {
+				if p := recover(); p != nil {
+					release()
+					panic(p)
+				}
+			}()
+
+			var response bytes.Buffer
+			ww := middleware.NewWrapResponseWriter(rw, r.ProtoMajor)
+			ww.Tee(&response)
+
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			// server errors aren't saved, so that the request could be retried with the same key
+			if status >= 500 {
+				release()
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), idempotencySaveTimeout)
+			defer cancel()
+
+			record.StatusCode = status
+			record.ContentType = ww.Header().Get("Content-Type")
+			record.Body = response.Bytes()
+			if err := store.Idempotency().Complete(ctx, record); err != nil {
+				reqLogger.Error("failed to save idempotent response", zap.Error(err))
+			}
+		})
+	}
+}
+
+// requestHash identifies the request the key has been used for
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%v %v\n", r.Method, r.URL.Path)
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
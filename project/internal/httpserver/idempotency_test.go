@@ -0,0 +1,196 @@
+package httpserver
+
+import (
+	"context"
+	"example/hello/project/internal/models"
+	"example/hello/project/internal/store/inmemory"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdempotent(t *testing.T) {
+	db := inmemory.NewDB()
+	var calls int64
+	handler := recoverer(idempotent(db, time.Hour, zap.NewNop())(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&calls, 1)
+		switch r.URL.Path {
+		case "/panic":
+			panic("test")
+		case "/fail":
+			rw.WriteHeader(http.StatusInternalServerError)
+		default:
+			rw.Header().Set("Content-Type", "text/plain")
+			rw.WriteHeader(http.StatusCreated)
+			_, _ = fmt.Fprintf(rw, "created #%v", n)
+		}
+	})))
+
+	post := func(path, key, auth, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		r.Header.Set(idempotencyKeyHeader, key)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+	reserve := func(key, path, body string, lockedUntil time.Time) {
+		now := time.Now().UTC()
+		r := httptest.NewRequest(http.MethodPost, path, nil)
+		record := &models.IdempotencyRecord{
+			Key:         credentialsScope(r) + " " + key,
+			RequestHash: requestHash(r, []byte(body)),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(time.Hour),
+			LockedUntil: lockedUntil,
+		}
+		if _, err := db.Idempotency().Reserve(context.Background(), record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		setup        func()
+		path         string
+		key          string
+		auth         string
+		body         string
+		wantStatus   int
+		wantBody     string
+		wantReplayed bool
+		wantCalls    int64
+	}{
+		{
+			name:       "first request",
+			path:       "/songs",
+			key:        "first",
+			body:       "song",
+			wantStatus: http.StatusCreated, wantBody: "created #1", wantCalls: 1,
+		},
+		{
+			name:       "replayed",
+			setup:      func() { post("/songs", "replayed", "", "song") },
+			path:       "/songs",
+			key:        "replayed",
+			body:       "song",
+			wantStatus: http.StatusCreated, wantBody: "created #1", wantReplayed: true, wantCalls: 1,
+		},
+		{
+			name:       "different request",
+			setup:      func() { post("/songs", "different", "", "song") },
+			path:       "/songs",
+			key:        "different",
+			body:       "another song",
+			wantStatus: http.StatusUnprocessableEntity, wantCalls: 1,
+		},
+		{
+			name:       "replayed to the same client",
+			setup:      func() { post("/songs", "same client", "Bearer alice", "song") },
+			path:       "/songs",
+			key:        "same client",
+			auth:       "Bearer alice",
+			body:       "song",
+			wantStatus: http.StatusCreated, wantBody: "created #1", wantReplayed: true, wantCalls: 1,
+		},
+		{
+			name:       "another client",
+			setup:      func() { post("/songs", "another client", "Bearer alice", "song") },
+			path:       "/songs",
+			key:        "another client",
+			auth:       "Bearer bob",
+			body:       "song",
+			wantStatus: http.StatusCreated, wantBody: "created #2", wantCalls: 2,
+		},
+		{
+			name:       "anonymous client",
+			setup:      func() { post("/songs", "anonymous client", "Bearer alice", "song") },
+			path:       "/songs",
+			key:        "anonymous client",
+			body:       "song",
+			wantStatus: http.StatusCreated, wantBody: "created #2", wantCalls: 2,
+		},
+		{
+			name:       "too large body",
+			path:       "/songs",
+			key:        "too large",
+			body:       strings.Repeat("a", maxIdempotentBodySize+1),
+			wantStatus: http.StatusRequestEntityTooLarge, wantCalls: 0,
+		},
+		{
+			name:       "still processed",
+			setup:      func() { reserve("processed", "/songs", "song", time.Now().Add(time.Minute)) },
+			path:       "/songs",
+			key:        "processed",
+			body:       "song",
+			wantStatus: http.StatusConflict, wantCalls: 0,
+		},
+		{
+			name:       "lapsed reservation is taken over",
+			setup:      func() { reserve("lapsed", "/songs", "song", time.Now().Add(-time.Second)) },
+			path:       "/songs",
+			key:        "lapsed",
+			body:       "song",
+			wantStatus: http.StatusCreated, wantBody: "created #1", wantCalls: 1,
+		},
+		{
+			name:       "retried after server error",
+			setup:      func() { post("/fail", "failed", "", "song") },
+			path:       "/fail",
+			key:        "failed",
+			body:       "song",
+			wantStatus: http.StatusInternalServerError, wantCalls: 2,
+		},
+		{
+			name:       "retried after panic",
+			setup:      func() { post("/panic", "panicked", "", "song") },
+			path:       "/panic",
+			key:        "panicked",
+			body:       "song",
+			wantStatus: http.StatusInternalServerError, wantCalls: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt64(&calls, 0)
+			if tt.setup != nil {
+				tt.setup()
+			}
+
+			rec := post(tt.path, tt.key, tt.auth, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got status %v, want %v: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("got body %q, want %q", rec.Body, tt.wantBody)
+			}
+			if replayed := rec.Header().Get(idempotentReplayedHeader) == "true"; replayed != tt.wantReplayed {
+				t.Errorf("got replayed %v, want %v", replayed, tt.wantReplayed)
+			}
+			if got := atomic.LoadInt64(&calls); got != tt.wantCalls {
+				t.Errorf("handler called %v times, want %v", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+// recoverer stands for middleware.Recoverer, without printing the stack
+func recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if p := recover(); p != nil {
+				rw.WriteHeader(http.StatusInternalServerError)
+			}
+		}()
+
+		next.ServeHTTP(rw, r)
+	})
+}
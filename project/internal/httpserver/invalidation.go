@@ -0,0 +1,74 @@
+package httpserver
+
+import (
+	"context"
+	"example/hello/project/internal/message_broker"
+	"example/hello/project/internal/models"
+	"fmt"
+)
+
+// tags of the cached entries, every entry is tagged with what it's built from
+const (
+	// any page of songs, including the songs of an artist
+	tagSongList = "list:songs"
+	// any page of artists
+	tagArtistList = "list:artists"
+)
+
+func songTag(id int) string {
+	return fmt.Sprintf("song:%d", id)
+}
+
+// artist tag is on the entries of the artist itself, of their songs, and of the songs nesting the artist (v2)
+func artistTag(id int) string {
+	return fmt.Sprintf("artist:%d", id)
+}
+
+func songTags(song *models.Song) []string {
+	return []string{songTag(song.ID), artistTag(song.ArtistID)}
+}
+
+func songListTags(songs []*models.Song) []string {
+	tags := []string{tagSongList}
+	seen := make(map[int]bool)
+	for _, song := range songs {
+		if !seen[song.ArtistID] {
+			seen[song.ArtistID] = true
+			tags = append(tags, artistTag(song.ArtistID))
+		}
+	}
+
+	return tags
+}
+
+// staleTags are the tags of the entries made stale by the change the event tells about
+func staleTags(event *models.Event) []string {
+	switch {
+	case event.Song != nil:
+		return []string{songTag(event.Song.ID), tagSongList}
+	case event.Artist != nil:
+		return []string{artistTag(event.Artist.ID), tagArtistList}
+	default:
+		return nil
+	}
+}
+
+// invalidateCache removes the entries made stale by the changes on every peer, the rest of the cache stays warm
+func invalidateCache(ctx context.Context, broker message_broker.MessageBroker, events ...*models.Event) error {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, event := range events {
+		for _, tag := range staleTags(event) {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	if len(tags) == 0 {
+		return nil
+	}
+
+	return broker.Cache().InvalidateTags(ctx, tags...)
+}
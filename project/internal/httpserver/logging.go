@@ -0,0 +1,36 @@
+package httpserver
+
+import (
+	"example/hello/project/internal/logger"
+	"github.com/go-chi/chi/middleware"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+// requestLogger puts a logger tagged with the chi request ID into the request context,
+// so that everything down the line (repositories, lyrics saga) can be correlated with the request
+func requestLogger(l *zap.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			reqLogger := l.With(zap.String("request_id", middleware.GetReqID(r.Context())))
+			if spanCtx := trace.SpanContextFromContext(r.Context()); spanCtx.IsValid() {
+				reqLogger = reqLogger.With(zap.String("trace_id", spanCtx.TraceID().String()))
+			}
+			ww := middleware.NewWrapResponseWriter(rw, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r.WithContext(logger.WithContext(r.Context(), reqLogger)))
+
+			reqLogger.Info("served request",
+				zap.String("method", r.Method),
+				zap.String("uri", r.RequestURI),
+				zap.String("remote_addr", r.RemoteAddr),
+				zap.Int("status", ww.Status()),
+				zap.Int("bytes", ww.BytesWritten()),
+				zap.Duration("duration", time.Since(start)),
+			)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package httpserver
+
+import (
+	"example/hello/project/internal/metrics"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"net/http"
+	"time"
+)
+
+// instrument records the rate and latency of requests per chi route pattern
+// (e.g. "/songs/{id}" rather than "/songs/42" to keep the cardinality low)
+func instrument(m *metrics.Metrics) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(rw, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r)
+
+			// the route pattern is only known once the request has been routed
+			route := "unmatched"
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			m.ObserveHTTPRequest(route, r.Method, status, time.Since(start))
+		})
+	}
+}
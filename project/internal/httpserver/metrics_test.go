@@ -0,0 +1,60 @@
+package httpserver
+
+import (
+	"example/hello/project/internal/metrics"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMetricsExported(t *testing.T) {
+	m := metrics.New()
+	srv, appCache := newTestServer(t, WithMetrics(m))
+	m.RegisterCacheSize(appCache.Len)
+
+	requests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{"/v1/songs", http.StatusOK},
+		{"/v1/songs", http.StatusOK},
+		{"/v1/songs/404", http.StatusNotFound},
+		{"/v1/nowhere", http.StatusNotFound},
+	}
+	for _, r := range requests {
+		resp, err := http.Get(srv.URL + r.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != r.wantStatus {
+			t.Fatalf("%v: got status %v, want %v", r.path, resp.StatusCode, r.wantStatus)
+		}
+	}
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the routes are counted by their patterns, so that the IDs don't blow up the cardinality
+	for _, want := range []string{
+		`lostify_http_requests_total{method="GET",route="/v1/songs/",status="200"} 2`,
+		`lostify_http_requests_total{method="GET",route="/v1/songs/{id}",status="404"} 1`,
+		`lostify_http_requests_total{method="GET",route="/v1/*",status="404"} 1`,
+		`lostify_http_request_duration_seconds_count{method="GET",route="/v1/songs/"} 2`,
+		`lostify_cache_lookups_total{resource="songs",result="miss"} 2`,
+		`lostify_cache_lookups_total{resource="songs",result="hit"} 1`,
+		`lostify_cache_size 1`,
+	} {
+		if !strings.Contains(string(body), want+"\n") {
+			t.Errorf("%v is not exported", want)
+		}
+	}
+}
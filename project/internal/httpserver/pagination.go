@@ -0,0 +1,37 @@
+package httpserver
+
+import (
+	"errors"
+	"example/hello/project/internal/models"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+)
+
+// pageFromQuery reads ?limit= and ?offset= query parameters, the results are ordered by ID
+func pageFromQuery(r *http.Request) (models.Page, error) {
+	page := models.Page{Limit: defaultPageSize}
+
+	queryValues := r.URL.Query()
+	if limit := queryValues.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxPageSize {
+			return page, fmt.Errorf("limit must be a number between 1 and %v", maxPageSize)
+		}
+		page.Limit = n
+	}
+	if offset := queryValues.Get("offset"); offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return page, errors.New("offset must be a non-negative number")
+		}
+		page.Offset = n
+	}
+
+	return page, nil
+}
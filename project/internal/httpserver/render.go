@@ -0,0 +1,216 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"example/hello/project/internal/store"
+	"fmt"
+	"github.com/go-chi/render"
+	"gopkg.in/yaml.v2"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	mediaTypeJSON = "application/json"
+	mediaTypeCSV  = "text/csv"
+	mediaTypeYAML = "application/yaml"
+)
+
+// mediaTypeAliases maps every acceptable media type (wildcards included) to the one we render
+var mediaTypeAliases = map[string]string{
+	"*/*":                mediaTypeJSON,
+	"application/*":      mediaTypeJSON,
+	mediaTypeJSON:        mediaTypeJSON,
+	"text/*":             mediaTypeCSV,
+	mediaTypeCSV:         mediaTypeCSV,
+	mediaTypeYAML:        mediaTypeYAML,
+	"application/x-yaml": mediaTypeYAML,
+	"text/yaml":          mediaTypeYAML,
+	"text/x-yaml":        mediaTypeYAML,
+}
+
+// negotiate picks the representation of the response based on Accept header of the request.
+// JSON is the default one; false is returned if none of the accepted media types is supported.
+func negotiate(r *http.Request) (string, bool) {
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return mediaTypeJSON, true
+	}
+
+	type candidate struct {
+		mediaType string
+		quality   float64
+	}
+	var candidates []candidate
+	for _, field := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(field))
+		if err != nil {
+			continue
+		}
+		supported, ok := mediaTypeAliases[mediaType]
+		if !ok {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if quality > 0 {
+			candidates = append(candidates, candidate{mediaType: supported, quality: quality})
+		}
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	// stable sort keeps the order of the header for the media types with the same quality
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].quality > candidates[j].quality
+	})
+	return candidates[0].mediaType, true
+}
+
+// respondTagged responds with the representation built by build, tagged for the response cache
+func respondTagged(rw http.ResponseWriter, r *http.Request, mediaType string, build func(ctx context.Context) (interface{}, []string, error)) {
+	body, tags, err := build(r.Context())
+	if err != nil {
+		storeError(rw, err)
+		return
+	}
+
+	tagResponse(r, tags...)
+	respond(rw, r, mediaType, body)
+}
+
+// storeError responds with 404 when there's nothing with the requested id, so that the clients don't retry it
+func storeError(rw http.ResponseWriter, err error) {
+	writeFailed(rw, dbError(err))
+}
+
+// stepError is the failed step of a write, REST responds with the status & the prefix of the step,
+// GraphQL with the error as it is
+type stepError struct {
+	status int
+	prefix string
+	err    error
+}
+
+func (e *stepError) Error() string {
+	return e.err.Error()
+}
+
+func (e *stepError) Unwrap() error {
+	return e.err
+}
+
+func validationError(err error) error {
+	return &stepError{status: http.StatusUnprocessableEntity, prefix: "Validation err", err: err}
+}
+
+func dbError(err error) error {
+	if errors.Is(err, store.ErrNotFound) {
+		return &stepError{status: http.StatusNotFound, prefix: "Not found", err: err}
+	}
+	return &stepError{status: http.StatusInternalServerError, prefix: "DB err", err: err}
+}
+
+func cacheError(err error) error {
+	return &stepError{status: http.StatusInternalServerError, prefix: "Received error while invalidating cache", err: err}
+}
+
+// writeFailed responds to the write which has failed on one of its steps
+func writeFailed(rw http.ResponseWriter, err error) {
+	step, ok := err.(*stepError)
+	if !ok {
+		step = &stepError{status: http.StatusInternalServerError, prefix: "Unknown err", err: err}
+	}
+
+	rw.WriteHeader(step.status)
+	_, _ = fmt.Fprintf(rw, "%v: %v", step.prefix, step.err)
+}
+
+func notAcceptable(rw http.ResponseWriter) {
+	rw.WriteHeader(http.StatusNotAcceptable)
+	_, _ = fmt.Fprintf(rw, "Supported media types: %v, %v, %v", mediaTypeJSON, mediaTypeCSV, mediaTypeYAML)
+}
+
+// respond renders v in the given representation
+func respond(rw http.ResponseWriter, r *http.Request, mediaType string, v interface{}) {
+	switch mediaType {
+	case mediaTypeCSV:
+		records, err := csvRecords(v)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			_, _ = fmt.Fprintf(rw, "CSV err: %v", err)
+			return
+		}
+
+		rw.Header().Set("Content-Type", mediaTypeCSV+"; charset=utf-8")
+		if status, ok := r.Context().Value(render.StatusCtxKey).(int); ok {
+			rw.WriteHeader(status)
+		}
+		_ = csv.NewWriter(rw).WriteAll(records)
+	case mediaTypeYAML:
+		body, err := yaml.Marshal(v)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			_, _ = fmt.Fprintf(rw, "YAML err: %v", err)
+			return
+		}
+
+		rw.Header().Set("Content-Type", mediaTypeYAML+"; charset=utf-8")
+		if status, ok := r.Context().Value(render.StatusCtxKey).(int); ok {
+			rw.WriteHeader(status)
+		}
+		_, _ = rw.Write(body)
+	default:
+		render.JSON(rw, r, v)
+	}
+}
+
+// csvTable is implemented by the representations of songs and artists in every version of the API
+type csvTable interface {
+	// csvRecords flattens the value into rows, the first one being the header
+	csvRecords() [][]string
+}
+
+func csvRecords(v interface{}) ([][]string, error) {
+	table, ok := v.(csvTable)
+	if !ok {
+		return nil, fmt.Errorf("%T can't be represented as CSV", v)
+	}
+
+	// the records are copied, as the headers are shared by all the responses
+	records := table.csvRecords()
+	escaped := make([][]string, len(records))
+	for i, record := range records {
+		escaped[i] = make([]string, len(record))
+		for j, cell := range record {
+			escaped[i][j] = csvCell(cell)
+		}
+	}
+
+	return escaped, nil
+}
+
+// csvCell keeps the spreadsheets from evaluating the user input as a formula (CSV injection),
+// the cells starting with a formula character are prefixed with a quote
+func csvCell(cell string) string {
+	if cell == "" {
+		return cell
+	}
+
+	switch cell[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + cell
+	}
+	return cell
+}
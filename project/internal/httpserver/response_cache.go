@@ -0,0 +1,252 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"example/hello/project/internal/cache"
+	"example/hello/project/internal/logger"
+	"example/hello/project/internal/metrics"
+	"fmt"
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cachedResponse is the response as it has been written by the handler, it's replayed as is on hits
+type cachedResponse struct {
+	status   int
+	header   http.Header
+	body     []byte
+	storedAt time.Time
+}
+
+// replay writes the response along with X-Cache (HIT, STALE or MISS) and Age of the cached ones
+func (cr *cachedResponse) replay(rw http.ResponseWriter, lookup cache.Lookup) {
+	for name, values := range cr.header {
+		rw.Header()[name] = append([]string(nil), values...)
+	}
+
+	rw.Header().Set("X-Cache", strings.ToUpper(lookup.String()))
+	if lookup != cache.LookupMiss {
+		rw.Header().Set("Age", strconv.Itoa(int(time.Since(cr.storedAt).Seconds())))
+	}
+
+	rw.WriteHeader(cr.status)
+	_, _ = rw.Write(cr.body)
+}
+
+// responseRecorder keeps what the handler writes, so that it can be cached
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   []byte
+}
+
+func (rec *responseRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+	rec.body = append(rec.body, b...)
+	return len(b), nil
+}
+
+type responseTagsKey struct{}
+
+// responseTags are the tags the handler gives to its response
+type responseTags struct {
+	tags []string
+}
+
+// tagResponse makes the response cacheable, it's invalidated along with any of the tags.
+// The responses without tags are never cached
+func tagResponse(r *http.Request, tags ...string) {
+	if rt, ok := r.Context().Value(responseTagsKey{}).(*responseTags); ok {
+		rt.tags = append(rt.tags, tags...)
+	}
+}
+
+// cacheDirectives are the ones of Cache-Control request header we honor
+type cacheDirectives struct {
+	noStore      bool
+	noCache      bool
+	onlyIfCached bool
+	// the oldest response acceptable to the client, negative when it's not limited
+	maxAge time.Duration
+}
+
+func parseCacheControl(header string) cacheDirectives {
+	directives := cacheDirectives{maxAge: -1}
+	for _, field := range strings.Split(header, ",") {
+		name, value := strings.TrimSpace(field), ""
+		if i := strings.Index(name, "="); i >= 0 {
+			name, value = strings.TrimSpace(name[:i]), strings.Trim(strings.TrimSpace(name[i+1:]), `"`)
+		}
+
+		switch strings.ToLower(name) {
+		case "no-store":
+			directives.noStore = true
+		case "no-cache":
+			directives.noCache = true
+		case "only-if-cached":
+			directives.onlyIfCached = true
+		case "max-age":
+			if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+				directives.maxAge = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+
+	return directives
+}
+
+// responseCacheKey is the same for all the requests getting the same response: the query is sorted,
+// the representation is the negotiated one rather than Accept as is, and the credentials are hashed,
+// so that the response to one client is never served to another one
+func responseCacheKey(r *http.Request, mediaType string) string {
+	key := r.Method + " " + mediaType + " " + credentialsScope(r) + " " + r.URL.Path
+	// Encode sorts the parameters by name
+	if query := r.URL.Query().Encode(); query != "" {
+		key += "?" + query
+	}
+
+	return key
+}
+
+// credentialsScope tells the clients apart by the hash of their credentials, without keeping the credentials
+func credentialsScope(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return "anonymous"
+	}
+
+	sum := sha256.Sum256([]byte(auth))
+	return "auth:" + hex.EncodeToString(sum[:8])
+}
+
+// responseCache caches the successful responses of the GET handlers, serialized and with their headers.
+// The handlers tag their responses (see tagResponse), so that the writes invalidate them;
+// concurrent misses of the same response share a single run of the handler
+func responseCache(c cache.TaggedCache, m *metrics.Metrics, log *zap.Logger, resource string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			// the representation and the credentials select the response, so must the shared caches downstream
+			rw.Header().Set("Vary", "Accept, Authorization")
+
+			directives := parseCacheControl(r.Header.Get("Cache-Control"))
+			mediaType, ok := negotiate(r)
+			if r.Method != http.MethodGet || !ok || directives.noStore {
+				m.ObserveCacheLookup(resource, "bypass")
+				rw.Header().Set("X-Cache", "BYPASS")
+				next.ServeHTTP(rw, r)
+				return
+			}
+
+			key := responseCacheKey(r, mediaType)
+			routed := withOwnRouteContext(r)
+			load := func(ctx context.Context) (interface{}, []string, error) {
+				return recordResponse(next, routed.WithContext(ctx))
+			}
+
+			var (
+				value  interface{}
+				lookup cache.Lookup
+				err    error
+			)
+			switch {
+			case directives.onlyIfCached:
+				if value, ok = c.Get(key); !ok {
+					m.ObserveCacheLookup(resource, cache.LookupMiss.String())
+					rw.WriteHeader(http.StatusGatewayTimeout)
+					_, _ = fmt.Fprintf(rw, "Not cached: %v", r.URL.RequestURI())
+					return
+				}
+				lookup = cache.LookupHit
+			case directives.noCache:
+				value, err = c.Load(r.Context(), key, load)
+				lookup = cache.LookupMiss
+			default:
+				value, lookup, err = c.GetOrLoad(r.Context(), key, load)
+				if err == nil && lookup != cache.LookupMiss && directives.maxAge >= 0 &&
+					time.Since(value.(*cachedResponse).storedAt) > directives.maxAge {
+					value, err = c.Load(r.Context(), key, load)
+					lookup = cache.LookupMiss
+				}
+			}
+			m.ObserveCacheLookup(resource, lookup.String())
+
+			if err != nil {
+				if r.Context().Err() != nil {
+					rw.WriteHeader(http.StatusServiceUnavailable)
+					_, _ = fmt.Fprintf(rw, "Request err: %v", err)
+					return
+				}
+
+				logger.FromContext(r.Context(), log).Error("failed to build the response", zap.String("uri", r.RequestURI), zap.Error(err))
+				rw.WriteHeader(http.StatusInternalServerError)
+				_, _ = fmt.Fprintf(rw, "Handler err: %v", err)
+				return
+			}
+
+			if lookup != cache.LookupMiss {
+				logger.FromContext(r.Context(), log).Debug("found response in cache", zap.String("uri", r.RequestURI), zap.Stringer("lookup", lookup))
+			}
+			value.(*cachedResponse).replay(rw, lookup)
+		})
+	}
+}
+
+// withOwnRouteContext copies the routing context of chi, which is reused once the request is served,
+// while the load (e.g. refresh of the stale response) might still be running the handler
+func withOwnRouteContext(r *http.Request) *http.Request {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return r
+	}
+
+	own := chi.NewRouteContext()
+	own.Routes = rctx.Routes
+	own.RoutePath = rctx.RoutePath
+	own.RouteMethod = rctx.RouteMethod
+	own.RoutePatterns = append([]string(nil), rctx.RoutePatterns...)
+	for i, key := range rctx.URLParams.Keys {
+		own.URLParams.Add(key, rctx.URLParams.Values[i])
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, own))
+}
+
+// recordResponse runs the handler, only its successful responses are given the tags and thus cached.
+// The handler runs in the goroutine of the load, so its panic is turned into the error
+func recordResponse(next http.Handler, r *http.Request) (value interface{}, tags []string, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("handler panicked: %v", p)
+		}
+	}()
+
+	rt := &responseTags{}
+	rec := &responseRecorder{header: make(http.Header)}
+	next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), responseTagsKey{}, rt)))
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+
+	response := &cachedResponse{status: rec.status, header: rec.header, body: rec.body, storedAt: time.Now()}
+	if response.status != http.StatusOK {
+		return response, nil, nil
+	}
+
+	return response, rt.tags, nil
+}
@@ -0,0 +1,48 @@
+package httpserver
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestResponseCacheVary(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	tests := []struct {
+		name      string
+		header    http.Header
+		wantCache string
+	}{
+		{"miss", http.Header{}, "MISS"},
+		{"hit", http.Header{}, "HIT"},
+		{"other representation", http.Header{"Accept": {"text/csv"}}, "MISS"},
+		{"authorized", http.Header{"Authorization": {"Bearer token"}}, "MISS"},
+		{"bypass", http.Header{"Cache-Control": {"no-store"}}, "BYPASS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/v1/songs", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header = tt.header
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("got status %v", resp.StatusCode)
+			}
+			if got := resp.Header.Get("X-Cache"); got != tt.wantCache {
+				t.Errorf("got X-Cache %q, want %q", got, tt.wantCache)
+			}
+			if got := resp.Header.Get("Vary"); got != "Accept, Authorization" {
+				t.Errorf("got Vary %q", got)
+			}
+		})
+	}
+}
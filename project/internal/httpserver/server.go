@@ -2,37 +2,73 @@ package httpserver
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"example/hello/project/internal/cache"
 	"example/hello/project/internal/message_broker"
+	"example/hello/project/internal/metrics"
+	"example/hello/project/internal/shutdown"
 	"example/hello/project/internal/store"
 	"github.com/go-chi/chi/middleware"
-	lru "github.com/hashicorp/golang-lru"
-	"log"
+	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi"
 )
 
 type Server struct {
-	ctx         context.Context
-	idleConnsCh chan struct{}
-	store       store.Store
-	cache       *lru.TwoQueueCache
-	broker      message_broker.MessageBroker
+	ctx      context.Context
+	store    store.Store
+	cache    cache.TaggedCache
+	broker   message_broker.MessageBroker
+	metrics  *metrics.Metrics
+	logger   *zap.Logger
+	logLevel *zap.AtomicLevel
+	// how long the responses to POST requests with Idempotency-Key are replayed
+	idempotencyWindow time.Duration
+	// HTTPS is served when it's set
+	tls   *tlsSettings
+	http2 bool
+	// background work started by the handlers, drained on shutdown
+	jobs *shutdown.Manager
+	// Musixmatch API unless it's set
+	lyricsAPI LyricsAPI
+	// required by /admin routes, they're closed when it's empty
+	adminToken string
+	// the cache is warmed up on start and after every purge when it's set
+	warming     *warmingSettings
+	accessStats *accessStats
+
+	mu         sync.Mutex
+	httpServer *http.Server
 
 	Address string
 }
 
 func NewServer(ctx context.Context, opts ...ServerOption) *Server {
 	srv := &Server{
-		ctx:         ctx,
-		idleConnsCh: make(chan struct{}),
+		ctx:               ctx,
+		idempotencyWindow: defaultIdempotencyWindow,
 	}
 
 	for _, opt := range opts {
 		opt(srv)
 	}
 
+	if srv.logger == nil {
+		srv.logger = zap.NewNop()
+	}
+	if srv.jobs == nil {
+		srv.jobs = shutdown.NewManager(defaultShutdownTimeout, srv.logger)
+	}
+	if srv.warming != nil {
+		srv.accessStats = newAccessStats()
+	}
+
 	return srv
 }
 
@@ -42,8 +78,15 @@ func (s *Server) basicHandler() chi.Router {
 	// A good base middleware stack
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
+	r.Use(traceRequests)
+	r.Use(requestLogger(s.logger))
 	r.Use(middleware.Recoverer)
+	r.Use(instrument(s.metrics))
+	r.Use(idempotent(s.store, s.idempotencyWindow, s.logger))
+	if s.accessStats != nil {
+		// the most requested responses are warmed up along with the configured ones
+		r.Use(countAccesses(s.accessStats))
+	}
 
 	r.Get("/", func(rw http.ResponseWriter, r *http.Request) {
 		_, err := rw.Write([]byte("Hello world, I am Lostify!"))
@@ -57,21 +100,100 @@ func (s *Server) basicHandler() chi.Router {
 			return
 		}
 	})
+	// liveness & readiness probes
+	healthResource := NewHealthResource(s.store, s.broker)
+	r.Get("/healthz", healthResource.Liveness)
+	r.Get("/readyz", healthResource.Readiness)
+
+	// metrics in Prometheus exposition format
+	r.Handle("/metrics", s.metrics.Handler())
+
 	r.Get("/panic", func(w http.ResponseWriter, r *http.Request) {
 		panic("test")
 	})
 
-	// mounting routes of /songs resource
-	songsResource := NewSongResource(s.store, s.broker, s.cache)
+	// versions of REST API, see api_v1.go & api_v2.go for the representations of songs and artists
+	var songsResource *SongResource
+	var artistsResource *ArtistResource
+	r.Route("/v1", func(r chi.Router) {
+		songsResource, artistsResource = s.v1Routes(r)
+	})
+	r.Route("/v2", func(r chi.Router) {
+		s.catalogRoutes(r, apiV2{})
+		s.webhooksRoutes(r)
+	})
+	// unversioned routes are the deprecated alias of v1
+	r.Group(func(r chi.Router) {
+		r.Use(deprecated("/v1"))
+		s.v1Routes(r)
+	})
+
+	// admin UI for curating the catalog, served at /admin/ to the holders of the admin token
+	adminResource := NewAdminResource(s.broker, s.cache, s.logLevel, s.adminToken, s.logger)
+	r.Mount("/admin", adminResource.Routes())
+
+	// live feed of changes made on every peer
+	eventsResource := NewEventsResource(s.ctx, s.broker, s.logger)
+	r.Mount("/events", eventsResource.Routes())
+
+	// GraphQL endpoint over songs & artists
+	graphqlResource := NewGraphQLResource(songsResource, artistsResource)
+	r.Handle("/graphql", graphqlResource.Handler())
+
+	return r
+}
+
+// catalogRoutes mounts /songs & /artists resources rendered in the representation of the given version
+func (s *Server) catalogRoutes(r chi.Router, version apiVersion) (*SongResource, *ArtistResource) {
+	songsResource := NewSongResource(s.store, s.broker, s.cache, s.metrics, s.jobs, s.lyricsAPI, s.logger, version)
 	r.Mount("/songs", songsResource.Routes())
 
-	// mounting routes of /artists resource
-	artistsResource := NewArtistResource(s.store, s.broker, s.cache)
+	artistsResource := NewArtistResource(s.store, s.broker, s.cache, s.metrics, s.logger, version, songsResource)
 	r.Mount("/artists", artistsResource.Routes())
 
+	return songsResource, artistsResource
+}
+
+// subscriptions of partner services to the catalog events
+func (s *Server) webhooksRoutes(r chi.Router) {
+	webhooksResource := NewWebhookResource(s.store)
+	r.Mount("/webhooks", webhooksResource.Routes())
+}
+
+func (s *Server) v1Routes(r chi.Router) (*SongResource, *ArtistResource) {
+	songsResource, artistsResource := s.catalogRoutes(r, apiV1{})
+	s.webhooksRoutes(r)
+
+	// many creates, updates & deletes of songs and artists in a single request,
+	// the operations carry songs in v1 representation, hence it's only a part of v1
+	batchResource := NewBatchResource(s.jobs, songsResource, artistsResource)
+	r.Mount("/batch", batchResource.Routes())
+
+	return songsResource, artistsResource
+}
+
+// catalogHandler serves the cached routes without the middleware stack, so that the requests of the cache warmer
+// aren't logged, traced, counted by the metrics or taken for the accesses of the users
+func (s *Server) catalogHandler() http.Handler {
+	r := chi.NewRouter()
+	r.Route("/v1", func(r chi.Router) {
+		s.catalogRoutes(r, apiV1{})
+	})
+	r.Route("/v2", func(r chi.Router) {
+		s.catalogRoutes(r, apiV2{})
+	})
+	r.Group(func(r chi.Router) {
+		s.catalogRoutes(r, apiV1{})
+	})
+
 	return r
 }
 
+// Handler serves the routes of the server without listening, e.g. in the tests of the clients
+func (s *Server) Handler() http.Handler {
+	return s.basicHandler()
+}
+
 func (s *Server) Run() error {
 	server := &http.Server{
 		Addr:         s.Address,
@@ -79,23 +201,97 @@ func (s *Server) Run() error {
 		ReadTimeout:  time.Second * 5,
 		WriteTimeout: time.Second * 30,
 	}
-	go s.ListenCtxForGT(server)
+	s.startCacheWarming()
+
+	if s.tls == nil {
+		if s.http2 {
+			// HTTP/2 without TLS, for the clients & proxies that know the server speaks it
+			server.Handler = h2c.NewHandler(server.Handler, &http2.Server{})
+		}
+		s.setHTTPServer(server)
+
+		s.logger.Info("[HTTP] server running", zap.String("address", s.Address), zap.Bool("http2", s.http2))
+		return ignoreServerClosed(server.ListenAndServe())
+	}
+
+	if s.tls.certFile == "" || s.tls.keyFile == "" {
+		return errors.New("both the certificate and the key are required to serve HTTPS")
+	}
+
+	nextProtos := []string{"http/1.1"}
+	if s.http2 {
+		nextProtos = []string{"h2", "http/1.1"}
+	}
+	reloader, err := newCertReloader(*s.tls, nextProtos, s.logger)
+	if err != nil {
+		return err
+	}
+	go reloader.watch(s.ctx, certReloadInterval)
 
-	log.Printf("[HTTP] Server running on %v", s.Address)
-	return server.ListenAndServe()
+	server.TLSConfig = reloader.tlsConfig()
+	if s.http2 {
+		if err := http2.ConfigureServer(server, &http2.Server{}); err != nil {
+			return err
+		}
+	} else {
+		// non-nil map keeps net/http from enabling HTTP/2 on its own
+		server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	}
+	s.setHTTPServer(server)
+
+	s.logger.Info("[HTTP] server running",
+		zap.String("address", s.Address),
+		zap.Bool("tls", true),
+		zap.Bool("mtls", s.tls.clientCAFile != ""),
+		zap.Bool("http2", s.http2),
+	)
+	// the certificates come from the TLS config
+	return ignoreServerClosed(server.ListenAndServeTLS("", ""))
+}
+
+// startCacheWarming warms the cache up now and after every purge, e.g. the one coming from another peer
+func (s *Server) startCacheWarming() {
+	if s.warming == nil || s.cache == nil {
+		return
+	}
+
+	warmer := newCacheWarmer(*s.warming, s.catalogHandler(), s.accessStats, s.jobs, s.logger)
+	s.cache.OnPurge(warmer.start)
+	warmer.start()
 }
 
-func (s *Server) ListenCtxForGT(server *http.Server) {
-	<-s.ctx.Done() // blocks while context isn't finished
+func (s *Server) setHTTPServer(server *http.Server) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	if err := server.Shutdown(context.Background()); err != nil {
-		log.Println("[HTTP] Got error while shutting down", err)
+	s.httpServer = server
+}
+
+// Shutdown stops accepting connections and waits for the requests in flight until the context is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	server := s.httpServer
+	s.mu.Unlock()
+
+	if server == nil {
+		return nil
 	}
 
-	log.Println("[HTTP] Processed all idle connections")
-	close(s.idleConnsCh)
+	if err := server.Shutdown(ctx); err != nil {
+		// the requests still in flight are cut off
+		_ = server.Close()
+		return err
+	}
+
+	s.logger.Info("[HTTP] processed all idle connections")
+	return nil
 }
 
-func (s *Server) WaitForGracefulTermination() {
-	<-s.idleConnsCh
+// ignoreServerClosed tells the shutdown from the failure of the server
+func ignoreServerClosed(err error) error {
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
 }
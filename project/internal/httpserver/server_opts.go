@@ -1,13 +1,20 @@
 package httpserver
 
 import (
+	"example/hello/project/internal/cache"
 	"example/hello/project/internal/message_broker"
+	"example/hello/project/internal/metrics"
+	"example/hello/project/internal/shutdown"
 	"example/hello/project/internal/store"
-	lru "github.com/hashicorp/golang-lru"
+	"go.uber.org/zap"
+	"time"
 )
 
 type ServerOption func(srv *Server)
 
+// used when the server isn't given the shutdown manager of the application
+const defaultShutdownTimeout = 30 * time.Second
+
 func WithAddress(address string) ServerOption {
 	return func(srv *Server) {
 		srv.Address = address
@@ -20,7 +27,7 @@ func WithStore(store store.Store) ServerOption {
 	}
 }
 
-func WithCache(cache *lru.TwoQueueCache) ServerOption {
+func WithCache(cache cache.TaggedCache) ServerOption {
 	return func(srv *Server) {
 		srv.cache = cache
 	}
@@ -31,3 +38,85 @@ func WithBroker(broker message_broker.MessageBroker) ServerOption {
 		srv.broker = broker
 	}
 }
+
+func WithMetrics(metrics *metrics.Metrics) ServerOption {
+	return func(srv *Server) {
+		srv.metrics = metrics
+	}
+}
+
+func WithLogger(logger *zap.Logger) ServerOption {
+	return func(srv *Server) {
+		srv.logger = logger
+	}
+}
+
+// WithLogLevel exposes the level at /admin/log/level so that the admins can change it at runtime
+func WithLogLevel(level zap.AtomicLevel) ServerOption {
+	return func(srv *Server) {
+		srv.logLevel = &level
+	}
+}
+
+func WithIdempotencyWindow(window time.Duration) ServerOption {
+	return func(srv *Server) {
+		srv.idempotencyWindow = window
+	}
+}
+
+// WithTLS serves HTTPS with the given certificate & key, the files are reloaded whenever they change on disk
+func WithTLS(certFile, keyFile string) ServerOption {
+	return func(srv *Server) {
+		if srv.tls == nil {
+			srv.tls = &tlsSettings{}
+		}
+		srv.tls.certFile, srv.tls.keyFile = certFile, keyFile
+	}
+}
+
+// WithClientCA requires the clients to present a certificate signed by one of the CAs in the file (mTLS),
+// it only takes effect along with WithTLS
+func WithClientCA(caFile string) ServerOption {
+	return func(srv *Server) {
+		if srv.tls == nil {
+			srv.tls = &tlsSettings{}
+		}
+		srv.tls.clientCAFile = caFile
+	}
+}
+
+// WithHTTP2 enables HTTP/2, negotiated via ALPN over TLS and as h2c (prior knowledge or upgrade) otherwise
+func WithHTTP2() ServerOption {
+	return func(srv *Server) {
+		srv.http2 = true
+	}
+}
+
+// WithShutdownManager makes the background work of the handlers tracked by the manager of the application
+func WithShutdownManager(manager *shutdown.Manager) ServerOption {
+	return func(srv *Server) {
+		srv.jobs = manager
+	}
+}
+
+// WithLyricsAPI fetches the lyrics from another API speaking Musixmatch's protocol, e.g. a fake one in the tests
+func WithLyricsAPI(api LyricsAPI) ServerOption {
+	return func(srv *Server) {
+		srv.lyricsAPI = api
+	}
+}
+
+// WithCacheWarming preloads the responses to the targets (e.g. /v1/songs) and the top most requested ones
+// into the cache on start and after every purge, making at most concurrency requests at once
+func WithCacheWarming(targets []string, top, concurrency int) ServerOption {
+	return func(srv *Server) {
+		srv.warming = &warmingSettings{targets: targets, top: top, concurrency: concurrency}
+	}
+}
+
+// WithAdminToken opens /admin routes to the requests carrying the token (as a bearer token or basic auth password)
+func WithAdminToken(token string) ServerOption {
+	return func(srv *Server) {
+		srv.adminToken = token
+	}
+}
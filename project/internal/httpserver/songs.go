@@ -3,17 +3,24 @@ package httpserver
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"example/hello/project/internal/cache"
+	"example/hello/project/internal/logger"
 	"example/hello/project/internal/message_broker"
+	"example/hello/project/internal/metrics"
 	"example/hello/project/internal/models"
+	"example/hello/project/internal/shutdown"
 	"example/hello/project/internal/store"
+	"example/hello/project/internal/tracing"
 	"fmt"
 	"github.com/go-chi/chi"
-	"github.com/go-chi/render"
 	validation "github.com/go-ozzo/ozzo-validation/v4"
-	lru "github.com/hashicorp/golang-lru"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
 	"path"
@@ -21,26 +28,40 @@ import (
 )
 
 type SongResource struct {
-	store  store.Store
-	broker message_broker.MessageBroker
-	cache  *lru.TwoQueueCache
+	store   store.Store
+	broker  message_broker.MessageBroker
+	cache   cache.TaggedCache
+	metrics *metrics.Metrics
+	// lyrics of the created songs are fetched in background, drained on shutdown
+	jobs      *shutdown.Manager
+	lyricsAPI LyricsAPI
+	logger    *zap.Logger
+	// representation of the resource
+	version apiVersion
 }
 
-func NewSongResource(store store.Store, broker message_broker.MessageBroker, cache *lru.TwoQueueCache) *SongResource {
+func NewSongResource(store store.Store, broker message_broker.MessageBroker, cache cache.TaggedCache, metrics *metrics.Metrics, jobs *shutdown.Manager, lyricsAPI LyricsAPI, logger *zap.Logger, version apiVersion) *SongResource {
 	return &SongResource{
-		store:  store,
-		broker: broker,
-		cache:  cache,
+		store:     store,
+		broker:    broker,
+		cache:     cache,
+		metrics:   metrics,
+		jobs:      jobs,
+		lyricsAPI: lyricsAPI,
+		logger:    logger,
+		version:   version,
 	}
 }
 
 func (sr *SongResource) Routes() chi.Router {
 	r := chi.NewRouter()
 
+	cached := r.With(responseCache(sr.cache, sr.metrics, sr.logger, "songs"))
+
 	// RESTy routes for "songs" resource
 	r.Post("/", sr.CreateSong)
-	r.Get("/", sr.AllSongs)
-	r.Get("/{id}", sr.ByID)
+	cached.Get("/", sr.AllSongs)
+	cached.Get("/{id}", sr.ByID)
 	r.Put("/", sr.UpdateSong)
 	r.Delete("/{id}", sr.DeleteSong)
 
@@ -56,27 +77,62 @@ func validateSong(song *models.Song) error {
 	)
 }
 
-func (sr *SongResource) setTemporaryStatus(song *models.Song) error {
-	song.Lyrics = "fetching lyrics..."
-	return sr.store.Songs().Update(context.TODO(), song)
+func (sr *SongResource) setTemporaryStatus(ctx context.Context, song *models.Song) error {
+	song.Lyrics = models.LyricsStatusFetching
+	return sr.store.Songs().Update(ctx, song)
 }
 
-func (sr *SongResource) setFailedStatus(song *models.Song) error {
-	song.Lyrics = "failed"
-	return sr.store.Songs().Update(context.TODO(), song)
+func (sr *SongResource) setFailedStatus(ctx context.Context, song *models.Song) error {
+	song.Lyrics = models.LyricsStatusFailed
+	return sr.store.Songs().Update(ctx, song)
 }
 
-func (sr *SongResource) setFinalStatus(song *models.Song) error {
-	return sr.store.Songs().Update(context.TODO(), song)
+func (sr *SongResource) setFinalStatus(ctx context.Context, song *models.Song) error {
+	return sr.store.Songs().Update(ctx, song)
 }
 
 const APIRootURL = "https://api.musixmatch.com/ws/1.1"
 const APIKey = "e2dd130dd5117a2e12cbb07d1af40373"
 
-func makeAPICall(apiURL string) ([]byte, error) {
-	log.Println("making a GET call to", apiURL)
+// LyricsAPI is where the lyrics are fetched from, the zero value is Musixmatch API called by http.DefaultClient
+type LyricsAPI struct {
+	RootURL string
+	Client  *http.Client
+}
+
+func (api LyricsAPI) rootURL() string {
+	if api.RootURL == "" {
+		return APIRootURL
+	}
+	return api.RootURL
+}
+
+func (api LyricsAPI) client() *http.Client {
+	if api.Client == nil {
+		return http.DefaultClient
+	}
+	return api.Client
+}
+
+func (sr *SongResource) makeAPICall(ctx context.Context, apiURL *url.URL) (_ []byte, err error) {
+	// neither span nor logs get the full URL, since the query contains API key
+	ctx, span := tracer.Start(ctx, "Musixmatch GET "+path.Base(apiURL.Path),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPMethodKey.String(http.MethodGet),
+			semconv.HTTPHostKey.String(apiURL.Host),
+			semconv.HTTPTargetKey.String(apiURL.Path),
+		),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	logger.FromContext(ctx, sr.logger).Debug("making a GET call to Musixmatch API", zap.String("path", apiURL.Path))
 
-	response, err := http.Get(apiURL)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := sr.lyricsAPI.client().Do(request)
 	if err != nil {
 		return nil, err
 	}
@@ -86,10 +142,11 @@ func makeAPICall(apiURL string) ([]byte, error) {
 			panic(err)
 		}
 	}(response.Body)
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(response.StatusCode))
 
 	// checking if status is 200
 	if response.StatusCode != 200 {
-		return nil, fmt.Errorf("received disturbing status code %v", response)
+		return nil, fmt.Errorf("received disturbing status code %v", response.StatusCode)
 	}
 
 	// reading the body from the response
@@ -103,13 +160,13 @@ func makeAPICall(apiURL string) ([]byte, error) {
 // Returns the "track_id" needed to get the lyrics of the song
 // Checkout the MusixMatch Lyrics API at: https://developer.musixmatch.com/documentation
 // Great tutorial on parsing JSON response: https://www.sohamkamani.com/golang/json/#decoding-json-to-maps---unstructured-data
-func (sr *SongResource) searchForSong(song *models.Song) (string, error) {
-	artist, err := sr.store.Songs().GetArtist(context.TODO(), song)
+func (sr *SongResource) searchForSong(ctx context.Context, song *models.Song) (string, error) {
+	artist, err := sr.store.Songs().GetArtist(ctx, song)
 	if err != nil {
 		return "", err
 	}
 
-	apiURL, err := url.Parse(APIRootURL)
+	apiURL, err := url.Parse(sr.lyricsAPI.rootURL())
 	if err != nil {
 		return "", err
 	}
@@ -123,32 +180,51 @@ func (sr *SongResource) searchForSong(song *models.Song) (string, error) {
 	q.Set("s_artist_rating", "desc")
 	apiURL.RawQuery = q.Encode()
 
-	response, err := makeAPICall(apiURL.String())
+	response, err := sr.makeAPICall(ctx, apiURL)
+	sr.metrics.ObserveLyricsCall("track.search", err)
 	if err != nil {
 		return "", err
 	}
-	var result map[string]interface{}
-	if err := json.Unmarshal([]byte(response), &result); err != nil {
+	body, err := responseBody(response)
+	if err != nil {
 		return "", err
 	}
-	result = result["message"].(map[string]interface{})
-	result = result["body"].(map[string]interface{})
 
-	tracks := result["track_list"].([]interface{})
+	tracks, ok := body["track_list"].([]interface{})
+	if !ok {
+		return "", errors.New("unexpected response of Musixmatch API: no track list")
+	}
+	if len(tracks) == 0 {
+		return "", fmt.Errorf("song %q of %v isn't found", song.Title, artist.FullName)
+	}
 	// for simplicity, we're only considering the first one
 	// that would be the most relevant
-	track := tracks[0].(map[string]interface{})
-	track = track["track"].(map[string]interface{})
+	first, ok := tracks[0].(map[string]interface{})
+	if !ok {
+		return "", errors.New("unexpected response of Musixmatch API: track isn't an object")
+	}
+	track, err := object(first, "track")
+	if err != nil {
+		return "", err
+	}
 
-	song.AlbumName = track["album_name"].(string)
+	albumName, ok := track["album_name"].(string)
+	if !ok {
+		return "", errors.New("unexpected response of Musixmatch API: no album name")
+	}
+	id, ok := track["track_id"].(float64)
+	if !ok {
+		return "", errors.New("unexpected response of Musixmatch API: no track ID")
+	}
+	song.AlbumName = albumName
 
-	trackID := strconv.FormatFloat(track["track_id"].(float64), 'f', -1, 64)
+	trackID := strconv.FormatFloat(id, 'f', -1, 64)
 	return trackID, nil
 }
 
 // Fetches the lyrics for the song
-func (sr *SongResource) getLyrics(song *models.Song, trackID string) error {
-	apiURL, err := url.Parse(APIRootURL)
+func (sr *SongResource) getLyrics(ctx context.Context, song *models.Song, trackID string) error {
+	apiURL, err := url.Parse(sr.lyricsAPI.rootURL())
 	if err != nil {
 		return err
 	}
@@ -158,23 +234,60 @@ func (sr *SongResource) getLyrics(song *models.Song, trackID string) error {
 	q.Set("track_id", trackID)
 	apiURL.RawQuery = q.Encode()
 
-	response, err := makeAPICall(apiURL.String())
+	response, err := sr.makeAPICall(ctx, apiURL)
+	sr.metrics.ObserveLyricsCall("track.lyrics.get", err)
 	if err != nil {
 		return err
 	}
-	var result map[string]interface{}
-	if err := json.Unmarshal([]byte(response), &result); err != nil {
+	body, err := responseBody(response)
+	if err != nil {
+		return err
+	}
+	lyrics, err := object(body, "lyrics")
+	if err != nil {
 		return err
 	}
-	result = result["message"].(map[string]interface{})
-	result = result["body"].(map[string]interface{})
-	result = result["lyrics"].(map[string]interface{})
 
-	song.Lyrics = result["lyrics_body"].(string)
+	lyricsBody, ok := lyrics["lyrics_body"].(string)
+	if !ok {
+		return errors.New("unexpected response of Musixmatch API: no lyrics body")
+	}
+	song.Lyrics = lyricsBody
 
 	return nil
 }
 
+// responseBody unwraps the body of Musixmatch response, the errors come with 200 status as well,
+// though their body isn't an object
+func responseBody(response []byte) (map[string]interface{}, error) {
+	var result map[string]interface{}
+	if err := json.Unmarshal(response, &result); err != nil {
+		return nil, err
+	}
+
+	message, err := object(result, "message")
+	if err != nil {
+		return nil, err
+	}
+	body, ok := message["body"].(map[string]interface{})
+	if !ok {
+		header, _ := message["header"].(map[string]interface{})
+		return nil, fmt.Errorf("Musixmatch API has responded with status %v", header["status_code"])
+	}
+
+	return body, nil
+}
+
+// object looks up the JSON object by the key
+func object(parent map[string]interface{}, key string) (map[string]interface{}, error) {
+	child, ok := parent[key].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected response of Musixmatch API: no %v object", key)
+	}
+
+	return child, nil
+}
+
 // Simple Saga Pattern
 // Two services: , the other with external API
 //  A) one works with DB
@@ -186,76 +299,137 @@ func (sr *SongResource) getLyrics(song *models.Song, trackID string) error {
 //		- getLyrics
 //
 // read more about Saga pattern at: https://docs.microsoft.com/en-us/azure/architecture/reference-architectures/saga/saga
-func (sr *SongResource) fetchTheLyrics(song *models.Song) error {
-	log.Println("starting to get the lyrics for", song)
+func (sr *SongResource) fetchTheLyrics(ctx context.Context, song *models.Song) (err error) {
+	ctx, span := tracer.Start(ctx, "fetchTheLyrics", trace.WithAttributes(attribute.Int("song.id", song.ID)))
+	defer func() { tracing.End(span, err) }()
 
-	if err := sr.setTemporaryStatus(song); err != nil {
+	log := logger.FromContext(ctx, sr.logger).With(zap.Int("song_id", song.ID))
+	log.Info("starting to get the lyrics", zap.String("title", song.Title))
+
+	if err := sr.setTemporaryStatus(ctx, song); err != nil {
 		return err
 	}
 
-	trackID, err := sr.searchForSong(song)
+	trackID, err := sr.searchForSong(ctx, song)
 	if err != nil {
-		if err := sr.setFailedStatus(song); err != nil {
+		if err := sr.setFailedStatus(ctx, song); err != nil {
 			return err
 		}
-		log.Println("failed on searching for song; err:", err)
+		log.Warn("failed on searching for song", zap.Error(err))
 		return nil
 	}
 
-	if err := sr.getLyrics(song, trackID); err != nil {
-		if err := sr.setFailedStatus(song); err != nil {
+	if err := sr.getLyrics(ctx, song, trackID); err != nil {
+		if err := sr.setFailedStatus(ctx, song); err != nil {
 			return err
 		}
-		log.Println("failed on getting the lyrics for the song; err:", err)
+		log.Warn("failed on getting the lyrics for the song", zap.Error(err))
 		return nil
 	}
 
-	if err := sr.setFinalStatus(song); err != nil {
+	if err := sr.setFinalStatus(ctx, song); err != nil {
 		return err
 	}
+	log.Info("fetched the lyrics", zap.String("album_name", song.AlbumName))
 	return nil
 }
 
+// lyricsEvent tells how the lyrics saga for the song has ended
+func lyricsEvent(song *models.Song) *models.Event {
+	if song.Lyrics == models.LyricsStatusFailed {
+		return &models.Event{Type: models.EventSongLyricsFailed, Song: song}
+	}
+	return &models.Event{Type: models.EventSongLyricsReady, Song: song}
+}
+
 func (sr *SongResource) CreateSong(rw http.ResponseWriter, r *http.Request) {
-	song := new(models.Song)
-	if err := json.NewDecoder(r.Body).Decode(song); err != nil {
+	song, err := sr.version.decodeSong(r.Body)
+	if err != nil {
 		rw.WriteHeader(http.StatusUnprocessableEntity)
 		_, _ = fmt.Fprintf(rw, "Unknown err: %v", err)
 		return
 	}
 
-	if err := validateSong(song); err != nil {
-		rw.WriteHeader(http.StatusUnprocessableEntity)
-		_, _ = fmt.Fprintf(rw, "Validation err: %v", err)
+	sr.create(rw, r, song)
+}
+
+// CreateArtistSong creates the song of the artist from the path, whatever artist the body refers to
+func (sr *SongResource) CreateArtistSong(rw http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	artistID, err := strconv.Atoi(idStr)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintf(rw, "Unknown err: %v", err)
 		return
 	}
 
-	if err := sr.store.Songs().Create(r.Context(), song); err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		_, _ = fmt.Fprintf(rw, "DB err: %v", err)
+	song, err := sr.version.decodeSong(r.Body)
+	if err != nil {
+		rw.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = fmt.Fprintf(rw, "Unknown err: %v", err)
 		return
 	}
+	song.ArtistID = artistID
+
+	sr.create(rw, r, song)
+}
 
-	if err := sr.fetchTheLyrics(song); err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		_, _ = fmt.Println("Received error while fetching lyrics:", err)
+func (sr *SongResource) create(rw http.ResponseWriter, r *http.Request, song *models.Song) {
+	if err := sr.createSong(r.Context(), song); err != nil {
+		writeFailed(rw, err)
 		return
 	}
 
-	if err := sr.broker.Cache().Purge(); err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		_, _ = fmt.Fprintf(rw, "Received error while purging cache: %v", err)
+	rw.WriteHeader(http.StatusCreated)
+}
+
+// createSong stores the song and fetches its lyrics in background, the same for REST & GraphQL
+func (sr *SongResource) createSong(ctx context.Context, song *models.Song) error {
+	if err := validateSong(song); err != nil {
+		return validationError(err)
+	}
+
+	// the song tells its lyrics are being fetched until the saga is over
+	song.Lyrics = models.LyricsStatusFetching
+	if err := sr.store.Songs().Create(ctx, song); err != nil {
+		return dbError(err)
+	}
+
+	event := &models.Event{Type: models.EventSongCreated, Song: song}
+	if err := invalidateCache(ctx, sr.broker, event); err != nil {
+		return cacheError(err)
+	}
+
+	publishEvent(ctx, sr.broker, sr.logger, event)
+
+	// the saga outlives the request, so that the song isn't left fetching the lyrics when the client goes away
+	log := logger.FromContext(ctx, sr.logger)
+	created := *song
+	sr.jobs.Go("song lyrics", func(ctx context.Context) {
+		sr.lyrics(logger.WithContext(ctx, log), &created)
+	})
+
+	return nil
+}
+
+// lyrics runs the lyrics saga for the created song and announces how it has ended
+func (sr *SongResource) lyrics(ctx context.Context, song *models.Song) {
+	if err := sr.fetchTheLyrics(ctx, song); err != nil {
+		logger.FromContext(ctx, sr.logger).Error("failed to fetch the lyrics", zap.Int("song_id", song.ID), zap.Error(err))
 		return
 	}
 
-	rw.WriteHeader(http.StatusCreated)
+	event := lyricsEvent(song)
+	if err := invalidateCache(ctx, sr.broker, event); err != nil {
+		logger.FromContext(ctx, sr.logger).Error("failed to invalidate cache after fetching the lyrics", zap.Error(err))
+	}
+	publishEvent(ctx, sr.broker, sr.logger, event)
 }
 
 func (sr *SongResource) AllSongs(rw http.ResponseWriter, r *http.Request) {
-	dataFromCache, ok := sr.cache.Get(r.RequestURI)
-	if ok {
-		log.Println("found data from cache with URI =", r.RequestURI)
-		render.JSON(rw, r, dataFromCache)
+	mediaType, ok := negotiate(r)
+	if !ok {
+		notAcceptable(rw)
 		return
 	}
 
@@ -263,27 +437,68 @@ func (sr *SongResource) AllSongs(rw http.ResponseWriter, r *http.Request) {
 	queryValues := r.URL.Query()
 	searchQuery := queryValues.Get("searchQuery")
 
-	filter := &models.Filter{}
+	page, err := pageFromQuery(r)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintf(rw, "Pagination err: %v", err)
+		return
+	}
+
+	filter := &models.Filter{Page: page}
 	if searchQuery != "" {
 		filter.Query = &searchQuery
 	}
 
-	songs, err := sr.store.Songs().All(r.Context(), filter)
+	// the representation is cached, so that it isn't built on every hit
+	respondTagged(rw, r, mediaType, func(ctx context.Context) (interface{}, []string, error) {
+		songs, err := sr.store.Songs().All(ctx, filter)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		body, err := sr.version.songs(ctx, sr.store, songs)
+		return body, songListTags(songs), err
+	})
+}
+
+// ArtistSongs returns a page of the songs of the artist
+func (sr *SongResource) ArtistSongs(rw http.ResponseWriter, r *http.Request) {
+	mediaType, ok := negotiate(r)
+	if !ok {
+		notAcceptable(rw)
+		return
+	}
+
+	idStr := chi.URLParam(r, "id")
+	artistID, err := strconv.Atoi(idStr)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintf(rw, "Unknown err: %v", err)
+		return
+	}
+
+	page, err := pageFromQuery(r)
 	if err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		_, _ = fmt.Fprintf(rw, "DB err: %v", err)
+		rw.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintf(rw, "Pagination err: %v", err)
 		return
 	}
 
-	sr.cache.Add(r.RequestURI, songs)
-	render.JSON(rw, r, songs)
+	respondTagged(rw, r, mediaType, func(ctx context.Context) (interface{}, []string, error) {
+		songs, err := sr.store.Songs().ByArtistID(ctx, artistID, &page)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		body, err := sr.version.songs(ctx, sr.store, songs)
+		return body, append(songListTags(songs), artistTag(artistID)), err
+	})
 }
 
 func (sr *SongResource) ByID(rw http.ResponseWriter, r *http.Request) {
-	dataFromCache, ok := sr.cache.Get(r.RequestURI)
-	if ok {
-		log.Println("found data from cache with URI =", r.RequestURI)
-		render.JSON(rw, r, dataFromCache)
+	mediaType, ok := negotiate(r)
+	if !ok {
+		notAcceptable(rw)
 		return
 	}
 
@@ -294,42 +509,48 @@ func (sr *SongResource) ByID(rw http.ResponseWriter, r *http.Request) {
 		_, _ = fmt.Fprintf(rw, "Unknown err: %v", err)
 		return
 	}
-	song, err := sr.store.Songs().ByID(r.Context(), id)
-	if err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		_, _ = fmt.Fprintf(rw, "DB err: %v", err)
-		return
-	}
 
-	sr.cache.Add(r.RequestURI, song)
-	render.JSON(rw, r, song)
+	// the representation is cached, so that it isn't built on every hit
+	respondTagged(rw, r, mediaType, func(ctx context.Context) (interface{}, []string, error) {
+		song, err := sr.store.Songs().ByID(ctx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		body, err := sr.version.song(ctx, sr.store, song)
+		return body, songTags(song), err
+	})
 }
 
 func (sr *SongResource) UpdateSong(rw http.ResponseWriter, r *http.Request) {
-	song := new(models.Song)
-	if err := json.NewDecoder(r.Body).Decode(song); err != nil {
+	song, err := sr.version.decodeSong(r.Body)
+	if err != nil {
 		rw.WriteHeader(http.StatusUnprocessableEntity)
 		_, _ = fmt.Fprintf(rw, "Unknown err: %v", err)
 		return
 	}
 
+	if err := sr.updateSong(r.Context(), song); err != nil {
+		writeFailed(rw, err)
+	}
+}
+
+func (sr *SongResource) updateSong(ctx context.Context, song *models.Song) error {
 	if err := validateSong(song); err != nil {
-		rw.WriteHeader(http.StatusUnprocessableEntity)
-		_, _ = fmt.Fprintf(rw, "Validation err: %v", err)
-		return
+		return validationError(err)
 	}
 
-	if err := sr.store.Songs().Update(r.Context(), song); err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		_, _ = fmt.Fprintf(rw, "DB err: %v", err)
-		return
+	if err := sr.store.Songs().Update(ctx, song); err != nil {
+		return dbError(err)
 	}
 
-	if err := sr.broker.Cache().Purge(); err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		_, _ = fmt.Fprintf(rw, "Received error while purging cache: %v", err)
-		return
+	event := &models.Event{Type: models.EventSongUpdated, Song: song}
+	if err := invalidateCache(ctx, sr.broker, event); err != nil {
+		return cacheError(err)
 	}
+
+	publishEvent(ctx, sr.broker, sr.logger, event)
+	return nil
 }
 
 func (sr *SongResource) DeleteSong(rw http.ResponseWriter, r *http.Request) {
@@ -341,15 +562,21 @@ func (sr *SongResource) DeleteSong(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := sr.store.Songs().Delete(r.Context(), id); err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		_, _ = fmt.Fprintf(rw, "DB err: %v", err)
-		return
+	if err := sr.deleteSong(r.Context(), id); err != nil {
+		writeFailed(rw, err)
 	}
+}
 
-	if err := sr.broker.Cache().Purge(); err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		_, _ = fmt.Fprintf(rw, "Received error while purging cache: %v", err)
-		return
+func (sr *SongResource) deleteSong(ctx context.Context, id int) error {
+	if err := sr.store.Songs().Delete(ctx, id); err != nil {
+		return dbError(err)
 	}
+
+	event := &models.Event{Type: models.EventSongDeleted, Song: &models.Song{ID: id}}
+	if err := invalidateCache(ctx, sr.broker, event); err != nil {
+		return cacheError(err)
+	}
+
+	publishEvent(ctx, sr.broker, sr.logger, event)
+	return nil
 }
@@ -0,0 +1,120 @@
+package httpserver
+
+import (
+	"context"
+	"example/hello/project/internal/models"
+	"example/hello/project/internal/shutdown"
+	"example/hello/project/internal/store/inmemory"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	trackFound    = `{"message": {"header": {"status_code": 200}, "body": {"track_list": [{"track": {"track_id": 31409936, "album_name": "Waterloo"}}]}}}`
+	trackNotFound = `{"message": {"header": {"status_code": 200}, "body": {"track_list": []}}}`
+	lyricsFound   = `{"message": {"header": {"status_code": 200}, "body": {"lyrics": {"lyrics_body": "My, my, at Waterloo Napoleon did surrender"}}}}`
+	// Musixmatch reports the errors with 200 status as well
+	unauthorized = `{"message": {"header": {"status_code": 401}, "body": ""}}`
+)
+
+// newFakeMusixmatch responds to the search & lyrics calls of the saga, an empty response stands for 500
+func newFakeMusixmatch(t *testing.T, search, lyrics string) *httptest.Server {
+	t.Helper()
+
+	respond := func(response string) http.HandlerFunc {
+		return func(rw http.ResponseWriter, r *http.Request) {
+			if response == "" {
+				rw.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			_, _ = fmt.Fprint(rw, response)
+		}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws/1.1/track.search", func(rw http.ResponseWriter, r *http.Request) {
+		if q := r.URL.Query(); q.Get("apikey") != APIKey || q.Get("q_track") != "Waterloo" || q.Get("q_artist") != "ABBA" {
+			t.Errorf("got search query %v", r.URL.RawQuery)
+		}
+		respond(search)(rw, r)
+	})
+	mux.HandleFunc("/ws/1.1/track.lyrics.get", func(rw http.ResponseWriter, r *http.Request) {
+		if trackID := r.URL.Query().Get("track_id"); trackID != "31409936" {
+			t.Errorf("got lyrics of track %q, want 31409936", trackID)
+		}
+		respond(lyrics)(rw, r)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestCreateSongFetchesLyricsInBackground(t *testing.T) {
+	tests := []struct {
+		name       string
+		search     string
+		lyrics     string
+		wantLyrics string
+		wantAlbum  string
+	}{
+		{"fetched", trackFound, lyricsFound, "My, my, at Waterloo Napoleon did surrender", "Waterloo"},
+		{"song not found", trackNotFound, lyricsFound, models.LyricsStatusFailed, ""},
+		{"search refused", unauthorized, lyricsFound, models.LyricsStatusFailed, ""},
+		{"search failed", "", lyricsFound, models.LyricsStatusFailed, ""},
+		{"lyrics refused", trackFound, unauthorized, models.LyricsStatusFailed, "Waterloo"},
+		{"lyrics failed", trackFound, "", models.LyricsStatusFailed, "Waterloo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			musixmatch := newFakeMusixmatch(t, tt.search, tt.lyrics)
+			db := inmemory.NewDB()
+			if err := db.Artists().Create(context.Background(), &models.Artist{ID: 1, FullName: "ABBA"}); err != nil {
+				t.Fatal(err)
+			}
+			jobs := shutdown.NewManager(time.Second, zap.NewNop())
+			srv, _ := newTestServer(t,
+				WithStore(db),
+				WithShutdownManager(jobs),
+				WithLyricsAPI(LyricsAPI{RootURL: musixmatch.URL + "/ws/1.1", Client: musixmatch.Client()}),
+			)
+
+			// the client goes away as soon as the song is created
+			ctx, cancel := context.WithCancel(context.Background())
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+"/v1/songs",
+				strings.NewReader(`{"id": 1, "title": "Waterloo", "artist_id": 1, "lyrics": "la la la"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			cancel()
+			if resp.StatusCode != http.StatusCreated {
+				t.Fatalf("got status %v, want %v", resp.StatusCode, http.StatusCreated)
+			}
+
+			drainCtx, cancelDrain := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancelDrain()
+			if err := jobs.Drain(drainCtx); err != nil {
+				t.Fatal(err)
+			}
+
+			song, err := db.Songs().ByID(context.Background(), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if song.Lyrics != tt.wantLyrics || song.AlbumName != tt.wantAlbum {
+				t.Errorf("got lyrics %q of album %q, want %q of album %q", song.Lyrics, song.AlbumName, tt.wantLyrics, tt.wantAlbum)
+			}
+		})
+	}
+}
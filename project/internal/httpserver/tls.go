@@ -0,0 +1,177 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"go.uber.org/zap"
+	"os"
+	"sync"
+	"time"
+)
+
+// how often the certificate files are checked for changes
+const certReloadInterval = 10 * time.Second
+
+type tlsSettings struct {
+	certFile string
+	keyFile  string
+	// CA bundle the client certificates are verified against, mTLS is off when it's empty
+	clientCAFile string
+}
+
+func (ts *tlsSettings) files() []string {
+	files := []string{ts.certFile, ts.keyFile}
+	if ts.clientCAFile != "" {
+		files = append(files, ts.clientCAFile)
+	}
+
+	return files
+}
+
+// certReloader keeps the TLS config in sync with the files on disk, so that certificates can be rotated
+// without restarting the peer. If the new files can't be loaded (e.g. the key is not written yet),
+// the previous config is kept and the loading is retried on the next check
+type certReloader struct {
+	settings   tlsSettings
+	nextProtos []string
+	logger     *zap.Logger
+
+	mu       sync.RWMutex
+	config   *tls.Config
+	modTimes []time.Time
+}
+
+func newCertReloader(settings tlsSettings, nextProtos []string, logger *zap.Logger) (*certReloader, error) {
+	cr := &certReloader{
+		settings:   settings,
+		nextProtos: nextProtos,
+		logger:     logger,
+	}
+
+	modTimes, err := cr.stat()
+	if err != nil {
+		return nil, err
+	}
+	config, err := cr.load()
+	if err != nil {
+		return nil, err
+	}
+	cr.config, cr.modTimes = config, modTimes
+
+	return cr, nil
+}
+
+func (cr *certReloader) load() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cr.settings.certFile, cr.settings.keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   cr.nextProtos,
+	}
+
+	if cr.settings.clientCAFile != "" {
+		caPEM, err := os.ReadFile(cr.settings.clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in %v", cr.settings.clientCAFile)
+		}
+		config.ClientCAs = clientCAs
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+func (cr *certReloader) stat() ([]time.Time, error) {
+	files := cr.settings.files()
+	modTimes := make([]time.Time, 0, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+
+	return modTimes, nil
+}
+
+// reloadIfChanged loads the files again if any of them has been modified since the last successful load
+func (cr *certReloader) reloadIfChanged() error {
+	modTimes, err := cr.stat()
+	if err != nil {
+		return err
+	}
+
+	cr.mu.RLock()
+	changed := false
+	for i := range modTimes {
+		if !modTimes[i].Equal(cr.modTimes[i]) {
+			changed = true
+		}
+	}
+	cr.mu.RUnlock()
+	if !changed {
+		return nil
+	}
+
+	config, err := cr.load()
+	if err != nil {
+		return err
+	}
+
+	cr.mu.Lock()
+	cr.config, cr.modTimes = config, modTimes
+	cr.mu.Unlock()
+
+	cr.logger.Info("[HTTP] reloaded TLS certificates", zap.Strings("files", cr.settings.files()))
+	return nil
+}
+
+// watch checks the files until the context is done
+func (cr *certReloader) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cr.reloadIfChanged(); err != nil {
+				cr.logger.Warn("[HTTP] failed to reload TLS certificates, keeping the previous ones", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (cr *certReloader) current() *tls.Config {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+
+	return cr.config
+}
+
+// tlsConfig is the config of the server, every handshake gets the config loaded most recently
+func (cr *certReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: cr.nextProtos,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return &cr.current().Certificates[0], nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return cr.current(), nil
+		},
+	}
+}
@@ -0,0 +1,245 @@
+package httpserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA issues the certificates of the server and the clients
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: name},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	certPEM, keyPEM := issueCert(t, template, nil, nil)
+
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCA{cert: cert, key: pair.PrivateKey.(*ecdsa.PrivateKey), pem: certPEM}
+}
+
+// issue returns the PEM encoded certificate & key signed by the CA
+func (ca *testCA) issue(t *testing.T, name string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: name},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{usage},
+	}
+	return issueCert(t, template, ca.cert, ca.key)
+}
+
+// issueCert signs the template with the parent or self-signs it when the parent is nil
+func issueCert(t *testing.T, template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) ([]byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeFile writes the file with the given modification time, so that the change is seen
+// regardless of the resolution of the file system clock
+func writeFile(t *testing.T, name string, data []byte, modTime time.Time) {
+	t.Helper()
+
+	if err := os.WriteFile(name, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(name, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serveTLS serves the handler over TLS with the config of the reloader
+func serveTLS(t *testing.T, reloader *certReloader) string {
+	t.Helper()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", reloader.tlsConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			_, _ = rw.Write([]byte("pong"))
+		}),
+		// the refused handshakes are expected
+		ErrorLog: log.New(ioutil.Discard, "", 0),
+	}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { _ = server.Close() })
+
+	return "https://" + listener.Addr().String()
+}
+
+// served returns the common name of the certificate the server presents
+func served(t *testing.T, url string, ca *testCA, clientCert *tls.Certificate) (string, error) {
+	t.Helper()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	config := &tls.Config{RootCAs: roots}
+	if clientCert != nil {
+		config.Certificates = []tls.Certificate{*clientCert}
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: config, DisableKeepAlives: true}}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	return resp.TLS.PeerCertificates[0].Subject.CommonName, nil
+}
+
+func TestCertReloaderRotatesCertificates(t *testing.T) {
+	dir := t.TempDir()
+	settings := tlsSettings{certFile: filepath.Join(dir, "tls.crt"), keyFile: filepath.Join(dir, "tls.key")}
+	ca := newTestCA(t, "Lostify CA")
+	modTime := time.Now().Add(-time.Minute)
+
+	certPEM, keyPEM := ca.issue(t, "first", x509.ExtKeyUsageServerAuth)
+	writeFile(t, settings.certFile, certPEM, modTime)
+	writeFile(t, settings.keyFile, keyPEM, modTime)
+
+	reloader, err := newCertReloader(settings, []string{"http/1.1"}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := serveTLS(t, reloader)
+	if name, err := served(t, url, ca, nil); err != nil || name != "first" {
+		t.Fatalf("got certificate %q (%v), want the first one", name, err)
+	}
+
+	// nothing has changed
+	if err := reloader.reloadIfChanged(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the certificate is written before the key, the previous pair is kept meanwhile
+	certPEM, keyPEM = ca.issue(t, "second", x509.ExtKeyUsageServerAuth)
+	modTime = modTime.Add(time.Second)
+	writeFile(t, settings.certFile, certPEM, modTime)
+	if err := reloader.reloadIfChanged(); err == nil {
+		t.Error("want error for the certificate not matching the key")
+	}
+	if name, err := served(t, url, ca, nil); err != nil || name != "first" {
+		t.Fatalf("got certificate %q (%v), want the first one until the key is written", name, err)
+	}
+
+	writeFile(t, settings.keyFile, keyPEM, modTime)
+	if err := reloader.reloadIfChanged(); err != nil {
+		t.Fatal(err)
+	}
+	if name, err := served(t, url, ca, nil); err != nil || name != "second" {
+		t.Fatalf("got certificate %q (%v), want the rotated one", name, err)
+	}
+}
+
+func TestCertReloaderRequiresClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	settings := tlsSettings{
+		certFile:     filepath.Join(dir, "tls.crt"),
+		keyFile:      filepath.Join(dir, "tls.key"),
+		clientCAFile: filepath.Join(dir, "ca.crt"),
+	}
+	ca := newTestCA(t, "Lostify CA")
+	modTime := time.Now().Add(-time.Minute)
+
+	certPEM, keyPEM := ca.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	writeFile(t, settings.certFile, certPEM, modTime)
+	writeFile(t, settings.keyFile, keyPEM, modTime)
+	writeFile(t, settings.clientCAFile, ca.pem, modTime)
+
+	reloader, err := newCertReloader(settings, []string{"http/1.1"}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := serveTLS(t, reloader)
+
+	clientCert := func(ca *testCA) *tls.Certificate {
+		certPEM, keyPEM := ca.issue(t, "client", x509.ExtKeyUsageClientAuth)
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &cert
+	}
+
+	tests := []struct {
+		name    string
+		cert    *tls.Certificate
+		wantErr bool
+	}{
+		{"no certificate", nil, true},
+		{"certificate of another CA", clientCert(newTestCA(t, "Another CA")), true},
+		{"certificate of the client CA", clientCert(ca), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := served(t, url, ca, tt.cert)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
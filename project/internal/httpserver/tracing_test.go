@@ -0,0 +1,62 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+)
+
+func TestTraceRequests(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() { _ = provider.Shutdown(context.Background()) }()
+
+	r := chi.NewRouter()
+	r.Use(traceRequests)
+	r.Get("/songs/{id}", func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusInternalServerError)
+	})
+
+	// the caller's trace is continued
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/songs/42", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %v spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /songs/{id}" {
+		t.Errorf("got span name %q, want the route pattern", span.Name())
+	}
+	if got := span.SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("got trace %v, want the one of the caller %v", got, traceID)
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("got status %v for 500 response, want error", span.Status().Code)
+	}
+
+	attributes := make(map[string]interface{})
+	for _, attr := range span.Attributes() {
+		attributes[string(attr.Key)] = attr.Value.AsInterface()
+	}
+	if got := attributes[string(semconv.HTTPRouteKey)]; got != "/songs/{id}" {
+		t.Errorf("got route attribute %v", got)
+	}
+	if got := attributes[string(semconv.HTTPStatusCodeKey)]; got != int64(http.StatusInternalServerError) {
+		t.Errorf("got status code attribute %v", got)
+	}
+}
@@ -0,0 +1,280 @@
+// Lostify admin UI, works with /v1 JSON API of the server it's served from
+(function () {
+	"use strict";
+
+	const API = "../v1";
+	const PAGE_SIZE = 20;
+
+	// the same statuses the lyrics saga stores in place of the lyrics
+	const LYRICS_FETCHING = "fetching lyrics...";
+	const LYRICS_FAILED = "failed";
+
+	const state = {
+		songs: {offset: 0, query: ""},
+		artists: {offset: 0},
+		artistNames: new Map(),
+	};
+
+	const $ = (selector, root) => (root || document).querySelector(selector);
+
+	async function api(method, path, body) {
+		const options = {method, headers: {Accept: "application/json"}};
+		if (body !== undefined) {
+			options.headers["Content-Type"] = "application/json";
+			options.body = JSON.stringify(body);
+		}
+
+		const response = await fetch(API + path, options);
+		if (!response.ok) {
+			throw new Error(`${response.status}: ${await response.text()}`);
+		}
+		if (response.headers.get("Content-Type")?.startsWith("application/json")) {
+			return response.json();
+		}
+		return null;
+	}
+
+	function notify(message, isError) {
+		const notice = $("#notice");
+		notice.textContent = message;
+		notice.classList.toggle("error", !!isError);
+		notice.hidden = false;
+		clearTimeout(notify.timer);
+		notify.timer = setTimeout(() => notice.hidden = true, 5000);
+	}
+
+	// run reports the failure of the action instead of letting it go unnoticed
+	async function run(action) {
+		try {
+			await action();
+		} catch (err) {
+			notify(err.message, true);
+		}
+	}
+
+	function lyricsStatus(lyrics) {
+		switch (lyrics) {
+			case "":
+				return "pending";
+			case LYRICS_FETCHING:
+				return "fetching";
+			case LYRICS_FAILED:
+				return "failed";
+			default:
+				return "ready";
+		}
+	}
+
+	function cell(row, text) {
+		const td = row.insertCell();
+		td.textContent = text;
+		return td;
+	}
+
+	function actions(row, onEdit, onDelete) {
+		const td = row.insertCell();
+		td.className = "actions";
+		const edit = document.createElement("button");
+		edit.textContent = "Edit";
+		edit.onclick = onEdit;
+		const remove = document.createElement("button");
+		remove.textContent = "Delete";
+		remove.onclick = onDelete;
+		td.append(edit, " ", remove);
+	}
+
+	function updatePager(panel, offset, count) {
+		$(".page-info", panel).textContent = count ? `${offset + 1}–${offset + count}` : "nothing found";
+		$("[data-page=prev]", panel).disabled = offset === 0;
+		$("[data-page=next]", panel).disabled = count < PAGE_SIZE;
+	}
+
+	// artists are needed for the names in songs table and for the select of song dialog
+	async function loadArtistNames() {
+		state.artistNames.clear();
+		for (let offset = 0; ; offset += 100) {
+			const artists = await api("GET", `/artists?limit=100&offset=${offset}`) || [];
+			artists.forEach(artist => state.artistNames.set(artist.id, artist.full_name));
+			if (artists.length < 100) {
+				return;
+			}
+		}
+	}
+
+	async function loadSongs() {
+		const {offset, query} = state.songs;
+		const params = new URLSearchParams({limit: PAGE_SIZE, offset});
+		if (query) {
+			params.set("searchQuery", query);
+		}
+		const songs = await api("GET", `/songs?${params}`) || [];
+
+		const rows = $("#song-rows");
+		rows.replaceChildren();
+		songs.forEach(song => {
+			const row = rows.insertRow();
+			cell(row, song.id);
+			cell(row, song.title);
+			cell(row, state.artistNames.get(song.artist_id) || `#${song.artist_id}`);
+			cell(row, song.album_name);
+			const status = lyricsStatus(song.lyrics);
+			const badge = document.createElement("span");
+			badge.className = `status ${status}`;
+			badge.textContent = status;
+			badge.title = status === "ready" ? song.lyrics.slice(0, 200) : "";
+			row.insertCell().append(badge);
+			actions(row, () => editSong(song), () => run(() => deleteSong(song)));
+		});
+		updatePager($("#songs"), offset, songs.length);
+	}
+
+	async function loadArtists() {
+		const {offset} = state.artists;
+		const artists = await api("GET", `/artists?limit=${PAGE_SIZE}&offset=${offset}`) || [];
+
+		const rows = $("#artist-rows");
+		rows.replaceChildren();
+		artists.forEach(artist => {
+			const row = rows.insertRow();
+			cell(row, artist.id);
+			cell(row, artist.full_name);
+			actions(row, () => editArtist(artist), () => run(() => deleteArtist(artist)));
+		});
+		updatePager($("#artists"), offset, artists.length);
+	}
+
+	// openDialog resolves with the form values once saved, or with null if cancelled
+	function openDialog(dialog, title, values, isNew) {
+		const form = $("form", dialog);
+		$("h2", form).textContent = title;
+		form.reset();
+		Object.entries(values).forEach(([name, value]) => {
+			if (form.elements[name]) {
+				form.elements[name].value = value;
+			}
+		});
+		form.elements.id.readOnly = !isNew;
+
+		return new Promise(resolve => {
+			dialog.onclose = () => {
+				if (dialog.returnValue !== "save") {
+					resolve(null);
+					return;
+				}
+				resolve(Object.fromEntries(new FormData(form)));
+			};
+			dialog.returnValue = "";
+			dialog.showModal();
+		});
+	}
+
+	function fillArtistSelect(selected) {
+		const select = $("#song-form select[name=artist_id]");
+		select.replaceChildren();
+		[...state.artistNames.entries()].sort((a, b) => a[0] - b[0]).forEach(([id, name]) => {
+			select.add(new Option(`${name} (#${id})`, id, false, id === selected));
+		});
+	}
+
+	async function editSong(song) {
+		const isNew = !song;
+		await loadArtistNames();
+		fillArtistSelect(song && song.artist_id);
+
+		const dialog = $("#song-dialog");
+		// the lyrics of the new song are fetched by the server
+		$("textarea", dialog).closest("label").hidden = isNew;
+		$(".hint", dialog).hidden = !isNew;
+
+		const values = await openDialog(dialog, isNew ? "New song" : `Edit song #${song.id}`, song || {}, isNew);
+		if (!values) {
+			return;
+		}
+
+		await run(async () => {
+			const body = {
+				id: Number(values.id),
+				title: values.title,
+				artist_id: Number(values.artist_id),
+				album_name: values.album_name,
+				lyrics: isNew ? "" : values.lyrics,
+			};
+			notify(isNew ? "Creating the song and fetching its lyrics..." : "Saving...");
+			await api(isNew ? "POST" : "PUT", "/songs", body);
+			notify(isNew ? `Created song #${body.id}` : `Saved song #${body.id}`);
+			await loadSongs();
+		});
+	}
+
+	async function deleteSong(song) {
+		if (!confirm(`Delete song "${song.title}"?`)) {
+			return;
+		}
+		await api("DELETE", `/songs/${song.id}`);
+		notify(`Deleted song #${song.id}`);
+		await loadSongs();
+	}
+
+	async function editArtist(artist) {
+		const isNew = !artist;
+		const values = await openDialog($("#artist-dialog"), isNew ? "New artist" : `Edit artist #${artist.id}`, artist || {}, isNew);
+		if (!values) {
+			return;
+		}
+
+		await run(async () => {
+			const body = {id: Number(values.id), full_name: values.full_name};
+			await api(isNew ? "POST" : "PUT", "/artists", body);
+			notify(isNew ? `Created artist #${body.id}` : `Saved artist #${body.id}`);
+			await loadArtists();
+		});
+	}
+
+	async function deleteArtist(artist) {
+		if (!confirm(`Delete artist "${artist.full_name}"?`)) {
+			return;
+		}
+		await api("DELETE", `/artists/${artist.id}`);
+		notify(`Deleted artist #${artist.id}`);
+		await loadArtists();
+	}
+
+	function showTab(name) {
+		document.querySelectorAll(".tab").forEach(tab => tab.classList.toggle("active", tab.dataset.tab === name));
+		document.querySelectorAll(".panel").forEach(panel => panel.hidden = panel.id !== name);
+		run(name === "songs" ? () => loadArtistNames().then(loadSongs) : loadArtists);
+	}
+
+	document.querySelectorAll(".tab").forEach(tab => tab.onclick = () => showTab(tab.dataset.tab));
+
+	$("#song-search").onsubmit = event => {
+		event.preventDefault();
+		state.songs = {offset: 0, query: event.target.elements.q.value.trim()};
+		run(loadSongs);
+	};
+	$("#song-new").onclick = () => editSong(null);
+	$("#artist-new").onclick = () => editArtist(null);
+
+	document.querySelectorAll(".pager").forEach(pager => {
+		const panel = pager.closest(".panel").id;
+		const load = panel === "songs" ? loadSongs : loadArtists;
+		$("[data-page=prev]", pager).onclick = () => {
+			state[panel].offset = Math.max(0, state[panel].offset - PAGE_SIZE);
+			run(load);
+		};
+		$("[data-page=next]", pager).onclick = () => {
+			state[panel].offset += PAGE_SIZE;
+			run(load);
+		};
+	});
+
+	$("#purge").onclick = () => run(async () => {
+		const response = await fetch("cache/purge", {method: "POST"});
+		if (!response.ok) {
+			throw new Error(`${response.status}: ${await response.text()}`);
+		}
+		notify("Purged the caches of every peer");
+	});
+
+	showTab("songs");
+})();
@@ -0,0 +1,37 @@
+package httpserver
+
+import (
+	"context"
+	"example/hello/project/internal/models"
+	"example/hello/project/internal/store"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// apiVersion converts the internal models to and from the representation of a version of the API,
+// so that the shape of the responses could evolve without breaking the consumers of the older versions
+type apiVersion interface {
+	decodeSong(body io.Reader) (*models.Song, error)
+	decodeArtist(body io.Reader) (*models.Artist, error)
+
+	// the store is there for the versions which embed related resources
+	song(ctx context.Context, store store.Store, song *models.Song) (interface{}, error)
+	songs(ctx context.Context, store store.Store, songs []*models.Song) (interface{}, error)
+	artist(artist *models.Artist) interface{}
+	artists(artists []*models.Artist) interface{}
+	// artist with a page of its songs, as CSV only the songs are rendered
+	artistWithSongs(ctx context.Context, store store.Store, artist *models.Artist, songs []*models.Song) (interface{}, error)
+}
+
+// deprecated marks the responses of the unversioned routes, which are the alias of the successor version
+// https://datatracker.ietf.org/doc/draft-ietf-httpapi-deprecation-header/
+func deprecated(successor string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.Header().Set("Deprecation", "true")
+			rw.Header().Set("Link", fmt.Sprintf("<%v%v>; rel=\"successor-version\"", successor, r.URL.Path))
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"example/hello/project/internal/models"
+	"example/hello/project/internal/store/inmemory"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVersionedRepresentations(t *testing.T) {
+	db := inmemory.NewDB()
+	ctx := context.Background()
+	for _, artist := range []*models.Artist{{ID: 1, FullName: "ABBA"}, {ID: 2, FullName: "Queen"}} {
+		if err := db.Artists().Create(ctx, artist); err != nil {
+			t.Fatal(err)
+		}
+	}
+	songs := []*models.Song{
+		{ID: 1, Title: "Waterloo", ArtistID: 1, Lyrics: "My, my, at Waterloo Napoleon did surrender", AlbumName: "Waterloo"},
+		{ID: 2, Title: "Mamma Mia", ArtistID: 1, Lyrics: models.LyricsStatusFetching},
+		{ID: 3, Title: "SOS", ArtistID: 1, Lyrics: models.LyricsStatusFailed},
+		{ID: 4, Title: "Bohemian Rhapsody", ArtistID: 2},
+	}
+	for _, song := range songs {
+		if err := db.Songs().Create(ctx, song); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the song is left with the ID of the artist only
+	if err := db.Artists().Delete(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	srv, _ := newTestServer(t, WithStore(db))
+
+	tests := []struct {
+		path           string
+		want           string
+		wantDeprecated bool
+	}{
+		{"/v1/songs/1", `{"id": 1, "title": "Waterloo", "artist_id": 1, "lyrics": "My, my, at Waterloo Napoleon did surrender", "album_name": "Waterloo"}`, false},
+		{"/v1/songs/2", `{"id": 2, "title": "Mamma Mia", "artist_id": 1, "lyrics": "fetching lyrics...", "album_name": ""}`, false},
+		{"/v1/artists/1", `{"id": 1, "full_name": "ABBA"}`, false},
+		{"/v2/songs/1", `{"id": 1, "title": "Waterloo", "artist": {"id": 1, "full_name": "ABBA"}, "album_name": "Waterloo",
+			"lyrics": {"status": "ready", "text": "My, my, at Waterloo Napoleon did surrender"}}`, false},
+		{"/v2/songs/2", `{"id": 2, "title": "Mamma Mia", "artist": {"id": 1, "full_name": "ABBA"}, "lyrics": {"status": "fetching"}}`, false},
+		{"/v2/songs/3", `{"id": 3, "title": "SOS", "artist": {"id": 1, "full_name": "ABBA"}, "lyrics": {"status": "failed"}}`, false},
+		{"/v2/songs/4", `{"id": 4, "title": "Bohemian Rhapsody", "artist": {"id": 2}, "lyrics": {"status": "pending"}}`, false},
+		{"/v2/artists/1", `{"id": 1, "full_name": "ABBA"}`, false},
+		// the unversioned routes are the alias of v1
+		{"/songs/1", `{"id": 1, "title": "Waterloo", "artist_id": 1, "lyrics": "My, my, at Waterloo Napoleon did surrender", "album_name": "Waterloo"}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("got status %v, want %v", resp.StatusCode, http.StatusOK)
+			}
+
+			var got, want interface{}
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+
+			deprecated := resp.Header.Get("Deprecation") == "true"
+			if deprecated != tt.wantDeprecated {
+				t.Errorf("got deprecated %v, want %v", deprecated, tt.wantDeprecated)
+			}
+			if link := "</v1" + tt.path + `>; rel="successor-version"`; tt.wantDeprecated && resp.Header.Get("Link") != link {
+				t.Errorf("got link %q, want %q", resp.Header.Get("Link"), link)
+			}
+		})
+	}
+}
+
+func TestVersionedDecoding(t *testing.T) {
+	tests := []struct {
+		name       string
+		version    apiVersion
+		body       string
+		wantSong   models.Song
+		wantArtist models.Artist
+	}{
+		{
+			"v1", apiV1{},
+			`{"id": 1, "title": "Waterloo", "artist_id": 1, "full_name": "ABBA", "lyrics": "la la la", "album_name": "Waterloo"}`,
+			models.Song{ID: 1, Title: "Waterloo", ArtistID: 1, Lyrics: "la la la", AlbumName: "Waterloo"},
+			models.Artist{ID: 1, FullName: "ABBA"},
+		},
+		// the artist is only referred to by its ID, the lyrics are only fetched by the server
+		{
+			"v2", apiV2{},
+			`{"id": 1, "title": "Waterloo", "artist": {"id": 2, "full_name": "Queen"}, "full_name": "ABBA",
+				"lyrics": {"status": "ready", "text": "la la la"}, "album_name": "Waterloo"}`,
+			models.Song{ID: 1, Title: "Waterloo", ArtistID: 2, AlbumName: "Waterloo"},
+			models.Artist{ID: 1, FullName: "ABBA"},
+		},
+		{
+			"v2 without artist", apiV2{},
+			`{"id": 1, "title": "Waterloo"}`,
+			models.Song{ID: 1, Title: "Waterloo"},
+			models.Artist{ID: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			song, err := tt.version.decodeSong(strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *song != tt.wantSong {
+				t.Errorf("got song %+v, want %+v", *song, tt.wantSong)
+			}
+
+			artist, err := tt.version.decodeArtist(strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *artist != tt.wantArtist {
+				t.Errorf("got artist %+v, want %+v", *artist, tt.wantArtist)
+			}
+
+			if _, err := tt.version.decodeSong(strings.NewReader(`{"id": "one"}`)); err == nil {
+				t.Error("want error for the malformed song")
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package httpserver
+
+import (
+	"context"
+	"example/hello/project/internal/shutdown"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// the access counts are halved once this many responses are tracked, the rarely requested ones are dropped
+const maxTrackedResponses = 1024
+
+type warmingSettings struct {
+	// requests warmed up in JSON representation regardless of the statistics, e.g. /v1/songs
+	targets []string
+	// number of the most requested responses warmed up besides the targets
+	top         int
+	concurrency int
+}
+
+// warmTarget is the anonymous GET request of the response
+type warmTarget struct {
+	target    string
+	mediaType string
+}
+
+// accessStats counts the requests of the cacheable responses, so that the warmer knows which ones are hot
+type accessStats struct {
+	mu     sync.Mutex
+	counts map[warmTarget]int
+}
+
+func newAccessStats() *accessStats {
+	return &accessStats{counts: make(map[warmTarget]int)}
+}
+
+func (as *accessStats) record(target warmTarget) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	as.counts[target]++
+	if len(as.counts) <= maxTrackedResponses {
+		return
+	}
+
+	// the responses which were hot long ago fade away
+	for t, count := range as.counts {
+		if count /= 2; count == 0 {
+			delete(as.counts, t)
+			continue
+		}
+		as.counts[t] = count
+	}
+}
+
+// top returns the n most requested responses, the most requested first
+func (as *accessStats) top(n int) []warmTarget {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	targets := make([]warmTarget, 0, len(as.counts))
+	for t := range as.counts {
+		targets = append(targets, t)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		if as.counts[targets[i]] != as.counts[targets[j]] {
+			return as.counts[targets[i]] > as.counts[targets[j]]
+		}
+		return targets[i].target < targets[j].target
+	})
+
+	if len(targets) > n {
+		targets = targets[:n]
+	}
+	return targets
+}
+
+// countAccesses records the anonymous requests served through the response cache
+func countAccesses(stats *accessStats) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(rw, r)
+
+			if r.Method != http.MethodGet || r.Header.Get("Authorization") != "" {
+				return
+			}
+			switch rw.Header().Get("X-Cache") {
+			case "HIT", "MISS", "STALE":
+			default:
+				return
+			}
+
+			mediaType, _ := negotiate(r)
+			target := r.URL.Path
+			if query := r.URL.Query().Encode(); query != "" {
+				target += "?" + query
+			}
+			stats.record(warmTarget{target: target, mediaType: mediaType})
+		})
+	}
+}
+
+// cacheWarmer preloads the responses into the cache, so that the first users after a restart
+// or a purge don't pay for the store. The requests are served in-process by the catalog routes alone
+type cacheWarmer struct {
+	settings warmingSettings
+	handler  http.Handler
+	stats    *accessStats
+	jobs     *shutdown.Manager
+	logger   *zap.Logger
+
+	mu sync.Mutex
+	// warming up is in progress, the purges meanwhile make it run once again afterwards
+	running bool
+	again   bool
+}
+
+func newCacheWarmer(settings warmingSettings, handler http.Handler, stats *accessStats, jobs *shutdown.Manager, logger *zap.Logger) *cacheWarmer {
+	if settings.concurrency < 1 {
+		settings.concurrency = 1
+	}
+
+	return &cacheWarmer{
+		settings: settings,
+		handler:  handler,
+		stats:    stats,
+		jobs:     jobs,
+		logger:   logger,
+	}
+}
+
+// start warms the cache up in the background, unless it's being warmed up already
+func (cw *cacheWarmer) start() {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	if cw.running {
+		cw.again = true
+		return
+	}
+	cw.running = true
+
+	cw.jobs.Go("cache warming", func(ctx context.Context) {
+		for {
+			cw.warm(ctx)
+
+			cw.mu.Lock()
+			if !cw.again || ctx.Err() != nil {
+				cw.running, cw.again = false, false
+				cw.mu.Unlock()
+				return
+			}
+			cw.again = false
+			cw.mu.Unlock()
+		}
+	})
+}
+
+func (cw *cacheWarmer) targets() []warmTarget {
+	seen := make(map[warmTarget]bool)
+	var targets []warmTarget
+	for _, target := range cw.settings.targets {
+		t := warmTarget{target: target, mediaType: mediaTypeJSON}
+		if !seen[t] {
+			seen[t] = true
+			targets = append(targets, t)
+		}
+	}
+	for _, t := range cw.stats.top(cw.settings.top) {
+		if !seen[t] {
+			seen[t] = true
+			targets = append(targets, t)
+		}
+	}
+
+	return targets
+}
+
+// warm requests the targets, at most settings.concurrency of them at once
+func (cw *cacheWarmer) warm(ctx context.Context) {
+	start := time.Now()
+	targets := cw.targets()
+
+	var failed int64
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, cw.settings.concurrency)
+	for _, target := range targets {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			// the peer is shutting down
+			wg.Wait()
+			return
+		}
+
+		wg.Add(1)
+		go func(target warmTarget) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			if err := cw.load(ctx, target); err != nil {
+				atomic.AddInt64(&failed, 1)
+				cw.logger.Warn("[Cache] failed to warm up", zap.String("target", target.target), zap.Error(err))
+			}
+		}(target)
+	}
+	wg.Wait()
+
+	cw.logger.Info("[Cache] warmed up",
+		zap.Int("responses", len(targets)),
+		zap.Int64("failed", atomic.LoadInt64(&failed)),
+		zap.Duration("took", time.Since(start)),
+	)
+}
+
+// load serves the request bypassing the cached response, so that the fresh one is cached
+func (cw *cacheWarmer) load(ctx context.Context, target warmTarget) error {
+	if !strings.HasPrefix(target.target, "/") {
+		return fmt.Errorf("target %q is not a path", target.target)
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, target.target, nil)
+	if err != nil {
+		return err
+	}
+	r.RequestURI = target.target
+	r.Header.Set("Accept", target.mediaType)
+	r.Header.Set("Cache-Control", "no-cache")
+
+	rec := &responseRecorder{header: make(http.Header)}
+	cw.handler.ServeHTTP(rec, r)
+	if rec.status != 0 && rec.status != http.StatusOK {
+		return fmt.Errorf("status %v: %s", rec.status, rec.body)
+	}
+
+	return nil
+}
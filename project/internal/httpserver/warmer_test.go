@@ -0,0 +1,165 @@
+package httpserver
+
+import (
+	"context"
+	"example/hello/project/internal/cache"
+	inmemorybroker "example/hello/project/internal/message_broker/inmemory"
+	"example/hello/project/internal/shutdown"
+	"example/hello/project/internal/store/inmemory"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowHandler takes a while to respond, so that the concurrent requests overlap
+type slowHandler struct {
+	mu          sync.Mutex
+	requests    []string
+	inFlight    int
+	maxInFlight int
+}
+
+func (h *slowHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	h.requests = append(h.requests, r.Header.Get("Accept")+" "+r.RequestURI)
+	if h.inFlight++; h.inFlight > h.maxInFlight {
+		h.maxInFlight = h.inFlight
+	}
+	h.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	h.mu.Lock()
+	h.inFlight--
+	h.mu.Unlock()
+}
+
+func TestCacheWarmerWarm(t *testing.T) {
+	tests := []struct {
+		name         string
+		settings     warmingSettings
+		wantRequests []string
+		// the most requests served at once
+		wantInFlight int
+	}{
+		{
+			"targets",
+			warmingSettings{targets: []string{"/v1/songs", "/v1/artists", "/v1/songs", "songs"}, concurrency: 4},
+			[]string{"application/json /v1/artists", "application/json /v1/songs"},
+			2,
+		},
+		{
+			"targets and top",
+			warmingSettings{targets: []string{"/v1/songs"}, top: 2, concurrency: 4},
+			[]string{"application/json /v1/songs", "application/json /v2/songs/1", "text/csv /v1/songs"},
+			3,
+		},
+		{
+			"one at a time",
+			warmingSettings{targets: []string{"/v1/songs", "/v1/artists", "/v2/songs", "/v2/artists"}, concurrency: 1},
+			[]string{"application/json /v1/artists", "application/json /v1/songs", "application/json /v2/artists", "application/json /v2/songs"},
+			1,
+		},
+		{
+			"no concurrency",
+			warmingSettings{targets: []string{"/v1/songs", "/v1/artists"}},
+			[]string{"application/json /v1/artists", "application/json /v1/songs"},
+			1,
+		},
+		{
+			"bounded",
+			warmingSettings{targets: []string{"/v1/songs", "/v1/artists", "/v2/songs", "/v2/artists", "/songs", "/artists"}, concurrency: 2},
+			[]string{"application/json /artists", "application/json /songs", "application/json /v1/artists", "application/json /v1/songs", "application/json /v2/artists", "application/json /v2/songs"},
+			2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := newAccessStats()
+			for i := 0; i < 3; i++ {
+				stats.record(warmTarget{target: "/v2/songs/1", mediaType: mediaTypeJSON})
+			}
+			stats.record(warmTarget{target: "/v1/songs", mediaType: mediaTypeCSV})
+			stats.record(warmTarget{target: "/v1/songs", mediaType: mediaTypeCSV})
+			stats.record(warmTarget{target: "/v1/artists/1", mediaType: mediaTypeJSON})
+
+			handler := &slowHandler{}
+			jobs := shutdown.NewManager(time.Second, zap.NewNop())
+			newCacheWarmer(tt.settings, handler, stats, jobs, zap.NewNop()).warm(context.Background())
+
+			sort.Strings(handler.requests)
+			if len(handler.requests) != len(tt.wantRequests) {
+				t.Fatalf("got requests %v, want %v", handler.requests, tt.wantRequests)
+			}
+			for i := range tt.wantRequests {
+				if handler.requests[i] != tt.wantRequests[i] {
+					t.Errorf("got requests %v, want %v", handler.requests, tt.wantRequests)
+					break
+				}
+			}
+			if handler.maxInFlight != tt.wantInFlight {
+				t.Errorf("got %v requests at once, want %v", handler.maxInFlight, tt.wantInFlight)
+			}
+		})
+	}
+}
+
+// waitCached polls the cache, since the warming runs in the background
+func waitCached(t *testing.T, c cache.TaggedCache, keys ...string) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for _, key := range keys {
+		for !c.Contains(key) {
+			if time.Now().After(deadline) {
+				t.Fatalf("%v isn't warmed up, cached %v", key, c.Keys())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func TestCacheWarmingAfterPurge(t *testing.T) {
+	lru, err := cache.New(cache.Config{Strategy: cache.StrategyLRU, Size: 128})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appCache := cache.NewTagged(lru)
+	jobs := shutdown.NewManager(time.Second, zap.NewNop())
+	defer jobs.Shutdown()
+	s := NewServer(context.Background(),
+		WithStore(inmemory.NewDB()),
+		WithCache(appCache),
+		WithBroker(inmemorybroker.NewBroker(appCache, "peer0", nil, zap.NewNop())),
+		WithShutdownManager(jobs),
+		WithCacheWarming([]string{"/v1/songs", "/v2/artists"}, 0, 2),
+	)
+	keys := []string{"GET application/json anonymous /v1/songs", "GET application/json anonymous /v2/artists"}
+
+	s.startCacheWarming()
+	waitCached(t, appCache, keys...)
+
+	appCache.Purge()
+	waitCached(t, appCache, keys...)
+
+	// the purge coming from another peer warms the cache up as well
+	appCache.PurgeFrom("peer1")
+	waitCached(t, appCache, keys...)
+
+	// the requests of the warmer aren't taken for the ones of the users
+	srv := httptest.NewServer(s.basicHandler())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/v1/artists")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if top := s.accessStats.top(10); len(top) != 1 || top[0].target != "/v1/artists" {
+		t.Errorf("got the most requested %v, want only /v1/artists", top)
+	}
+}
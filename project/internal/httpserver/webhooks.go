@@ -0,0 +1,183 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"example/hello/project/internal/models"
+	"example/hello/project/internal/store"
+	"example/hello/project/internal/webhooks"
+	"fmt"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+	"net/http"
+	"strconv"
+)
+
+// WebhookResource manages the subscriptions of partner services, deliveries themselves are made by webhooks.Dispatcher
+type WebhookResource struct {
+	store store.Store
+}
+
+func NewWebhookResource(store store.Store) *WebhookResource {
+	return &WebhookResource{
+		store: store,
+	}
+}
+
+func (wr *WebhookResource) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Post("/", wr.CreateWebhook)
+	r.Get("/", wr.AllWebhooks)
+	r.Get("/dead-letters", wr.DeadLetters)
+	r.Get("/{id}", wr.ByID)
+	r.Get("/{id}/deliveries", wr.Deliveries)
+	r.Put("/", wr.UpdateWebhook)
+	r.Delete("/{id}", wr.DeleteWebhook)
+
+	return r
+}
+
+func validateWebhook(webhook *models.Webhook) error {
+	eventTypes := make([]interface{}, len(models.EventTypes))
+	for i, eventType := range models.EventTypes {
+		eventTypes[i] = eventType
+	}
+
+	return validation.ValidateStruct(
+		webhook,
+		validation.Field(&webhook.ID, validation.Required),
+		validation.Field(&webhook.URL, validation.Required, is.RequestURL, validation.By(webhooks.ValidateURL)),
+		validation.Field(&webhook.Secret, validation.Required),
+		validation.Field(&webhook.EventTypes, validation.Required, validation.Each(validation.In(eventTypes...))),
+	)
+}
+
+// the secret is only ever written, never rendered back
+func hideSecrets(webhooks ...*models.Webhook) {
+	for _, webhook := range webhooks {
+		webhook.Secret = ""
+	}
+}
+
+func (wr *WebhookResource) CreateWebhook(rw http.ResponseWriter, r *http.Request) {
+	webhook := new(models.Webhook)
+	if err := json.NewDecoder(r.Body).Decode(webhook); err != nil {
+		rw.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = fmt.Fprintf(rw, "Unknown err: %v", err)
+		return
+	}
+
+	if err := validateWebhook(webhook); err != nil {
+		rw.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = fmt.Fprintf(rw, "Validation err: %v", err)
+		return
+	}
+
+	if err := wr.store.Webhooks().Create(r.Context(), webhook); err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprintf(rw, "DB err: %v", err)
+		return
+	}
+
+	rw.WriteHeader(http.StatusCreated)
+}
+
+func (wr *WebhookResource) AllWebhooks(rw http.ResponseWriter, r *http.Request) {
+	webhooks, err := wr.store.Webhooks().All(r.Context())
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprintf(rw, "DB err: %v", err)
+		return
+	}
+
+	hideSecrets(webhooks...)
+	render.JSON(rw, r, webhooks)
+}
+
+func (wr *WebhookResource) ByID(rw http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintf(rw, "Unknown err: %v", err)
+		return
+	}
+
+	webhook, err := wr.store.Webhooks().ByID(r.Context(), id)
+	if err != nil {
+		storeError(rw, err)
+		return
+	}
+
+	hideSecrets(webhook)
+	render.JSON(rw, r, webhook)
+}
+
+func (wr *WebhookResource) UpdateWebhook(rw http.ResponseWriter, r *http.Request) {
+	webhook := new(models.Webhook)
+	if err := json.NewDecoder(r.Body).Decode(webhook); err != nil {
+		rw.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = fmt.Fprintf(rw, "Unknown err: %v", err)
+		return
+	}
+
+	if err := validateWebhook(webhook); err != nil {
+		rw.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = fmt.Fprintf(rw, "Validation err: %v", err)
+		return
+	}
+
+	if err := wr.store.Webhooks().Update(r.Context(), webhook); err != nil {
+		storeError(rw, err)
+		return
+	}
+}
+
+func (wr *WebhookResource) DeleteWebhook(rw http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintf(rw, "Unknown err: %v", err)
+		return
+	}
+
+	if err := wr.store.Webhooks().Delete(r.Context(), id); err != nil {
+		storeError(rw, err)
+		return
+	}
+}
+
+// Deliveries returns the most recent delivery attempts made to the webhook
+func (wr *WebhookResource) Deliveries(rw http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintf(rw, "Unknown err: %v", err)
+		return
+	}
+
+	deliveries, err := wr.store.Webhooks().Deliveries(r.Context(), id)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprintf(rw, "DB err: %v", err)
+		return
+	}
+
+	render.JSON(rw, r, deliveries)
+}
+
+// DeadLetters returns the deliveries that have failed on every attempt
+func (wr *WebhookResource) DeadLetters(rw http.ResponseWriter, r *http.Request) {
+	deliveries, err := wr.store.Webhooks().DeadLetters(r.Context())
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprintf(rw, "DB err: %v", err)
+		return
+	}
+
+	render.JSON(rw, r, deliveries)
+}
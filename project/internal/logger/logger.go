@@ -0,0 +1,57 @@
+// Zap, structured & leveled logger: https://github.com/uber-go/zap
+
+package logger
+
+import (
+	"context"
+	"github.com/go-chi/chi/middleware"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type ctxKey struct{}
+
+// New builds a JSON logger whose level can be changed at runtime through the returned AtomicLevel
+// (it's also an http.Handler that responds to GET and PUT requests with {"level":"debug"} body)
+func New(level string) (*zap.Logger, zap.AtomicLevel, error) {
+	atomicLevel := zap.NewAtomicLevel()
+	if level != "" {
+		if err := atomicLevel.UnmarshalText([]byte(level)); err != nil {
+			return nil, atomicLevel, err
+		}
+	}
+
+	config := zap.NewProductionConfig()
+	config.Level = atomicLevel
+	config.EncoderConfig.TimeKey = "time"
+	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	l, err := config.Build()
+	if err != nil {
+		return nil, atomicLevel, err
+	}
+
+	return l, atomicLevel, nil
+}
+
+// WithContext returns a copy of ctx that carries the logger l
+func WithContext(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx (e.g. the one enriched with request ID by the HTTP server).
+// If there is none, fallback is returned with request ID attached (if ctx has it).
+func FromContext(ctx context.Context, fallback *zap.Logger) *zap.Logger {
+	if l, ok := ctx.Value(ctxKey{}).(*zap.Logger); ok {
+		return l
+	}
+
+	if fallback == nil {
+		fallback = zap.NewNop()
+	}
+	if requestID := middleware.GetReqID(ctx); requestID != "" {
+		return fallback.With(zap.String("request_id", requestID))
+	}
+
+	return fallback
+}
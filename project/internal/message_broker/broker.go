@@ -6,12 +6,15 @@ type (
 	MessageBroker interface {
 		Connect(ctx context.Context) error
 		Close() error
+		Ping(ctx context.Context) error
 
 		Cache() CacheBroker
+		Events() EventsBroker
 	}
 
 	BrokerWithClient interface {
 		Connect(ctx context.Context, brokers []string) error
 		Close() error
+		Ping(ctx context.Context) error
 	}
 )
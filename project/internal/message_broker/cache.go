@@ -1,7 +1,11 @@
 package message_broker
 
+import "context"
+
 type CacheBroker interface {
 	BrokerWithClient
-	Remove(key interface{}) error
-	Purge() error
+	Remove(ctx context.Context, key interface{}) error
+	Purge(ctx context.Context) error
+	// InvalidateTags removes the cached entries with any of the tags on every peer
+	InvalidateTags(ctx context.Context, tags ...string) error
 }
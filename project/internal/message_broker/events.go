@@ -0,0 +1,87 @@
+package message_broker
+
+import (
+	"context"
+	"example/hello/project/internal/models"
+	"sync"
+)
+
+type EventsBroker interface {
+	BrokerWithClient
+	// Publish delivers the event to every peer, including this one
+	Publish(ctx context.Context, event *models.Event) error
+	// Subscribe returns the events received by this peer after the one with sequence number afterSeq
+	// (those still kept in the backlog are replayed first). cancel must be called to stop receiving them.
+	Subscribe(afterSeq uint64) (events <-chan *models.Event, cancel func())
+	// Origin is the ID the events published by this run of the peer are stamped with
+	Origin() string
+}
+
+const (
+	// subscribers that can't keep up lose the events that don't fit into their buffer
+	subscriberBufferSize = 64
+	// number of the most recent events kept for replaying to reconnecting subscribers
+	backlogSize = 256
+)
+
+// EventHub fans out the events received from the broker to local subscribers
+type EventHub struct {
+	mu          sync.Mutex
+	seq         uint64
+	backlog     []*models.Event
+	subscribers map[chan *models.Event]struct{}
+}
+
+func NewEventHub() *EventHub {
+	return &EventHub{
+		subscribers: make(map[chan *models.Event]struct{}),
+	}
+}
+
+// Broadcast assigns the next sequence number to the event and sends it to all the subscribers
+func (h *EventHub) Broadcast(event *models.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	event.Seq = h.seq
+
+	h.backlog = append(h.backlog, event)
+	if len(h.backlog) > backlogSize {
+		h.backlog = h.backlog[len(h.backlog)-backlogSize:]
+	}
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+func (h *EventHub) Subscribe(afterSeq uint64) (<-chan *models.Event, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan *models.Event, subscriberBufferSize+backlogSize)
+	if afterSeq > 0 {
+		for _, event := range h.backlog {
+			if event.Seq > afterSeq {
+				ch <- event
+			}
+		}
+	}
+	h.subscribers[ch] = struct{}{}
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			delete(h.subscribers, ch)
+			close(ch)
+		})
+	}
+
+	return ch, cancel
+}
@@ -0,0 +1,112 @@
+// in-memory message broker of a single peer, the messages never leave the process;
+// handy for running the server locally without Kafka and for testing against it
+
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"example/hello/project/internal/cache"
+	"example/hello/project/internal/message_broker"
+	"example/hello/project/internal/metrics"
+	"go.uber.org/zap"
+	"sync"
+)
+
+var ErrClosed = errors.New("message broker is closed")
+
+// names of the topics the messages would go to with Kafka, used by the metrics
+const (
+	cacheTopic  = "cache"
+	eventsTopic = "events"
+)
+
+type Broker struct {
+	clientID string
+
+	cacheBroker  message_broker.CacheBroker
+	eventsBroker message_broker.EventsBroker
+	cache        cache.TaggedCache
+	metrics      *metrics.Metrics
+	logger       *zap.Logger
+}
+
+func NewBroker(cache cache.TaggedCache, clientID string, metrics *metrics.Metrics, logger *zap.Logger) message_broker.MessageBroker {
+	return &Broker{cache: cache, clientID: clientID, metrics: metrics, logger: logger}
+}
+
+func (b *Broker) Connect(ctx context.Context) error {
+	brokers := []message_broker.BrokerWithClient{b.Cache(), b.Events()}
+
+	for _, broker := range brokers {
+		if err := broker.Connect(ctx, nil); err != nil {
+			return err
+		}
+	}
+	b.logger.Info("[MessageBroker] running in memory, the messages are only delivered to this peer")
+
+	return nil
+}
+
+func (b *Broker) Close() error {
+	brokers := []message_broker.BrokerWithClient{b.Cache(), b.Events()}
+
+	for _, broker := range brokers {
+		if err := broker.Close(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *Broker) Ping(ctx context.Context) error {
+	brokers := []message_broker.BrokerWithClient{b.Cache(), b.Events()}
+
+	for _, broker := range brokers {
+		if err := broker.Ping(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *Broker) Cache() message_broker.CacheBroker {
+	if b.cacheBroker == nil {
+		b.cacheBroker = NewCacheBroker(b.cache, b.clientID, b.metrics)
+	}
+
+	return b.cacheBroker
+}
+
+func (b *Broker) Events() message_broker.EventsBroker {
+	if b.eventsBroker == nil {
+		b.eventsBroker = NewEventsBroker(b.clientID, b.metrics)
+	}
+
+	return b.eventsBroker
+}
+
+// connection tells whether the broker is usable, the messages are refused once it's closed
+type connection struct {
+	mu     sync.RWMutex
+	closed bool
+}
+
+func (c *connection) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+}
+
+func (c *connection) ping() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return ErrClosed
+	}
+	return nil
+}
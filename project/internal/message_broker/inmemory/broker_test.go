@@ -0,0 +1,198 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"example/hello/project/internal/cache"
+	"example/hello/project/internal/message_broker"
+	"example/hello/project/internal/metrics"
+	"example/hello/project/internal/models"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestBroker(t *testing.T, m *metrics.Metrics) (message_broker.MessageBroker, *cache.Tagged) {
+	t.Helper()
+
+	lru, err := cache.New(cache.Config{Strategy: cache.StrategyLRU, Size: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagged := cache.NewTagged(lru)
+	broker := NewBroker(tagged, "peer0", m, zap.NewNop())
+	if err := broker.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	return broker, tagged
+}
+
+func TestCacheBroker(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  func(ctx context.Context, c message_broker.CacheBroker) error
+		wantKeys []string
+		// peer reported by the stats of the cache as the one which has requested the purge
+		wantPurgeOrigin string
+	}{
+		{
+			name:     "remove",
+			command:  func(ctx context.Context, c message_broker.CacheBroker) error { return c.Remove(ctx, "songs") },
+			wantKeys: []string{"song 1", "song 2"},
+		},
+		{
+			name:     "invalidate tags",
+			command:  func(ctx context.Context, c message_broker.CacheBroker) error { return c.InvalidateTags(ctx, "song:1") },
+			wantKeys: []string{"song 2", "songs"},
+		},
+		{
+			name:            "purge",
+			command:         func(ctx context.Context, c message_broker.CacheBroker) error { return c.Purge(ctx) },
+			wantPurgeOrigin: "peer0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker, tagged := newTestBroker(t, nil)
+			tagged.AddWithTags("song 1", "Waterloo", "song:1")
+			tagged.AddWithTags("song 2", "Mamma Mia", "song:2")
+			tagged.Add("songs", "Waterloo, Mamma Mia")
+
+			// the command is applied by the time it's sent, there are no other peers to wait for
+			if err := tt.command(context.Background(), broker.Cache()); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, key := range []string{"song 1", "song 2", "songs"} {
+				want := false
+				for _, kept := range tt.wantKeys {
+					want = want || kept == key
+				}
+				if got := tagged.Contains(key); got != want {
+					t.Errorf("%v: got cached %v, want %v", key, got, want)
+				}
+			}
+			if origin := tagged.Stats().LastPurgeOrigin; origin != tt.wantPurgeOrigin {
+				t.Errorf("got purge origin %q, want %q", origin, tt.wantPurgeOrigin)
+			}
+		})
+	}
+}
+
+func TestEventsBroker(t *testing.T) {
+	broker, _ := newTestBroker(t, nil)
+	events, cancel := broker.Events().Subscribe(0)
+	defer cancel()
+
+	published := []*models.Event{
+		{Type: models.EventSongCreated, Song: &models.Song{ID: 1}},
+		{Type: models.EventSongDeleted, Origin: "peer1", Song: &models.Song{ID: 1}},
+	}
+	for _, event := range published {
+		if err := broker.Events().Publish(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, want := range published {
+		select {
+		case event := <-events:
+			// the events are sequenced and stamped by this peer, whatever the publisher has set
+			if event.Type != want.Type || event.Seq != uint64(i+1) || event.Origin != "peer0" || event.Time.IsZero() {
+				t.Errorf("got event %+v, want %v #%v from peer0", event, want.Type, i+1)
+			}
+			if event == want {
+				t.Error("got the published event, want a copy of it")
+			}
+		default:
+			t.Fatalf("event %v isn't delivered", want.Type)
+		}
+	}
+
+	// the subscribers which reconnect get the events they've missed
+	replayed, cancelReplay := broker.Events().Subscribe(1)
+	defer cancelReplay()
+	select {
+	case event := <-replayed:
+		if event.Seq != 2 {
+			t.Errorf("got event #%v replayed, want #2", event.Seq)
+		}
+	default:
+		t.Fatal("the missed event isn't replayed")
+	}
+}
+
+func TestBrokerClosed(t *testing.T) {
+	broker, tagged := newTestBroker(t, nil)
+	tagged.Add("songs", "Waterloo")
+	events, cancel := broker.Events().Subscribe(0)
+	defer cancel()
+
+	if err := broker.Ping(context.Background()); err != nil {
+		t.Fatalf("got ping error %v before closing", err)
+	}
+	if err := broker.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	calls := []struct {
+		name string
+		call func() error
+	}{
+		{"ping", func() error { return broker.Ping(ctx) }},
+		{"remove", func() error { return broker.Cache().Remove(ctx, "songs") }},
+		{"purge", func() error { return broker.Cache().Purge(ctx) }},
+		{"invalidate tags", func() error { return broker.Cache().InvalidateTags(ctx, "song:1") }},
+		{"publish", func() error {
+			return broker.Events().Publish(ctx, &models.Event{Type: models.EventSongCreated, Song: &models.Song{ID: 1}})
+		}},
+	}
+	for _, c := range calls {
+		if err := c.call(); !errors.Is(err, ErrClosed) {
+			t.Errorf("%v: got error %v, want %v", c.name, err, ErrClosed)
+		}
+	}
+
+	// the refused messages aren't applied
+	if !tagged.Contains("songs") {
+		t.Error("got the cache changed by the closed broker")
+	}
+	select {
+	case event := <-events:
+		t.Errorf("got event %+v delivered by the closed broker", event)
+	default:
+	}
+}
+
+func TestBrokerCountsMessages(t *testing.T) {
+	m := metrics.New()
+	broker, _ := newTestBroker(t, m)
+
+	if err := broker.Cache().Purge(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := broker.Events().Publish(context.Background(), &models.Event{Type: models.EventSongCreated, Song: &models.Song{ID: 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+
+	// the same as with Kafka, every message is produced and then consumed by this peer
+	for _, want := range []string{
+		`lostify_broker_messages_total{command="PURGE",direction="produced",topic="cache"} 1`,
+		`lostify_broker_messages_total{command="PURGE",direction="consumed",topic="cache"} 1`,
+		`lostify_broker_messages_total{command="song.created",direction="produced",topic="events"} 1`,
+		`lostify_broker_messages_total{command="song.created",direction="consumed",topic="events"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("got no %v", want)
+		}
+	}
+}
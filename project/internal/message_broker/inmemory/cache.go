@@ -0,0 +1,77 @@
+package inmemory
+
+import (
+	"context"
+	"example/hello/project/internal/cache"
+	"example/hello/project/internal/message_broker"
+	"example/hello/project/internal/metrics"
+	"example/hello/project/internal/models"
+)
+
+// CacheBroker applies the commands to the cache of the peer right away, there are no other peers to tell
+type CacheBroker struct {
+	connection
+
+	cache   cache.TaggedCache
+	metrics *metrics.Metrics
+	origin  string
+}
+
+func NewCacheBroker(cache cache.TaggedCache, clientID string, metrics *metrics.Metrics) message_broker.CacheBroker {
+	return &CacheBroker{
+		cache:   cache,
+		metrics: metrics,
+		origin:  clientID,
+	}
+}
+
+func (c *CacheBroker) Connect(ctx context.Context, brokers []string) error {
+	return nil
+}
+
+func (c *CacheBroker) Close() error {
+	c.close()
+	return nil
+}
+
+func (c *CacheBroker) Ping(ctx context.Context) error {
+	return c.ping()
+}
+
+func (c *CacheBroker) Remove(ctx context.Context, key interface{}) error {
+	if err := c.deliver(models.CacheCommandRemove); err != nil {
+		return err
+	}
+
+	c.cache.Remove(key)
+	return nil
+}
+
+func (c *CacheBroker) Purge(ctx context.Context) error {
+	if err := c.deliver(models.CacheCommandPurge); err != nil {
+		return err
+	}
+
+	c.cache.PurgeFrom(c.origin)
+	return nil
+}
+
+func (c *CacheBroker) InvalidateTags(ctx context.Context, tags ...string) error {
+	if err := c.deliver(models.CacheCommandInvalidateTags); err != nil {
+		return err
+	}
+
+	c.cache.InvalidateTags(tags...)
+	return nil
+}
+
+// deliver counts the message as both produced and consumed, the same as it's done with Kafka
+func (c *CacheBroker) deliver(command models.CacheCommand) error {
+	if err := c.ping(); err != nil {
+		return err
+	}
+
+	c.metrics.MessageProduced(cacheTopic, string(command))
+	c.metrics.MessageConsumed(cacheTopic, string(command))
+	return nil
+}
@@ -0,0 +1,67 @@
+package inmemory
+
+import (
+	"context"
+	"example/hello/project/internal/message_broker"
+	"example/hello/project/internal/metrics"
+	"example/hello/project/internal/models"
+	"time"
+)
+
+// EventsBroker hands the events over to the subscribers of the peer
+type EventsBroker struct {
+	connection
+
+	clientID string
+	hub      *message_broker.EventHub
+	metrics  *metrics.Metrics
+}
+
+func NewEventsBroker(clientID string, metrics *metrics.Metrics) message_broker.EventsBroker {
+	return &EventsBroker{
+		clientID: clientID,
+		hub:      message_broker.NewEventHub(),
+		metrics:  metrics,
+	}
+}
+
+func (e *EventsBroker) Connect(ctx context.Context, brokers []string) error {
+	return nil
+}
+
+func (e *EventsBroker) Close() error {
+	e.close()
+	return nil
+}
+
+func (e *EventsBroker) Ping(ctx context.Context) error {
+	return e.ping()
+}
+
+func (e *EventsBroker) Publish(ctx context.Context, event *models.Event) error {
+	if err := e.ping(); err != nil {
+		return err
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	event.Origin = e.clientID
+
+	// the subscribers get their own copy, as they would get it decoded from Kafka
+	delivered := *event
+	e.metrics.MessageProduced(eventsTopic, string(event.Type))
+	e.metrics.MessageConsumed(eventsTopic, string(event.Type))
+	e.hub.Broadcast(&delivered)
+
+	return nil
+}
+
+func (e *EventsBroker) Subscribe(afterSeq uint64) (<-chan *models.Event, func()) {
+	return e.hub.Subscribe(afterSeq)
+}
+
+// Origin is the client ID, the events never reach another peer anyway
+func (e *EventsBroker) Origin() string {
+	return e.clientID
+}
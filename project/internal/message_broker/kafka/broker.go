@@ -2,38 +2,46 @@ package kafka
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"example/hello/project/internal/cache"
 	"example/hello/project/internal/message_broker"
-	lru "github.com/hashicorp/golang-lru"
-	"log"
+	"example/hello/project/internal/metrics"
+	"fmt"
+	"go.uber.org/zap"
+	"time"
 )
 
 type Broker struct {
 	brokers  []string
 	clientID string
 
-	cacheBroker message_broker.CacheBroker
-	cache       *lru.TwoQueueCache
+	cacheBroker  message_broker.CacheBroker
+	eventsBroker message_broker.EventsBroker
+	cache        cache.TaggedCache
+	metrics      *metrics.Metrics
+	logger       *zap.Logger
 }
 
-func NewBroker(brokers []string, cache *lru.TwoQueueCache, clientID string) message_broker.MessageBroker {
-	return &Broker{brokers: brokers, cache: cache, clientID: clientID}
+func NewBroker(brokers []string, cache cache.TaggedCache, clientID string, metrics *metrics.Metrics, logger *zap.Logger) message_broker.MessageBroker {
+	return &Broker{brokers: brokers, cache: cache, clientID: clientID, metrics: metrics, logger: logger}
 }
 
 func (b *Broker) Connect(ctx context.Context) error {
-	brokers := []message_broker.BrokerWithClient{b.Cache()}
+	brokers := []message_broker.BrokerWithClient{b.Cache(), b.Events()}
 
 	for _, broker := range brokers {
 		if err := broker.Connect(ctx, b.brokers); err != nil {
 			return err
 		}
 	}
-	log.Println("[MessageBroker] connected to all available brokers")
+	b.logger.Info("[MessageBroker] connected to all available brokers")
 
 	return nil
 }
 
 func (b *Broker) Close() error {
-	brokers := []message_broker.BrokerWithClient{b.Cache()}
+	brokers := []message_broker.BrokerWithClient{b.Cache(), b.Events()}
 
 	for _, broker := range brokers {
 		if err := broker.Close(); err != nil {
@@ -44,10 +52,41 @@ func (b *Broker) Close() error {
 	return nil
 }
 
+func (b *Broker) Ping(ctx context.Context) error {
+	brokers := []message_broker.BrokerWithClient{b.Cache(), b.Events()}
+
+	for _, broker := range brokers {
+		if err := broker.Ping(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (b *Broker) Cache() message_broker.CacheBroker {
 	if b.cacheBroker == nil {
-		b.cacheBroker = NewCacheBroker(b.cache, b.clientID)
+		b.cacheBroker = NewCacheBroker(b.cache, b.clientID, b.metrics, b.logger)
 	}
 
 	return b.cacheBroker
 }
+
+func (b *Broker) Events() message_broker.EventsBroker {
+	if b.eventsBroker == nil {
+		b.eventsBroker = NewEventsBroker(b.clientID, b.metrics, b.logger)
+	}
+
+	return b.eventsBroker
+}
+
+// newPeerID tells apart the runs of the peer, as well as the peers started with the same client ID
+func newPeerID(clientID string) string {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		// the time is unique enough as long as the peers aren't started at the same nanosecond
+		return fmt.Sprintf("%v-%x", clientID, time.Now().UnixNano())
+	}
+
+	return clientID + "-" + hex.EncodeToString(suffix)
+}
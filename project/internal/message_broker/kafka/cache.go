@@ -3,159 +3,230 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"example/hello/project/internal/cache"
 	"example/hello/project/internal/message_broker"
+	"example/hello/project/internal/metrics"
 	"example/hello/project/internal/models"
 	"github.com/Shopify/sarama"
-	lru "github.com/hashicorp/golang-lru"
-	"log"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"sync"
+	"time"
 )
 
-const cacheTopic = "cache"
+const (
+	cacheTopic = "cache"
+	// every run of every peer consumes the topic in its own group, so that all of them get every message
+	cacheGroupPrefix = "lostify-cache-"
+	// sequence numbers of the peers silent for this long are forgotten, they've been most likely stopped
+	originMemory = 24 * time.Hour
+)
 
-type (
-	CacheBroker struct {
-		syncProducer  sarama.SyncProducer
-		consumerGroup sarama.ConsumerGroup
+// appliedSeq is the last message of the origin applied to the cache
+type appliedSeq struct {
+	seq uint64
+	at  time.Time
+}
 
-		consumeHandler *cacheConsumeHandler
-		clientID       string
-	}
+type CacheBroker struct {
+	producer *producer
+	consumer *consumer
+
+	cache   cache.TaggedCache
+	metrics *metrics.Metrics
+	logger  *zap.Logger
+	// ID of this run of the peer, sent along with the messages
+	origin  string
+	brokers []string
+
+	// the messages are numbered & sent one at a time, so that they get to the partition in the order of their numbers;
+	// otherwise the other peers would take the message overtaken by the next one for a redelivery and drop it
+	sendMu sync.Mutex
+	// sequence number of the last message sent
+	seq uint64
+
+	mu      sync.Mutex
+	applied map[string]appliedSeq
+}
 
-	cacheConsumeHandler struct {
-		cache *lru.TwoQueueCache
-		ready chan bool
+func NewCacheBroker(cache cache.TaggedCache, clientID string, metrics *metrics.Metrics, logger *zap.Logger) message_broker.CacheBroker {
+	c := &CacheBroker{
+		cache:   cache,
+		metrics: metrics,
+		logger:  logger,
+		origin:  newPeerID(clientID),
+		applied: make(map[string]appliedSeq),
 	}
-)
-
-func NewCacheBroker(cache *lru.TwoQueueCache, clientID string) message_broker.CacheBroker {
-	return &CacheBroker{
-		clientID: clientID,
-		consumeHandler: &cacheConsumeHandler{
-			cache: cache,
-			ready: make(chan bool),
-		},
+	c.producer = &producer{
+		topic:   cacheTopic,
+		metrics: metrics,
+	}
+	c.consumer = &consumer{
+		topic:   cacheTopic,
+		groupID: cacheGroupPrefix + c.origin,
+		handle:  c.handle,
+		logger:  logger,
 	}
+
+	return c
 }
 
 func (c *CacheBroker) Connect(ctx context.Context, brokers []string) error {
-	producerConfig := sarama.NewConfig()
-	producerConfig.Producer.RequiredAcks = sarama.WaitForAll
-	producerConfig.Producer.Retry.Max = 10
-	producerConfig.Producer.Return.Successes = true
-
-	syncProducer, err := sarama.NewSyncProducer(brokers, producerConfig)
-	if err != nil {
+	c.brokers = brokers
+	if err := c.producer.connect(brokers); err != nil {
 		return err
 	}
-	c.syncProducer = syncProducer
 
-	consumerConfig := sarama.NewConfig()
-	consumerConfig.Consumer.Return.Errors = true
-	consumerGroup, err := sarama.NewConsumerGroup(brokers, c.clientID, consumerConfig)
-	if err != nil {
+	if err := c.consumer.connect(ctx, brokers); err != nil {
 		return err
 	}
-	c.consumerGroup = consumerGroup
 
-	go func() {
-		for {
-			if err := c.consumerGroup.Consume(ctx, []string{cacheTopic}, c.consumeHandler); err != nil {
-				log.Panicf("Error from consumer: %v", err)
-			}
-			if ctx.Err() != nil {
-				return
-			}
-			c.consumeHandler.ready = make(chan bool)
-		}
-	}()
-
-	<-c.consumeHandler.ready
+	// the groups of the peers which haven't been stopped gracefully would be kept by Kafka for days
+	go newStaleGroups(cacheGroupPrefix, c.consumer.groupID, c.logger).run(ctx, brokers)
 
 	return nil
 }
 
 func (c *CacheBroker) Close() error {
-	if err := c.syncProducer.Close(); err != nil {
+	if err := c.producer.close(); err != nil {
 		return err
 	}
 
-	if err := c.consumerGroup.Close(); err != nil {
+	if err := c.consumer.close(); err != nil {
 		return err
 	}
 
+	// nobody is going to consume in the group of this run again
+	if err := deleteGroup(c.brokers, c.consumer.groupID); err != nil {
+		c.logger.Warn("[Kafka] failed to delete consumer group", zap.String("group", c.consumer.groupID), zap.Error(err))
+	}
+
 	return nil
 }
 
-func (c *CacheBroker) Remove(key interface{}) error {
+// Ping reports whether both the producer and the consumer group are still usable
+func (c *CacheBroker) Ping(ctx context.Context) error {
+	if err := c.producer.ping(ctx); err != nil {
+		return err
+	}
+
+	return c.consumer.ping()
+}
+
+func (c *CacheBroker) Remove(ctx context.Context, key interface{}) error {
 	msg := &models.CacheMsg{
 		Command: models.CacheCommandRemove,
 		Key:     key,
 	}
 
-	msgRaw, err := json.Marshal(msg)
-	if err != nil {
-		return err
-	}
+	return c.send(ctx, msg)
+}
 
-	_, _, err = c.syncProducer.SendMessage(&sarama.ProducerMessage{
-		Topic: cacheTopic,
-		Value: sarama.StringEncoder(msgRaw),
-	})
-	if err != nil {
-		return err
+func (c *CacheBroker) Purge(ctx context.Context) error {
+	msg := &models.CacheMsg{
+		Command: models.CacheCommandPurge,
 	}
 
-	return nil
+	return c.send(ctx, msg)
 }
 
-func (c *CacheBroker) Purge() error {
+func (c *CacheBroker) InvalidateTags(ctx context.Context, tags ...string) error {
 	msg := &models.CacheMsg{
-		Command: models.CacheCommandPurge,
+		Command: models.CacheCommandInvalidateTags,
+		Tags:    tags,
 	}
 
+	return c.send(ctx, msg)
+}
+
+// send applies the message to the cache of this peer right away, so that the peer reads its own writes
+// without waiting for the round-trip to Kafka, and then to the caches of the other peers
+func (c *CacheBroker) send(ctx context.Context, msg *models.CacheMsg) error {
+	msg.Version = models.CacheMsgVersion
+	msg.Origin = c.origin
+	msg.Time = time.Now().UTC()
+
+	c.apply(msg)
+
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	c.seq++
+	msg.Seq = c.seq
 	msgRaw, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
 
-	_, _, err = c.syncProducer.SendMessage(&sarama.ProducerMessage{
-		Topic: cacheTopic,
-		Value: sarama.StringEncoder(msgRaw),
-	})
-	if err != nil {
+	// keyed by the origin, so that the messages of the peer are consumed in the order they were sent
+	return c.producer.send(ctx, c.origin, string(msg.Command), msgRaw)
+}
+
+// handle applies the command to the local cache; the eviction is traced as a part of the write that caused it
+func (c *CacheBroker) handle(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	cacheMsg := new(models.CacheMsg)
+	if err := json.Unmarshal(msg.Value, cacheMsg); err != nil {
 		return err
 	}
 
-	return nil
-}
+	c.metrics.MessageConsumed(msg.Topic, string(cacheMsg.Command))
+	trace.SpanFromContext(ctx).SetAttributes(messageKindKey.String(string(cacheMsg.Command)))
+
+	switch {
+	case cacheMsg.Origin == c.origin:
+		// the echo of the message applied when it was sent
+		return nil
+	case cacheMsg.Version > models.CacheMsgVersion:
+		// sent by a newer peer, since it can't be understood nothing cached can be trusted anymore
+		c.logger.Warn("[MessageBroker] purging cache on message of unknown version",
+			zap.Int("version", cacheMsg.Version),
+			zap.String("origin", cacheMsg.Origin),
+		)
+		c.cache.PurgeFrom(cacheMsg.Origin)
+		return nil
+	case c.redelivered(cacheMsg):
+		return nil
+	}
 
-func (c *cacheConsumeHandler) Setup(session sarama.ConsumerGroupSession) error {
-	close(c.ready)
+	c.apply(cacheMsg)
 	return nil
 }
 
-func (c *cacheConsumeHandler) Cleanup(session sarama.ConsumerGroupSession) error {
-	return nil
-}
+// redelivered tells whether the message has been applied already, e.g. it's consumed again after the rebalance
+// the messages of the origin are sent in the order of their numbers, so any number up to the last applied one has been seen
+func (c *CacheBroker) redelivered(msg *models.CacheMsg) bool {
+	if msg.Version == 0 {
+		// there's no sequence to tell
+		return false
+	}
 
-func (c *cacheConsumeHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
-	for msg := range claim.Messages() {
-		log.Printf("Message claimed: value = %s, timestamp = %v, topic = %s", string(msg.Value), msg.Timestamp, msg.Topic)
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-		cacheMsg := new(models.CacheMsg)
-		if err := json.Unmarshal(msg.Value, cacheMsg); err != nil {
-			return err
+	now := time.Now()
+	last, ok := c.applied[msg.Origin]
+	if ok && msg.Seq <= last.seq {
+		return true
+	}
+	if !ok {
+		for origin, applied := range c.applied {
+			if now.Sub(applied.at) > originMemory {
+				delete(c.applied, origin)
+			}
 		}
+	}
+	c.applied[msg.Origin] = appliedSeq{seq: msg.Seq, at: now}
 
-		switch cacheMsg.Command {
-		case models.CacheCommandRemove:
-			c.cache.Remove(cacheMsg.Key)
-		case models.CacheCommandPurge:
-			c.cache.Purge()
-		}
+	return false
+}
 
-		session.MarkMessage(msg, "")
+func (c *CacheBroker) apply(msg *models.CacheMsg) {
+	switch msg.Command {
+	case models.CacheCommandRemove:
+		c.cache.Remove(msg.Key)
+	case models.CacheCommandPurge:
+		c.cache.PurgeFrom(msg.Origin)
+	case models.CacheCommandInvalidateTags:
+		c.cache.InvalidateTags(msg.Tags...)
 	}
-
-	return nil
 }
@@ -0,0 +1,166 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"example/hello/project/internal/cache"
+	"example/hello/project/internal/models"
+	"fmt"
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"go.uber.org/zap"
+	"sync"
+	"testing"
+)
+
+func newTestCacheBroker(t *testing.T) (*CacheBroker, *cache.Tagged) {
+	t.Helper()
+
+	lru, err := cache.New(cache.Config{Strategy: cache.StrategyLRU, Size: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagged := cache.NewTagged(lru)
+
+	return NewCacheBroker(tagged, "peer0", nil, zap.NewNop()).(*CacheBroker), tagged
+}
+
+func cacheMessage(t *testing.T, msg *models.CacheMsg) *sarama.ConsumerMessage {
+	t.Helper()
+
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &sarama.ConsumerMessage{Topic: cacheTopic, Key: []byte(msg.Origin), Value: raw}
+}
+
+func TestCacheHandle(t *testing.T) {
+	remove := func(origin string, seq uint64, key string) *models.CacheMsg {
+		return &models.CacheMsg{Version: models.CacheMsgVersion, Origin: origin, Seq: seq, Command: models.CacheCommandRemove, Key: key}
+	}
+
+	tests := []struct {
+		name string
+		// the messages handled one by one, the entries 1-4 are cached before
+		messages []*models.CacheMsg
+		// the entries left in the cache
+		wantKept []string
+	}{
+		{
+			name:     "in order",
+			messages: []*models.CacheMsg{remove("peer1-a", 1, "1"), remove("peer1-a", 2, "2")},
+			wantKept: []string{"3", "4"},
+		},
+		{
+			name:     "duplicate",
+			messages: []*models.CacheMsg{remove("peer1-a", 1, "1"), remove("peer1-a", 2, "2"), remove("peer1-a", 2, "3")},
+			wantKept: []string{"3", "4"},
+		},
+		{
+			name:     "redelivered after the later ones",
+			messages: []*models.CacheMsg{remove("peer1-a", 1, "1"), remove("peer1-a", 2, "2"), remove("peer1-a", 1, "3")},
+			wantKept: []string{"3", "4"},
+		},
+		{
+			name:     "new origin",
+			messages: []*models.CacheMsg{remove("peer1-a", 5, "1"), remove("peer1-b", 1, "2"), remove("peer2-a", 1, "3")},
+			wantKept: []string{"4"},
+		},
+		{
+			name:     "echo of own message",
+			messages: []*models.CacheMsg{remove("", 1, "1")},
+			wantKept: []string{"1", "2", "3", "4"},
+		},
+		{
+			name: "predating the envelope",
+			messages: []*models.CacheMsg{
+				{Command: models.CacheCommandRemove, Key: "1"},
+				{Command: models.CacheCommandRemove, Key: "2"},
+			},
+			wantKept: []string{"3", "4"},
+		},
+		{
+			name:     "unknown version",
+			messages: []*models.CacheMsg{{Version: models.CacheMsgVersion + 1, Origin: "peer1-a", Seq: 1, Command: "EVICT"}},
+			wantKept: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, tagged := newTestCacheBroker(t)
+			for _, key := range []string{"1", "2", "3", "4"} {
+				tagged.Add(key, key)
+			}
+
+			for _, msg := range tt.messages {
+				if msg.Origin == "" && msg.Version > 0 {
+					msg.Origin = c.origin
+				}
+				if err := c.handle(context.Background(), cacheMessage(t, msg)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, key := range []string{"1", "2", "3", "4"} {
+				want := false
+				for _, kept := range tt.wantKept {
+					want = want || kept == key
+				}
+				if got := tagged.Contains(key); got != want {
+					t.Errorf("%v: got cached %v, want %v", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+// the other peers drop the messages numbered below the last one they've applied, so the numbers mustn't overtake each other
+func TestCacheSendInOrder(t *testing.T) {
+	const sends = 50
+
+	c, tagged := newTestCacheBroker(t)
+	syncProducer := mocks.NewSyncProducer(t, nil)
+	c.producer.syncProducer = syncProducer
+
+	var mu sync.Mutex
+	var last uint64
+	for i := 0; i < sends; i++ {
+		syncProducer.ExpectSendMessageWithCheckerFunctionAndSucceed(func(value []byte) error {
+			msg := new(models.CacheMsg)
+			if err := json.Unmarshal(value, msg); err != nil {
+				return err
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if msg.Seq != last+1 || msg.Origin != c.origin {
+				return fmt.Errorf("got message #%v of %v after #%v", msg.Seq, msg.Origin, last)
+			}
+			last = msg.Seq
+			return nil
+		})
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < sends; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tagged.Add(i, i)
+			if err := c.Remove(context.Background(), i); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// the peer doesn't wait for Kafka to apply its own messages
+	if tagged.Len() != 0 {
+		t.Errorf("got %v entries cached, want all of them removed", tagged.Len())
+	}
+	if err := syncProducer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,281 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"example/hello/project/internal/metrics"
+	"example/hello/project/internal/tracing"
+	"fmt"
+	"github.com/Shopify/sarama"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+var tracer = tracing.Tracer("message_broker/kafka")
+
+const (
+	consumeRetryBackoff = time.Second
+	// how long the peer waits for joining the consumer group on start, e.g. when Kafka is unreachable
+	consumerConnectTimeout = 30 * time.Second
+)
+
+// producer sends messages to a single topic
+type producer struct {
+	topic   string
+	metrics *metrics.Metrics
+
+	client       sarama.Client
+	syncProducer sarama.SyncProducer
+}
+
+func (p *producer) connect(brokers []string) error {
+	config := sarama.NewConfig()
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Retry.Max = 10
+	config.Producer.Return.Successes = true
+	// record headers (used for trace context propagation) are supported since Kafka 0.11
+	config.Version = sarama.V0_11_0_0
+
+	// keeping the client around so that we're able to check its state later on
+	client, err := sarama.NewClient(brokers, config)
+	if err != nil {
+		return err
+	}
+	p.client = client
+
+	syncProducer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		return err
+	}
+	p.syncProducer = syncProducer
+
+	return nil
+}
+
+func (p *producer) close() error {
+	if err := p.syncProducer.Close(); err != nil {
+		return err
+	}
+
+	// producer created from a client doesn't close it on its own
+	return p.client.Close()
+}
+
+// ping gives up once the context is done, even though the refresh of the metadata goes on in the background
+func (p *producer) ping(ctx context.Context) error {
+	if p.client == nil {
+		return errors.New("producer is not connected")
+	}
+
+	if p.client.Closed() {
+		return errors.New("producer client is closed")
+	}
+	// refreshing the metadata makes a round-trip to the Kafka brokers
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- p.client.RefreshMetadata(p.topic)
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("producer can't reach brokers: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("producer can't reach brokers: %w", ctx.Err())
+	}
+}
+
+// send publishes value with the trace context of ctx in the headers,
+// so that the processing on every consumer is linked to the operation that caused it.
+// The messages with the same non-empty key go to the same partition, so they're consumed in order
+func (p *producer) send(ctx context.Context, key, kind string, value []byte) (err error) {
+	ctx, span := tracer.Start(ctx, p.topic+" send",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("kafka"),
+			semconv.MessagingDestinationKey.String(p.topic),
+			semconv.MessagingDestinationKindTopic,
+			messageKindKey.String(kind),
+		),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	msg := &sarama.ProducerMessage{
+		Topic: p.topic,
+		Value: sarama.ByteEncoder(value),
+	}
+	if key != "" {
+		msg.Key = sarama.StringEncoder(key)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, producerHeadersCarrier{msg: msg})
+
+	if _, _, err := p.syncProducer.SendMessage(msg); err != nil {
+		return err
+	}
+	p.metrics.MessageProduced(p.topic, kind)
+
+	return nil
+}
+
+// messageHandler processes a single message; ctx carries the span continuing the trace of the producer
+type messageHandler func(ctx context.Context, msg *sarama.ConsumerMessage) error
+
+// consumer runs a consumer group on a single topic, rejoining it on errors
+type consumer struct {
+	topic   string
+	groupID string
+	handle  messageHandler
+	logger  *zap.Logger
+
+	group sarama.ConsumerGroup
+	ready chan bool
+
+	// last error reported by the consumer group; nil while it's healthy
+	errMu sync.RWMutex
+	err   error
+}
+
+// connect joins the group and blocks until the first session is set up, the context is done
+// or consumerConnectTimeout is exceeded, in which case the group is closed
+func (c *consumer) connect(ctx context.Context, brokers []string) error {
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+	// the new groups start with the messages sent from now on, the older ones are of no use to the peer
+	config.Consumer.Offsets.Initial = sarama.OffsetNewest
+	config.Version = sarama.V0_11_0_0
+	group, err := sarama.NewConsumerGroup(brokers, c.groupID, config)
+	if err != nil {
+		return err
+	}
+	c.group = group
+
+	c.ready = make(chan bool)
+	ready := c.ready
+
+	go func() {
+		// these are per-partition errors that sarama recovers from on its own
+		for err := range c.group.Errors() {
+			c.logger.Warn("[Kafka] error from consumer group", zap.String("topic", c.topic), zap.Error(err))
+		}
+	}()
+
+	go func() {
+		for {
+			if err := c.group.Consume(ctx, []string{c.topic}, c); err != nil {
+				// instead of crashing the whole peer, the error is recorded (so that readiness fails)
+				// and we try to rejoin the group a bit later
+				c.logger.Error("[Kafka] error from consumer", zap.String("topic", c.topic), zap.Error(err))
+				c.setErr(err)
+				if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+					return
+				}
+				select {
+				case <-ctx.Done():
+				case <-time.After(consumeRetryBackoff):
+				}
+			}
+			if ctx.Err() != nil {
+				c.setErr(ctx.Err())
+				return
+			}
+			// the session could have failed before Setup, in which case `ready` is still open
+			select {
+			case <-c.ready:
+				c.ready = make(chan bool)
+			default:
+			}
+		}
+	}()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		_ = c.group.Close()
+		return ctx.Err()
+	case <-time.After(consumerConnectTimeout):
+		err := fmt.Errorf("consumer of %v topic hasn't joined the group in %v, last error: %v", c.topic, consumerConnectTimeout, c.lastErr())
+		_ = c.group.Close()
+		return err
+	}
+}
+
+func (c *consumer) close() error {
+	return c.group.Close()
+}
+
+func (c *consumer) ping() error {
+	if c.group == nil {
+		return errors.New("consumer is not connected")
+	}
+
+	c.errMu.RLock()
+	defer c.errMu.RUnlock()
+	if c.err != nil {
+		return fmt.Errorf("consumer group is unhealthy: %w", c.err)
+	}
+
+	return nil
+}
+
+func (c *consumer) lastErr() error {
+	c.errMu.RLock()
+	defer c.errMu.RUnlock()
+
+	return c.err
+}
+
+func (c *consumer) setErr(err error) {
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+	c.err = err
+}
+
+func (c *consumer) Setup(session sarama.ConsumerGroupSession) error {
+	// a successful rebalance means that the consumer group is alive again
+	c.setErr(nil)
+	close(c.ready)
+	return nil
+}
+
+func (c *consumer) Cleanup(session sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (c *consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		c.logger.Debug("message claimed",
+			zap.ByteString("value", msg.Value),
+			zap.Time("timestamp", msg.Timestamp),
+			zap.String("topic", msg.Topic),
+		)
+
+		// continuing the trace of the producer
+		producerCtx := otel.GetTextMapPropagator().Extract(session.Context(), consumerHeadersCarrier{msg: msg})
+		ctx, span := tracer.Start(producerCtx, msg.Topic+" process",
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(
+				semconv.MessagingSystemKey.String("kafka"),
+				semconv.MessagingDestinationKey.String(msg.Topic),
+				semconv.MessagingDestinationKindTopic,
+				semconv.MessagingOperationProcess,
+			),
+		)
+		err := c.handle(ctx, msg)
+		tracing.End(span, err)
+		if err != nil {
+			// skipping the malformed message, otherwise we'd get stuck on it forever
+			c.logger.Error("[Kafka] failed to handle message", zap.String("topic", msg.Topic), zap.Error(err))
+		}
+
+		session.MarkMessage(msg, "")
+	}
+
+	return nil
+}
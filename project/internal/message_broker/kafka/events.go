@@ -0,0 +1,131 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"example/hello/project/internal/message_broker"
+	"example/hello/project/internal/metrics"
+	"example/hello/project/internal/models"
+	"github.com/Shopify/sarama"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	eventsTopic = "events"
+	// every run of every peer consumes the topic in its own group, so that all of them get every event
+	eventsGroupPrefix = "lostify-events-"
+)
+
+// EventsBroker publishes catalog changes to every peer. Each run of the peer consumes the topic
+// in its own consumer group from the newest offset, so that all of them receive every event
+// published meanwhile. The events carry the ID of the run of the publisher, which is the only one
+// delivering them to the webhooks, even if the other peers are started with the same client ID.
+type EventsBroker struct {
+	producer *producer
+	consumer *consumer
+
+	// ID of this run of the peer, the events are stamped with it
+	origin  string
+	hub     *message_broker.EventHub
+	metrics *metrics.Metrics
+	logger  *zap.Logger
+	brokers []string
+}
+
+func NewEventsBroker(clientID string, metrics *metrics.Metrics, logger *zap.Logger) message_broker.EventsBroker {
+	e := &EventsBroker{
+		origin:  newPeerID(clientID),
+		hub:     message_broker.NewEventHub(),
+		metrics: metrics,
+		logger:  logger,
+	}
+	e.producer = &producer{
+		topic:   eventsTopic,
+		metrics: metrics,
+	}
+	e.consumer = &consumer{
+		topic:   eventsTopic,
+		groupID: eventsGroupPrefix + e.origin,
+		handle:  e.handle,
+		logger:  logger,
+	}
+
+	return e
+}
+
+func (e *EventsBroker) Connect(ctx context.Context, brokers []string) error {
+	e.brokers = brokers
+	if err := e.producer.connect(brokers); err != nil {
+		return err
+	}
+
+	if err := e.consumer.connect(ctx, brokers); err != nil {
+		return err
+	}
+
+	go newStaleGroups(eventsGroupPrefix, e.consumer.groupID, e.logger).run(ctx, brokers)
+
+	return nil
+}
+
+func (e *EventsBroker) Close() error {
+	if err := e.producer.close(); err != nil {
+		return err
+	}
+
+	if err := e.consumer.close(); err != nil {
+		return err
+	}
+
+	if err := deleteGroup(e.brokers, e.consumer.groupID); err != nil {
+		e.logger.Warn("[Kafka] failed to delete consumer group", zap.String("group", e.consumer.groupID), zap.Error(err))
+	}
+
+	return nil
+}
+
+func (e *EventsBroker) Ping(ctx context.Context) error {
+	if err := e.producer.ping(ctx); err != nil {
+		return err
+	}
+
+	return e.consumer.ping()
+}
+
+func (e *EventsBroker) Publish(ctx context.Context, event *models.Event) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	event.Origin = e.origin
+
+	eventRaw, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return e.producer.send(ctx, "", string(event.Type), eventRaw)
+}
+
+func (e *EventsBroker) Subscribe(afterSeq uint64) (<-chan *models.Event, func()) {
+	return e.hub.Subscribe(afterSeq)
+}
+
+func (e *EventsBroker) Origin() string {
+	return e.origin
+}
+
+func (e *EventsBroker) handle(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	event := new(models.Event)
+	if err := json.Unmarshal(msg.Value, event); err != nil {
+		return err
+	}
+
+	e.metrics.MessageConsumed(msg.Topic, string(event.Type))
+	trace.SpanFromContext(ctx).SetAttributes(messageKindKey.String(string(event.Type)))
+
+	e.hub.Broadcast(event)
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"example/hello/project/internal/models"
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"go.uber.org/zap"
+	"strings"
+	"testing"
+)
+
+// the runs of the peers started with the same client ID mustn't split the partitions of the topic
+func TestEventsGroupPerRun(t *testing.T) {
+	first := NewEventsBroker("peer0", nil, zap.NewNop()).(*EventsBroker)
+	second := NewEventsBroker("peer0", nil, zap.NewNop()).(*EventsBroker)
+
+	for _, e := range []*EventsBroker{first, second} {
+		if !strings.HasPrefix(e.consumer.groupID, eventsGroupPrefix+"peer0-") {
+			t.Errorf("got group %q, want it prefixed with %q", e.consumer.groupID, eventsGroupPrefix)
+		}
+	}
+	if first.consumer.groupID == second.consumer.groupID {
+		t.Errorf("both runs consume in group %q", first.consumer.groupID)
+	}
+}
+
+// the webhooks are delivered by the run which has published the event, whatever client ID the other runs have
+func TestEventsPublishStampsRun(t *testing.T) {
+	first := NewEventsBroker("peer0", nil, zap.NewNop()).(*EventsBroker)
+	second := NewEventsBroker("peer0", nil, zap.NewNop()).(*EventsBroker)
+	if first.Origin() == second.Origin() || !strings.HasPrefix(first.Origin(), "peer0-") {
+		t.Fatalf("got origins %q and %q, want them unique to the run", first.Origin(), second.Origin())
+	}
+
+	syncProducer := mocks.NewSyncProducer(t, nil)
+	first.producer.syncProducer = syncProducer
+	syncProducer.ExpectSendMessageWithCheckerFunctionAndSucceed(func(value []byte) error {
+		event := new(models.Event)
+		if err := json.Unmarshal(value, event); err != nil {
+			return err
+		}
+		if event.Origin != first.Origin() {
+			t.Errorf("got event sent from %q, want %q", event.Origin, first.Origin())
+		}
+		return nil
+	})
+
+	// whatever origin the event has had, it's the publisher's one
+	event := &models.Event{Type: models.EventSongCreated, Origin: "peer0", Song: &models.Song{ID: 1}}
+	if err := first.Publish(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncProducer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEventsHandleBroadcasts(t *testing.T) {
+	e := NewEventsBroker("peer0", nil, zap.NewNop()).(*EventsBroker)
+	events, cancel := e.Subscribe(0)
+	defer cancel()
+
+	raw, err := json.Marshal(&models.Event{Type: models.EventSongCreated, Origin: "peer1", Song: &models.Song{ID: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.handle(context.Background(), &sarama.ConsumerMessage{Topic: eventsTopic, Value: raw}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-events:
+		// the origin is kept, so that only the publishing peer delivers the webhooks
+		if event.Origin != "peer1" || event.Song == nil || event.Song.ID != 1 || event.Seq != 1 {
+			t.Errorf("got event %+v", event)
+		}
+	default:
+		t.Fatal("the event isn't broadcast")
+	}
+}
@@ -0,0 +1,147 @@
+package kafka
+
+import (
+	"context"
+	"github.com/Shopify/sarama"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+// states of the consumer groups without members, see DescribeGroups in Kafka protocol
+const (
+	groupStateEmpty = "Empty"
+	groupStateDead  = "Dead"
+)
+
+// how long the group has to stay without members to be taken for stale; the live peers rejoin their groups
+// after consumeRetryBackoff, so their groups are only empty for a moment
+const staleGroupTimeout = time.Minute
+
+// groupsAdmin is the part of sarama.ClusterAdmin managing the consumer groups
+type groupsAdmin interface {
+	ListConsumerGroups() (map[string]string, error)
+	DescribeConsumerGroups(groups []string) ([]*sarama.GroupDescription, error)
+	DeleteConsumerGroup(group string) error
+}
+
+// staleGroups deletes the consumer groups with the prefix which have had no members for staleGroupTimeout,
+// e.g. the groups of the peers which have crashed, Kafka would keep them for days otherwise.
+// Kafka doesn't tell since when the group is empty, so it's the peer which keeps track of that
+type staleGroups struct {
+	prefix string
+	// group of the peer itself, it's deleted on Close
+	own    string
+	logger *zap.Logger
+
+	emptySince map[string]time.Time
+}
+
+func newStaleGroups(prefix, own string, logger *zap.Logger) *staleGroups {
+	return &staleGroups{
+		prefix:     prefix,
+		own:        own,
+		logger:     logger,
+		emptySince: make(map[string]time.Time),
+	}
+}
+
+// run sweeps the groups every staleGroupTimeout until ctx is done
+func (sg *staleGroups) run(ctx context.Context, brokers []string) {
+	ticker := time.NewTicker(staleGroupTimeout)
+	defer ticker.Stop()
+
+	for {
+		if err := sg.sweepCluster(brokers); err != nil {
+			sg.logger.Warn("[Kafka] failed to clean up stale consumer groups", zap.String("prefix", sg.prefix), zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (sg *staleGroups) sweepCluster(brokers []string) error {
+	admin, err := newClusterAdmin(brokers)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = admin.Close() }()
+
+	return sg.sweep(admin, time.Now())
+}
+
+// sweep deletes the groups which have been empty since the previous sweeps for staleGroupTimeout at least,
+// the groups in use are never deleted, Kafka refuses to do that anyway
+func (sg *staleGroups) sweep(admin groupsAdmin, now time.Time) error {
+	groups, err := admin.ListConsumerGroups()
+	if err != nil {
+		return err
+	}
+	var candidates []string
+	for group := range groups {
+		if strings.HasPrefix(group, sg.prefix) && group != sg.own {
+			candidates = append(candidates, group)
+		}
+	}
+	// the groups deleted by the other peers are forgotten
+	for group := range sg.emptySince {
+		if _, ok := groups[group]; !ok {
+			delete(sg.emptySince, group)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	descriptions, err := admin.DescribeConsumerGroups(candidates)
+	if err != nil {
+		return err
+	}
+	for _, description := range descriptions {
+		group := description.GroupId
+		if description.State != groupStateEmpty && description.State != groupStateDead {
+			delete(sg.emptySince, group)
+			continue
+		}
+		since, ok := sg.emptySince[group]
+		if !ok {
+			sg.emptySince[group] = now
+			continue
+		}
+		if now.Sub(since) < staleGroupTimeout {
+			continue
+		}
+
+		if err := admin.DeleteConsumerGroup(group); err != nil {
+			sg.logger.Warn("[Kafka] failed to delete stale consumer group", zap.String("group", group), zap.Error(err))
+			continue
+		}
+		delete(sg.emptySince, group)
+		sg.logger.Info("[Kafka] deleted stale consumer group", zap.String("group", group), zap.Time("empty_since", since))
+	}
+
+	return nil
+}
+
+// deleteGroup deletes the group once its consumer has left it
+func deleteGroup(brokers []string, group string) error {
+	admin, err := newClusterAdmin(brokers)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = admin.Close() }()
+
+	return admin.DeleteConsumerGroup(group)
+}
+
+func newClusterAdmin(brokers []string) (sarama.ClusterAdmin, error) {
+	config := sarama.NewConfig()
+	// deleting the groups is supported since Kafka 1.1
+	config.Version = sarama.V1_1_0_0
+
+	return sarama.NewClusterAdmin(brokers, config)
+}
@@ -0,0 +1,123 @@
+package kafka
+
+import (
+	"errors"
+	"github.com/Shopify/sarama"
+	"go.uber.org/zap"
+	"sort"
+	"testing"
+	"time"
+)
+
+// fakeAdmin is the cluster with the consumer groups in the given states
+type fakeAdmin struct {
+	states    map[string]string
+	deleted   []string
+	deleteErr error
+}
+
+func (a *fakeAdmin) ListConsumerGroups() (map[string]string, error) {
+	groups := make(map[string]string, len(a.states))
+	for group := range a.states {
+		groups[group] = "consumer"
+	}
+	return groups, nil
+}
+
+func (a *fakeAdmin) DescribeConsumerGroups(groups []string) ([]*sarama.GroupDescription, error) {
+	var descriptions []*sarama.GroupDescription
+	for _, group := range groups {
+		descriptions = append(descriptions, &sarama.GroupDescription{GroupId: group, State: a.states[group]})
+	}
+	return descriptions, nil
+}
+
+func (a *fakeAdmin) DeleteConsumerGroup(group string) error {
+	if a.deleteErr != nil {
+		return a.deleteErr
+	}
+	delete(a.states, group)
+	a.deleted = append(a.deleted, group)
+	return nil
+}
+
+func TestStaleGroupsSweep(t *testing.T) {
+	const own = cacheGroupPrefix + "peer0-0a0b0c0d"
+
+	type sweep struct {
+		// since the first sweep
+		at time.Duration
+		// states of the groups which haven't been deleted yet
+		states      map[string]string
+		wantDeleted []string
+	}
+	tests := []struct {
+		name      string
+		deleteErr error
+		sweeps    []sweep
+	}{
+		{"empty for a while", nil, []sweep{
+			{0, map[string]string{"lostify-cache-peer1": "Empty", "lostify-cache-peer2": "Stable"}, nil},
+			{time.Minute, map[string]string{"lostify-cache-peer1": "Empty", "lostify-cache-peer2": "Stable"}, []string{"lostify-cache-peer1"}},
+		}},
+		{"dead", nil, []sweep{
+			{0, map[string]string{"lostify-cache-peer1": "Dead"}, nil},
+			{time.Minute, map[string]string{"lostify-cache-peer1": "Dead"}, []string{"lostify-cache-peer1"}},
+		}},
+		// the peer is waiting for consumeRetryBackoff to rejoin its group
+		{"empty for a moment", nil, []sweep{
+			{0, map[string]string{"lostify-cache-peer1": "Empty"}, nil},
+			{time.Second, map[string]string{"lostify-cache-peer1": "Empty"}, nil},
+		}},
+		{"rejoined in between", nil, []sweep{
+			{0, map[string]string{"lostify-cache-peer1": "Empty"}, nil},
+			{time.Minute, map[string]string{"lostify-cache-peer1": "PreparingRebalance"}, nil},
+			{2 * time.Minute, map[string]string{"lostify-cache-peer1": "Empty"}, nil},
+			{3 * time.Minute, map[string]string{"lostify-cache-peer1": "Empty"}, []string{"lostify-cache-peer1"}},
+		}},
+		{"own group", nil, []sweep{
+			{0, map[string]string{own: "Empty"}, nil},
+			{time.Hour, map[string]string{own: "Empty"}, nil},
+		}},
+		{"other prefix", nil, []sweep{
+			{0, map[string]string{"lostify-events-peer1": "Empty", "billing": "Empty"}, nil},
+			{time.Hour, map[string]string{"lostify-events-peer1": "Empty", "billing": "Empty"}, nil},
+		}},
+		{"deleted by another peer", nil, []sweep{
+			{0, map[string]string{"lostify-cache-peer1": "Empty"}, nil},
+			{time.Minute, map[string]string{}, nil},
+			// the group of the same name is a new one
+			{2 * time.Minute, map[string]string{"lostify-cache-peer1": "Empty"}, nil},
+		}},
+		{"failed to delete", errors.New("group is not empty"), []sweep{
+			{0, map[string]string{"lostify-cache-peer1": "Empty"}, nil},
+			{time.Minute, map[string]string{"lostify-cache-peer1": "Empty"}, nil},
+			{time.Minute + time.Second, map[string]string{"lostify-cache-peer1": "Empty"}, nil},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			admin := &fakeAdmin{deleteErr: tt.deleteErr}
+			sg := newStaleGroups(cacheGroupPrefix, own, zap.NewNop())
+			start := time.Now()
+
+			for i, sweep := range tt.sweeps {
+				admin.states, admin.deleted = sweep.states, nil
+				if err := sg.sweep(admin, start.Add(sweep.at)); err != nil {
+					t.Fatal(err)
+				}
+
+				sort.Strings(admin.deleted)
+				if len(admin.deleted) != len(sweep.wantDeleted) {
+					t.Fatalf("sweep %v: got deleted %v, want %v", i, admin.deleted, sweep.wantDeleted)
+				}
+				for j := range admin.deleted {
+					if admin.deleted[j] != sweep.wantDeleted[j] {
+						t.Fatalf("sweep %v: got deleted %v, want %v", i, admin.deleted, sweep.wantDeleted)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package kafka
+
+import (
+	"github.com/Shopify/sarama"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// producerHeadersCarrier lets OpenTelemetry propagator inject trace context into Kafka message headers
+type producerHeadersCarrier struct {
+	msg *sarama.ProducerMessage
+}
+
+func (c producerHeadersCarrier) Get(key string) string {
+	for _, header := range c.msg.Headers {
+		if string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func (c producerHeadersCarrier) Set(key, value string) {
+	for i, header := range c.msg.Headers {
+		if string(header.Key) == key {
+			c.msg.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	c.msg.Headers = append(c.msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+func (c producerHeadersCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.msg.Headers))
+	for _, header := range c.msg.Headers {
+		keys = append(keys, string(header.Key))
+	}
+	return keys
+}
+
+// consumerHeadersCarrier lets OpenTelemetry propagator extract trace context from consumed message headers
+type consumerHeadersCarrier struct {
+	msg *sarama.ConsumerMessage
+}
+
+func (c consumerHeadersCarrier) Get(key string) string {
+	for _, header := range c.msg.Headers {
+		if header != nil && string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+// Set is a no-op, since consumed messages are read-only
+func (c consumerHeadersCarrier) Set(key, value string) {}
+
+func (c consumerHeadersCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.msg.Headers))
+	for _, header := range c.msg.Headers {
+		if header != nil {
+			keys = append(keys, string(header.Key))
+		}
+	}
+	return keys
+}
+
+// messageKindKey is the span attribute with the cache command or event type of the message
+var messageKindKey = attribute.Key("message.kind")
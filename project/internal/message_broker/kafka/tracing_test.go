@@ -0,0 +1,58 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// the consumer continues the trace of the producer, as the trace context goes along in the headers
+func TestTraceContextInHeaders(t *testing.T) {
+	provider := sdktrace.NewTracerProvider()
+	defer func() { _ = provider.Shutdown(context.Background()) }()
+	propagator := propagation.TraceContext{}
+
+	ctx, span := provider.Tracer("test").Start(context.Background(), "cache send")
+	defer span.End()
+
+	produced := &sarama.ProducerMessage{
+		Topic:   cacheTopic,
+		Headers: []sarama.RecordHeader{{Key: []byte("other"), Value: []byte("header")}},
+	}
+	propagator.Inject(ctx, producerHeadersCarrier{msg: produced})
+
+	consumed := &sarama.ConsumerMessage{Topic: cacheTopic}
+	for i := range produced.Headers {
+		consumed.Headers = append(consumed.Headers, &produced.Headers[i])
+	}
+	// sarama leaves nil headers in the consumed messages at times
+	consumed.Headers = append(consumed.Headers, nil)
+
+	extracted := trace.SpanContextFromContext(propagator.Extract(context.Background(), consumerHeadersCarrier{msg: consumed}))
+	if !extracted.IsValid() || !extracted.IsRemote() {
+		t.Fatalf("no remote span context extracted from headers %v", consumerHeadersCarrier{msg: consumed}.Keys())
+	}
+	if extracted.TraceID() != span.SpanContext().TraceID() || extracted.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("got span %v of trace %v, want span %v of trace %v",
+			extracted.SpanID(), extracted.TraceID(), span.SpanContext().SpanID(), span.SpanContext().TraceID())
+	}
+}
+
+func TestProducerHeadersCarrierReplacesHeader(t *testing.T) {
+	msg := &sarama.ProducerMessage{}
+	carrier := producerHeadersCarrier{msg: msg}
+
+	carrier.Set("traceparent", "first")
+	carrier.Set("traceparent", "second")
+
+	if len(msg.Headers) != 1 {
+		t.Fatalf("got %v headers, want 1", len(msg.Headers))
+	}
+	if got := carrier.Get("traceparent"); got != "second" {
+		t.Errorf("got %q, want %q", got, "second")
+	}
+}
@@ -0,0 +1,148 @@
+// Prometheus client for Go: https://github.com/prometheus/client_golang
+
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "lostify"
+
+// Metrics holds a dedicated registry with all the collectors of the project.
+// All the methods are safe to call on a nil *Metrics, in which case nothing is recorded.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests   *prometheus.CounterVec
+	httpDuration   *prometheus.HistogramVec
+	cacheLookups   *prometheus.CounterVec
+	brokerMessages *prometheus.CounterVec
+	lyricsCalls    *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Number of HTTP requests by chi route pattern, method and status code.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of HTTP requests by chi route pattern and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      "lookups_total",
+			Help:      "Number of in-memory cache lookups by resource and result (hit, miss, stale or bypass).",
+		}, []string{"resource", "result"}),
+		brokerMessages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "broker",
+			Name:      "messages_total",
+			Help:      "Number of messages produced to and consumed from the message broker.",
+		}, []string{"topic", "direction", "command"}),
+		lyricsCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "lyrics",
+			Name:      "api_calls_total",
+			Help:      "Number of calls to Musixmatch API by method and outcome.",
+		}, []string{"method", "outcome"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.cacheLookups,
+		m.brokerMessages,
+		m.lyricsCalls,
+	)
+
+	return m
+}
+
+// Handler serves the registry in Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	if m == nil {
+		return http.NotFoundHandler()
+	}
+
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// RegisterCacheSize exposes the current number of entries in the cache, read at scrape time
+func (m *Metrics) RegisterCacheSize(size func() int) {
+	if m == nil {
+		return
+	}
+
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "size",
+		Help:      "Number of entries in the in-memory cache.",
+	}, func() float64 {
+		return float64(size())
+	}))
+}
+
+func (m *Metrics) ObserveHTTPRequest(route, method string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	m.httpDuration.WithLabelValues(route, method).Observe(duration.Seconds())
+}
+
+// ObserveCacheLookup counts the lookups by their result: hit, miss, stale or bypass (e.g. Cache-Control: no-store)
+func (m *Metrics) ObserveCacheLookup(resource, result string) {
+	if m == nil {
+		return
+	}
+
+	m.cacheLookups.WithLabelValues(resource, result).Inc()
+}
+
+func (m *Metrics) MessageProduced(topic, command string) {
+	if m == nil {
+		return
+	}
+
+	m.brokerMessages.WithLabelValues(topic, "produced", command).Inc()
+}
+
+func (m *Metrics) MessageConsumed(topic, command string) {
+	if m == nil {
+		return
+	}
+
+	m.brokerMessages.WithLabelValues(topic, "consumed", command).Inc()
+}
+
+func (m *Metrics) ObserveLyricsCall(method string, err error) {
+	if m == nil {
+		return
+	}
+
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	m.lyricsCalls.WithLabelValues(method, outcome).Inc()
+}
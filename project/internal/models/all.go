@@ -8,19 +8,33 @@
 package models
 
 type Artist struct {
-	ID       int    `json:"id"`
-	FullName string `json:"full_name"`
+	ID       int    `json:"id" yaml:"id"`
+	FullName string `json:"full_name" yaml:"full_name"`
 }
 
 type Song struct {
-	ID       int    `json:"id"`
-	Title    string `json:"title"`
-	ArtistID int    `json:"artist_id"`
+	ID       int    `json:"id" yaml:"id"`
+	Title    string `json:"title" yaml:"title"`
+	ArtistID int    `json:"artist_id" yaml:"artist_id"`
 	// the following fields are fetched from musixmatch API
-	Lyrics    string `json:"lyrics"`
-	AlbumName string `json:"album_name"`
+	Lyrics    string `json:"lyrics" yaml:"lyrics"`
+	AlbumName string `json:"album_name" yaml:"album_name"`
 }
 
+// statuses of the lyrics saga, stored in place of the lyrics until they're fetched
+const (
+	LyricsStatusFetching = "fetching lyrics..."
+	LyricsStatusFailed   = "failed"
+)
+
 type Filter struct {
-	Query *string `json:"query"`
+	Query    *string `json:"query"`
+	ArtistID *int    `json:"artist_id"`
+	Page
+}
+
+// Page is a window of the results ordered by ID
+type Page struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
 }
@@ -1,13 +1,29 @@
 package models
 
+import "time"
+
+// CacheMsgVersion is the version of the schema of CacheMsg, it's bumped on incompatible changes
+const CacheMsgVersion = 1
+
 type CacheCommand string
 
 const (
 	CacheCommandRemove CacheCommand = "REMOVE"
 	CacheCommandPurge  CacheCommand = "PURGE"
+	// removes the entries with any of the tags
+	CacheCommandInvalidateTags CacheCommand = "INVALIDATE_TAGS"
 )
 
 type CacheMsg struct {
+	// envelope, the messages of the peers predating it have zero version and none of the fields
+	Version int `json:"version,omitempty"`
+	// ID of the peer which has sent the message, unique for every run of the peer
+	Origin string `json:"origin,omitempty"`
+	// sequence number of the message among the ones sent by the origin, starting with 1
+	Seq  uint64    `json:"seq,omitempty"`
+	Time time.Time `json:"time"`
+
 	Command CacheCommand `json:"command"`
 	Key     interface{}  `json:"key"`
+	Tags    []string     `json:"tags,omitempty"`
 }
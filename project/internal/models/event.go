@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+type EventType string
+
+const (
+	EventSongCreated      EventType = "song.created"
+	EventSongUpdated      EventType = "song.updated"
+	EventSongDeleted      EventType = "song.deleted"
+	EventSongLyricsReady  EventType = "song.lyrics_ready"
+	EventSongLyricsFailed EventType = "song.lyrics_failed"
+	EventArtistCreated    EventType = "artist.created"
+	EventArtistUpdated    EventType = "artist.updated"
+	EventArtistDeleted    EventType = "artist.deleted"
+)
+
+// EventTypes lists every type of the events, e.g. to validate subscriptions
+var EventTypes = []EventType{
+	EventSongCreated,
+	EventSongUpdated,
+	EventSongDeleted,
+	EventSongLyricsReady,
+	EventSongLyricsFailed,
+	EventArtistCreated,
+	EventArtistUpdated,
+	EventArtistDeleted,
+}
+
+// Event describes a change in the catalog made on any of the peers.
+// For deletions, only the ID of the deleted song or artist is set.
+type Event struct {
+	Type   EventType `json:"type"`
+	Time   time.Time `json:"time"`
+	Origin string    `json:"origin"`
+	Song   *Song     `json:"song,omitempty"`
+	Artist *Artist   `json:"artist,omitempty"`
+
+	// sequence number assigned by the receiving peer, used as SSE event ID
+	Seq uint64 `json:"-"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// IdempotencyRecord is the first response to a request with the given Idempotency-Key,
+// it's replayed on duplicates until expired
+type IdempotencyRecord struct {
+	Key string
+	// hash of the method, path & body, the key can't be reused for a different request
+	RequestHash string
+	// false while the first request is still being processed
+	Completed bool
+	// the reservation of the request being processed lapses then, e.g. if the peer processing it has died,
+	// so that a retry could take the key over
+	LockedUntil time.Time
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
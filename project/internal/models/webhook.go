@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Webhook is a subscription of a partner service to the catalog events
+type Webhook struct {
+	ID  int    `json:"id"`
+	URL string `json:"url"`
+	// used to sign the payloads with HMAC-SHA256; never rendered back to clients
+	Secret     string      `json:"secret,omitempty"`
+	EventTypes []EventType `json:"event_types"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryStatusDelivered DeliveryStatus = "delivered"
+	DeliveryStatusFailed    DeliveryStatus = "failed"
+	// the delivery has failed on every attempt and won't be retried anymore
+	DeliveryStatusDead DeliveryStatus = "dead"
+)
+
+// WebhookDelivery is a single attempt to deliver an event to a webhook
+type WebhookDelivery struct {
+	ID             string         `json:"id"`
+	WebhookID      int            `json:"webhook_id"`
+	Event          *Event         `json:"event"`
+	Attempt        int            `json:"attempt"`
+	Status         DeliveryStatus `json:"status"`
+	ResponseStatus int            `json:"response_status,omitempty"`
+	Error          string         `json:"error,omitempty"`
+	Time           time.Time      `json:"time"`
+}
@@ -0,0 +1,149 @@
+// Package shutdown coordinates the termination of the peer: the stages are torn down one after another
+// within a single deadline, and the background jobs are drained instead of being dropped on the floor
+package shutdown
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"strings"
+	"sync"
+	"time"
+)
+
+// how long the stages are waited for after the deadline of the shutdown is exceeded
+const lateTeardownTimeout = time.Second
+
+type stage struct {
+	name     string
+	teardown func(ctx context.Context) error
+}
+
+// Manager runs the registered teardowns in the order of registration and keeps track of the background jobs
+type Manager struct {
+	timeout time.Duration
+	logger  *zap.Logger
+
+	// context of the jobs, cancelled when they aren't drained in time
+	jobsCtx    context.Context
+	cancelJobs context.CancelFunc
+	jobs       sync.WaitGroup
+
+	mu       sync.Mutex
+	draining bool
+	stages   []stage
+}
+
+func NewManager(timeout time.Duration, logger *zap.Logger) *Manager {
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+
+	return &Manager{
+		timeout:    timeout,
+		logger:     logger,
+		jobsCtx:    jobsCtx,
+		cancelJobs: cancelJobs,
+	}
+}
+
+// OnShutdown registers the teardown of the stage, it's given the context with the deadline of the whole shutdown
+func (m *Manager) OnShutdown(name string, teardown func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stages = append(m.stages, stage{name: name, teardown: teardown})
+}
+
+// Go runs the job in the background, its context is cancelled if the job isn't finished in time on shutdown.
+// The jobs started after the draining has begun are not run at all
+func (m *Manager) Go(name string, job func(ctx context.Context)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.draining {
+		m.logger.Warn("[Shutdown] background job is not started since the peer is shutting down", zap.String("job", name))
+		return
+	}
+
+	m.jobs.Add(1)
+	go func() {
+		defer m.jobs.Done()
+		// the job isn't run by a handler anymore, so nothing else would keep its panic from killing the peer
+		defer func() {
+			if p := recover(); p != nil {
+				m.logger.Error("[Shutdown] background job has panicked", zap.String("job", name), zap.Any("panic", p), zap.Stack("stack"))
+			}
+		}()
+		job(m.jobsCtx)
+	}()
+}
+
+// Drain waits for the background jobs, cancelling them when the context is done first
+func (m *Manager) Drain(ctx context.Context) error {
+	m.mu.Lock()
+	m.draining = true
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.jobs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		m.cancelJobs()
+		return fmt.Errorf("background jobs were cancelled before finishing: %w", ctx.Err())
+	}
+}
+
+// Shutdown tears down all the stages even if some of them fail, the error tells which ones have failed
+func (m *Manager) Shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+	defer m.cancelJobs()
+
+	m.mu.Lock()
+	stages := m.stages
+	m.mu.Unlock()
+
+	var failed []string
+	for _, stage := range stages {
+		start := time.Now()
+		if err := m.run(ctx, stage); err != nil {
+			m.logger.Error("[Shutdown] failed to tear down", zap.String("stage", stage.name), zap.Error(err))
+			failed = append(failed, fmt.Sprintf("%v: %v", stage.name, err))
+			continue
+		}
+		m.logger.Info("[Shutdown] torn down", zap.String("stage", stage.name), zap.Duration("took", time.Since(start)))
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("shutdown has failed: %v", strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// run gives up on the teardown once the deadline is exceeded, so that a stuck one doesn't block the rest.
+// The stages left after the deadline still get a moment, e.g. to close the connections
+func (m *Manager) run(ctx context.Context, stage stage) error {
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), lateTeardownTimeout)
+		defer cancel()
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- stage.teardown(ctx)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return context.DeadlineExceeded
+	}
+}
@@ -0,0 +1,82 @@
+package shutdown
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"testing"
+	"time"
+)
+
+func TestGoRecoversPanics(t *testing.T) {
+	core, logs := observer.New(zapcore.ErrorLevel)
+	m := NewManager(time.Second, zap.New(core))
+
+	m.Go("panicking", func(ctx context.Context) {
+		var tracks []interface{}
+		_ = tracks[0]
+	})
+	finished := make(chan struct{})
+	m.Go("finishing", func(ctx context.Context) {
+		close(finished)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := m.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-finished:
+	default:
+		t.Error("the other job hasn't finished")
+	}
+	panics := logs.FilterField(zap.String("job", "panicking")).All()
+	if len(panics) != 1 {
+		t.Fatalf("got %v panics logged, want 1", len(panics))
+	}
+}
+
+func TestGoAfterDrain(t *testing.T) {
+	m := NewManager(time.Second, zap.NewNop())
+	if err := m.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{}, 1)
+	m.Go("late", func(ctx context.Context) {
+		started <- struct{}{}
+	})
+	if err := m.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-started:
+		t.Error("got the job started while draining")
+	default:
+	}
+}
+
+func TestDrainCancelsJobs(t *testing.T) {
+	m := NewManager(time.Second, zap.NewNop())
+	cancelled := make(chan struct{})
+	m.Go("stuck", func(ctx context.Context) {
+		<-ctx.Done()
+		close(cancelled)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := m.Drain(ctx); err == nil {
+		t.Fatal("got no error, want the stuck job reported")
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("the job's context isn't cancelled")
+	}
+}
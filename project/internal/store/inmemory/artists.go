@@ -0,0 +1,102 @@
+package inmemory
+
+import (
+	"context"
+	"example/hello/project/internal/models"
+	"example/hello/project/internal/store"
+	"fmt"
+	"sort"
+)
+
+type artistsRepository struct {
+	db *DB
+}
+
+func (c artistsRepository) Create(ctx context.Context, artist *models.Artist) error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	if _, ok := c.db.artists[artist.ID]; ok {
+		return fmt.Errorf("artist with id %v already exists", artist.ID)
+	}
+
+	c.db.recordArtist(ctx, artist.ID)
+	c.db.artists[artist.ID] = *artist
+
+	return nil
+}
+
+func (c artistsRepository) All(ctx context.Context, page *models.Page) ([]*models.Artist, error) {
+	c.db.mu.RLock()
+	defer c.db.mu.RUnlock()
+
+	// ordered by ID, the same as MongoDB store returns the pages
+	ids := make([]int, 0, len(c.db.artists))
+	for id := range c.db.artists {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	start, end := paginate(len(ids), page)
+	artists := make([]*models.Artist, 0, end-start)
+	for _, id := range ids[start:end] {
+		artist := c.db.artists[id]
+		artists = append(artists, &artist)
+	}
+
+	return artists, nil
+}
+
+func (c artistsRepository) ByID(ctx context.Context, id int) (*models.Artist, error) {
+	c.db.mu.RLock()
+	defer c.db.mu.RUnlock()
+
+	artist, ok := c.db.artists[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	return &artist, nil
+}
+
+func (c artistsRepository) ByIDs(ctx context.Context, ids []int) ([]*models.Artist, error) {
+	c.db.mu.RLock()
+	defer c.db.mu.RUnlock()
+
+	artists := make([]*models.Artist, 0, len(ids))
+	for _, id := range ids {
+		if artist, ok := c.db.artists[id]; ok {
+			artists = append(artists, &artist)
+		}
+	}
+
+	return artists, nil
+}
+
+func (c artistsRepository) Update(ctx context.Context, artist *models.Artist) error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	if _, ok := c.db.artists[artist.ID]; !ok {
+		return store.ErrNotFound
+	}
+
+	c.db.recordArtist(ctx, artist.ID)
+	c.db.artists[artist.ID] = *artist
+
+	return nil
+}
+
+func (c artistsRepository) Delete(ctx context.Context, id int) error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	if _, ok := c.db.artists[id]; !ok {
+		return store.ErrNotFound
+	}
+
+	c.db.recordArtist(ctx, id)
+	delete(c.db.artists, id)
+
+	return nil
+}
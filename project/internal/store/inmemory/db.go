@@ -0,0 +1,148 @@
+// in-memory store, handy for running the server locally without MongoDB and for testing against it
+
+package inmemory
+
+import (
+	"context"
+	"example/hello/project/internal/models"
+	"example/hello/project/internal/store"
+	"sync"
+)
+
+type DB struct {
+	mu sync.RWMutex
+
+	songs      map[int]models.Song
+	artists    map[int]models.Artist
+	webhooks   map[int]models.Webhook
+	deliveries []models.WebhookDelivery
+	// expired records are only replaced, never removed
+	idempotency map[string]models.IdempotencyRecord
+}
+
+func NewDB() store.Store {
+	return &DB{
+		songs:       make(map[int]models.Song),
+		artists:     make(map[int]models.Artist),
+		webhooks:    make(map[int]models.Webhook),
+		idempotency: make(map[string]models.IdempotencyRecord),
+	}
+}
+
+func (db *DB) Connect(uri string) error {
+	return nil
+}
+
+func (db *DB) Close() error {
+	return nil
+}
+
+func (db *DB) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Transaction reverts the changes made by fn if it fails, the concurrent writes are kept.
+// It isn't isolated from them though, e.g. the song updated by both fn and another request is restored as fn has found it
+func (db *DB) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	log := &undoLog{db: db}
+	if err := fn(context.WithValue(ctx, undoLogKey{}, log)); err != nil {
+		db.mu.Lock()
+		log.rollback()
+		db.mu.Unlock()
+		return err
+	}
+
+	// the changes of the nested transaction are reverted along with the outer one
+	if outer, ok := ctx.Value(undoLogKey{}).(*undoLog); ok && outer.db == db {
+		db.mu.Lock()
+		outer.undos = append(outer.undos, log.undos...)
+		db.mu.Unlock()
+	}
+
+	return nil
+}
+
+type undoLogKey struct{}
+
+// undoLog holds what's needed to revert the changes of the transaction, it's guarded by the lock of the DB
+type undoLog struct {
+	db    *DB
+	undos []func()
+}
+
+func (l *undoLog) rollback() {
+	for i := len(l.undos) - 1; i >= 0; i-- {
+		l.undos[i]()
+	}
+}
+
+// record keeps the undo of the change made within the transaction of ctx, it's called with the lock held
+func (db *DB) record(ctx context.Context, undo func()) {
+	if log, ok := ctx.Value(undoLogKey{}).(*undoLog); ok && log.db == db {
+		log.undos = append(log.undos, undo)
+	}
+}
+
+// recordSong is called before the song is written, so that it's restored as it is now
+func (db *DB) recordSong(ctx context.Context, id int) {
+	song, ok := db.songs[id]
+	db.record(ctx, func() {
+		if ok {
+			db.songs[id] = song
+		} else {
+			delete(db.songs, id)
+		}
+	})
+}
+
+func (db *DB) recordArtist(ctx context.Context, id int) {
+	artist, ok := db.artists[id]
+	db.record(ctx, func() {
+		if ok {
+			db.artists[id] = artist
+		} else {
+			delete(db.artists, id)
+		}
+	})
+}
+
+func (db *DB) recordWebhook(ctx context.Context, id int) {
+	webhook, ok := db.webhooks[id]
+	db.record(ctx, func() {
+		if ok {
+			db.webhooks[id] = webhook
+		} else {
+			delete(db.webhooks, id)
+		}
+	})
+}
+
+func (db *DB) Songs() store.SongsRepository {
+	return songsRepository{db}
+}
+
+func (db *DB) Artists() store.ArtistsRepository {
+	return artistsRepository{db}
+}
+
+func (db *DB) Webhooks() store.WebhooksRepository {
+	return webhooksRepository{db}
+}
+
+func (db *DB) Idempotency() store.IdempotencyRepository {
+	return idempotencyRepository{db}
+}
+
+// paginate returns the bounds of the page within n items
+func paginate(n int, page *models.Page) (int, int) {
+	start := page.Offset
+	if start > n {
+		start = n
+	}
+	end := n
+	if page.Limit > 0 && start+page.Limit < end {
+		end = start + page.Limit
+	}
+
+	return start, end
+}
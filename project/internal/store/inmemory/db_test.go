@@ -0,0 +1,131 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"example/hello/project/internal/models"
+	"example/hello/project/internal/store"
+	"testing"
+)
+
+func TestTransaction(t *testing.T) {
+	errAborted := errors.New("aborted")
+
+	tests := []struct {
+		name string
+		fn   func(ctx context.Context, db store.Store) error
+		// full names of the artists there are after the transaction
+		wantArtists map[int]string
+		wantSong    bool
+	}{
+		{
+			name: "committed",
+			fn: func(ctx context.Context, db store.Store) error {
+				if err := db.Artists().Create(ctx, &models.Artist{ID: 2, FullName: "Queen"}); err != nil {
+					return err
+				}
+				return db.Songs().Delete(ctx, 1)
+			},
+			wantArtists: map[int]string{1: "ABBA", 2: "Queen", 3: "Muse"},
+			wantSong:    false,
+		},
+		{
+			name: "rolled back",
+			fn: func(ctx context.Context, db store.Store) error {
+				if err := db.Artists().Create(ctx, &models.Artist{ID: 2, FullName: "Queen"}); err != nil {
+					return err
+				}
+				if err := db.Artists().Update(ctx, &models.Artist{ID: 1, FullName: "A*B*B*A"}); err != nil {
+					return err
+				}
+				if err := db.Artists().Update(ctx, &models.Artist{ID: 1, FullName: "Abba"}); err != nil {
+					return err
+				}
+				if err := db.Songs().Delete(ctx, 1); err != nil {
+					return err
+				}
+				return errAborted
+			},
+			wantArtists: map[int]string{1: "ABBA", 3: "Muse"},
+			wantSong:    true,
+		},
+		{
+			name: "nested rolled back along with the outer one",
+			fn: func(ctx context.Context, db store.Store) error {
+				err := db.Transaction(ctx, func(ctx context.Context) error {
+					return db.Artists().Create(ctx, &models.Artist{ID: 2, FullName: "Queen"})
+				})
+				if err != nil {
+					return err
+				}
+				return errAborted
+			},
+			wantArtists: map[int]string{1: "ABBA", 3: "Muse"},
+			wantSong:    true,
+		},
+		{
+			name: "nested rolled back alone",
+			fn: func(ctx context.Context, db store.Store) error {
+				if err := db.Artists().Create(ctx, &models.Artist{ID: 2, FullName: "Queen"}); err != nil {
+					return err
+				}
+				_ = db.Transaction(ctx, func(ctx context.Context) error {
+					if err := db.Songs().Delete(ctx, 1); err != nil {
+						return err
+					}
+					return errAborted
+				})
+				return nil
+			},
+			wantArtists: map[int]string{1: "ABBA", 2: "Queen", 3: "Muse"},
+			wantSong:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := NewDB()
+			if err := db.Artists().Create(ctx, &models.Artist{ID: 1, FullName: "ABBA"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.Songs().Create(ctx, &models.Song{ID: 1, Title: "Waterloo", ArtistID: 1}); err != nil {
+				t.Fatal(err)
+			}
+
+			_ = db.Transaction(ctx, func(txCtx context.Context) error {
+				// written by another request meanwhile, it's kept whatever happens to the transaction
+				if err := db.Artists().Create(ctx, &models.Artist{ID: 3, FullName: "Muse"}); err != nil {
+					t.Fatal(err)
+				}
+				return tt.fn(txCtx, db)
+			})
+
+			artists, err := db.Artists().All(ctx, &models.Page{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[int]string)
+			for _, artist := range artists {
+				got[artist.ID] = artist.FullName
+			}
+			if len(got) != len(tt.wantArtists) {
+				t.Errorf("got artists %v, want %v", got, tt.wantArtists)
+			}
+			for id, fullName := range tt.wantArtists {
+				if got[id] != fullName {
+					t.Errorf("got artists %v, want %v", got, tt.wantArtists)
+					break
+				}
+			}
+
+			song, err := db.Songs().ByID(ctx, 1)
+			if tt.wantSong && (err != nil || song.Title != "Waterloo") {
+				t.Errorf("got song %+v, %v, want it restored", song, err)
+			}
+			if !tt.wantSong && !errors.Is(err, store.ErrNotFound) {
+				t.Errorf("got song %+v, %v, want it deleted", song, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"example/hello/project/internal/models"
+	"time"
+)
+
+type idempotencyRepository struct {
+	db *DB
+}
+
+func (c idempotencyRepository) Reserve(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	now := time.Now()
+	if existing, ok := c.db.idempotency[record.Key]; ok && existing.ExpiresAt.After(now) &&
+		(existing.Completed || existing.LockedUntil.After(now)) {
+		return &existing, nil
+	}
+
+	c.db.idempotency[record.Key] = *record
+
+	return nil, nil
+}
+
+func (c idempotencyRepository) Complete(ctx context.Context, record *models.IdempotencyRecord) error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	existing, ok := c.db.idempotency[record.Key]
+	if !ok {
+		return errors.New("either no or more than one idempotency key has been matched")
+	}
+
+	existing.Completed = true
+	existing.StatusCode = record.StatusCode
+	existing.ContentType = record.ContentType
+	existing.Body = record.Body
+	c.db.idempotency[record.Key] = existing
+
+	return nil
+}
+
+func (c idempotencyRepository) Release(ctx context.Context, key string) error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	delete(c.db.idempotency, key)
+
+	return nil
+}
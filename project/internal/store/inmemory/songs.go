@@ -0,0 +1,126 @@
+package inmemory
+
+import (
+	"context"
+	"example/hello/project/internal/models"
+	"example/hello/project/internal/store"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+type songsRepository struct {
+	db *DB
+}
+
+func (c songsRepository) GetArtist(ctx context.Context, song *models.Song) (*models.Artist, error) {
+	c.db.mu.RLock()
+	defer c.db.mu.RUnlock()
+
+	artist, ok := c.db.artists[song.ArtistID]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	return &artist, nil
+}
+
+func (c songsRepository) Create(ctx context.Context, song *models.Song) error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	if _, ok := c.db.artists[song.ArtistID]; !ok {
+		return fmt.Errorf("artist with id %v doesn't exist", song.ArtistID)
+	}
+	if _, ok := c.db.songs[song.ID]; ok {
+		return fmt.Errorf("song with id %v already exists", song.ID)
+	}
+
+	c.db.recordSong(ctx, song.ID)
+	c.db.songs[song.ID] = *song
+
+	return nil
+}
+
+func (c songsRepository) All(ctx context.Context, filter *models.Filter) ([]*models.Song, error) {
+	var titleRegex *regexp.Regexp
+	if filter.Query != nil {
+		// case insensitive search, the same as MongoDB store does
+		var err error
+		if titleRegex, err = regexp.Compile("(?i)" + *filter.Query); err != nil {
+			return nil, err
+		}
+	}
+
+	c.db.mu.RLock()
+	defer c.db.mu.RUnlock()
+
+	// ordered by ID, the same as MongoDB store returns the pages
+	ids := make([]int, 0, len(c.db.songs))
+	for id := range c.db.songs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	songs := make([]*models.Song, 0, len(ids))
+	for _, id := range ids {
+		song := c.db.songs[id]
+		if titleRegex != nil && !titleRegex.MatchString(song.Title) {
+			continue
+		}
+		if filter.ArtistID != nil && song.ArtistID != *filter.ArtistID {
+			continue
+		}
+		songs = append(songs, &song)
+	}
+
+	start, end := paginate(len(songs), &filter.Page)
+	return songs[start:end], nil
+}
+
+func (c songsRepository) ByID(ctx context.Context, id int) (*models.Song, error) {
+	c.db.mu.RLock()
+	defer c.db.mu.RUnlock()
+
+	song, ok := c.db.songs[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	return &song, nil
+}
+
+func (c songsRepository) ByArtistID(ctx context.Context, artistID int, page *models.Page) ([]*models.Song, error) {
+	return c.All(ctx, &models.Filter{ArtistID: &artistID, Page: *page})
+}
+
+func (c songsRepository) Update(ctx context.Context, song *models.Song) error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	if _, ok := c.db.artists[song.ArtistID]; !ok {
+		return fmt.Errorf("artist with %+v doesn't exist", song.ArtistID)
+	}
+	if _, ok := c.db.songs[song.ID]; !ok {
+		return store.ErrNotFound
+	}
+
+	c.db.recordSong(ctx, song.ID)
+	c.db.songs[song.ID] = *song
+
+	return nil
+}
+
+func (c songsRepository) Delete(ctx context.Context, id int) error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	if _, ok := c.db.songs[id]; !ok {
+		return store.ErrNotFound
+	}
+
+	c.db.recordSong(ctx, id)
+	delete(c.db.songs, id)
+
+	return nil
+}
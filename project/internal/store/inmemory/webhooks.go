@@ -0,0 +1,162 @@
+package inmemory
+
+import (
+	"context"
+	"example/hello/project/internal/models"
+	"example/hello/project/internal/store"
+	"fmt"
+	"sort"
+)
+
+// number of the most recent deliveries returned from the log
+const deliveriesPageSize = 50
+
+type webhooksRepository struct {
+	db *DB
+}
+
+// copyWebhook doesn't let the callers share the slice of event types with the store
+func copyWebhook(webhook models.Webhook) *models.Webhook {
+	webhook.EventTypes = append([]models.EventType(nil), webhook.EventTypes...)
+	return &webhook
+}
+
+func (c webhooksRepository) Create(ctx context.Context, webhook *models.Webhook) error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	if _, ok := c.db.webhooks[webhook.ID]; ok {
+		return fmt.Errorf("webhook with id %v already exists", webhook.ID)
+	}
+
+	c.db.recordWebhook(ctx, webhook.ID)
+	c.db.webhooks[webhook.ID] = *copyWebhook(*webhook)
+
+	return nil
+}
+
+func (c webhooksRepository) All(ctx context.Context) ([]*models.Webhook, error) {
+	return c.find(func(webhook *models.Webhook) bool {
+		return true
+	}), nil
+}
+
+func (c webhooksRepository) ByID(ctx context.Context, id int) (*models.Webhook, error) {
+	c.db.mu.RLock()
+	defer c.db.mu.RUnlock()
+
+	webhook, ok := c.db.webhooks[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	return copyWebhook(webhook), nil
+}
+
+func (c webhooksRepository) ByEventType(ctx context.Context, eventType models.EventType) ([]*models.Webhook, error) {
+	return c.find(func(webhook *models.Webhook) bool {
+		for _, subscribed := range webhook.EventTypes {
+			if subscribed == eventType {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func (c webhooksRepository) find(match func(webhook *models.Webhook) bool) []*models.Webhook {
+	c.db.mu.RLock()
+	defer c.db.mu.RUnlock()
+
+	// ordered by ID, the same as MongoDB store returns the pages
+	ids := make([]int, 0, len(c.db.webhooks))
+	for id := range c.db.webhooks {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	var webhooks []*models.Webhook
+	for _, id := range ids {
+		if webhook := copyWebhook(c.db.webhooks[id]); match(webhook) {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+
+	return webhooks
+}
+
+func (c webhooksRepository) Update(ctx context.Context, webhook *models.Webhook) error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	if _, ok := c.db.webhooks[webhook.ID]; !ok {
+		return store.ErrNotFound
+	}
+
+	c.db.recordWebhook(ctx, webhook.ID)
+	c.db.webhooks[webhook.ID] = *copyWebhook(*webhook)
+
+	return nil
+}
+
+func (c webhooksRepository) Delete(ctx context.Context, id int) error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	if _, ok := c.db.webhooks[id]; !ok {
+		return store.ErrNotFound
+	}
+
+	c.db.recordWebhook(ctx, id)
+	delete(c.db.webhooks, id)
+
+	return nil
+}
+
+func (c webhooksRepository) LogDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	i := len(c.db.deliveries)
+	c.db.record(ctx, func() {
+		c.db.deliveries = append(c.db.deliveries[:i:i], c.db.deliveries[i+1:]...)
+	})
+	c.db.deliveries = append(c.db.deliveries, *delivery)
+
+	return nil
+}
+
+func (c webhooksRepository) Deliveries(ctx context.Context, webhookID int) ([]*models.WebhookDelivery, error) {
+	return c.findDeliveries(func(delivery *models.WebhookDelivery) bool {
+		return delivery.WebhookID == webhookID
+	}), nil
+}
+
+func (c webhooksRepository) DeadLetters(ctx context.Context) ([]*models.WebhookDelivery, error) {
+	return c.findDeliveries(func(delivery *models.WebhookDelivery) bool {
+		return delivery.Status == models.DeliveryStatusDead
+	}), nil
+}
+
+// findDeliveries returns the most recent matching deliveries first
+func (c webhooksRepository) findDeliveries(match func(delivery *models.WebhookDelivery) bool) []*models.WebhookDelivery {
+	c.db.mu.RLock()
+	defer c.db.mu.RUnlock()
+
+	var deliveries []*models.WebhookDelivery
+	for i := range c.db.deliveries {
+		delivery := c.db.deliveries[i]
+		if match(&delivery) {
+			deliveries = append(deliveries, &delivery)
+		}
+	}
+
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		return deliveries[i].Time.After(deliveries[j].Time)
+	})
+	if len(deliveries) > deliveriesPageSize {
+		deliveries = deliveries[:deliveriesPageSize]
+	}
+
+	return deliveries
+}
@@ -2,24 +2,24 @@ package mongodb
 
 import (
 	"context"
-	"errors"
+	"example/hello/project/internal/logger"
 	"example/hello/project/internal/models"
 	"example/hello/project/internal/store"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"log"
+	"go.uber.org/zap"
 )
 
-func (db DB) Artists() store.ArtistsRepository {
+func (db *DB) Artists() store.ArtistsRepository {
 	if db.artists == nil {
-		artists, err := NewArtistsRepository(db.client)
+		artists, err := NewArtistsRepository(db.client, db.logger)
 
 		if err != nil {
-			log.Fatalf("got an error while creating a %v collection with constraints. Err: %v", "artists", err)
+			db.logger.Fatal("got an error while creating a collection with constraints", zap.String("collection", "artists"), zap.Error(err))
 			return nil
 		}
-		db.artists = artists
+		db.artists = tracedArtistsRepository{next: artists}
 	}
 
 	return db.artists
@@ -27,11 +27,12 @@ func (db DB) Artists() store.ArtistsRepository {
 
 type ArtistsRepository struct {
 	client *mongo.Client
+	logger *zap.Logger
 
 	collection *mongo.Collection
 }
 
-func NewArtistsRepository(client *mongo.Client) (store.ArtistsRepository, error) {
+func NewArtistsRepository(client *mongo.Client, logger *zap.Logger) (store.ArtistsRepository, error) {
 	// if either the database or the collection doesn't exist, the following line will create them
 	artistsCollection := client.Database("lostify").Collection("artists")
 	// creating an index so that `ID` field is unique
@@ -49,6 +50,7 @@ func NewArtistsRepository(client *mongo.Client) (store.ArtistsRepository, error)
 
 	return &ArtistsRepository{
 		client:     client,
+		logger:     logger,
 		collection: client.Database("lostify").Collection("artists"),
 	}, nil
 }
@@ -59,16 +61,14 @@ func (c ArtistsRepository) Create(ctx context.Context, artist *models.Artist) er
 		return err
 	}
 
-	log.Println("inserted an artist:", insertResult.InsertedID)
+	logger.FromContext(ctx, c.logger).Debug("inserted an artist", zap.Any("inserted_id", insertResult.InsertedID))
 
 	return nil
 }
 
-func (c ArtistsRepository) All(ctx context.Context) ([]*models.Artist, error) {
+func (c ArtistsRepository) All(ctx context.Context, page *models.Page) ([]*models.Artist, error) {
 	// pass these options to the Find method
-	findOptions := options.Find()
-	// page size is set to 10
-	findOptions.SetLimit(10)
+	findOptions := pageOptions(page)
 
 	// here's an array in which you can store the decoded documents
 	var artists []*models.Artist
@@ -102,7 +102,7 @@ func (c ArtistsRepository) All(ctx context.Context) ([]*models.Artist, error) {
 		return nil, err
 	}
 
-	log.Printf("found multiple artists (array of pointers): %+v\n", artists)
+	logger.FromContext(ctx, c.logger).Debug("found multiple artists", zap.Int("count", len(artists)))
 
 	return artists, nil
 }
@@ -114,14 +114,33 @@ func (c ArtistsRepository) ByID(ctx context.Context, id int) (*models.Artist, er
 	filter := bson.M{"id": id}
 
 	if err := c.collection.FindOne(ctx, filter).Decode(&artist); err != nil {
-		return nil, err
+		return nil, notFound(err)
 	}
 
-	log.Printf("found an artist: %+v\n", artist)
+	logger.FromContext(ctx, c.logger).Debug("found an artist", zap.Int("id", id))
 
 	return &artist, nil
 }
 
+func (c ArtistsRepository) ByIDs(ctx context.Context, ids []int) ([]*models.Artist, error) {
+	filter := bson.M{"id": bson.M{"$in": ids}}
+
+	cur, err := c.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// decoding all the documents at once, closes the cursor as well
+	var artists []*models.Artist
+	if err := cur.All(ctx, &artists); err != nil {
+		return nil, err
+	}
+
+	logger.FromContext(ctx, c.logger).Debug("found artists by ids", zap.Ints("ids", ids), zap.Int("count", len(artists)))
+
+	return artists, nil
+}
+
 func (c ArtistsRepository) Update(ctx context.Context, artist *models.Artist) error {
 	filter := bson.M{"id": artist.ID}
 
@@ -130,10 +149,13 @@ func (c ArtistsRepository) Update(ctx context.Context, artist *models.Artist) er
 		return err
 	}
 
-	log.Printf("matched %v documents and updated %v documents.\n", updateResult.MatchedCount, updateResult.ModifiedCount)
+	logger.FromContext(ctx, c.logger).Debug("updated artist documents",
+		zap.Int64("matched", updateResult.MatchedCount),
+		zap.Int64("modified", updateResult.ModifiedCount),
+	)
 
-	if updateResult.MatchedCount != 1 {
-		return errors.New("either no or more than one artist has been matched")
+	if updateResult.MatchedCount == 0 {
+		return store.ErrNotFound
 	}
 
 	return nil
@@ -147,10 +169,10 @@ func (c ArtistsRepository) Delete(ctx context.Context, id int) error {
 		return err
 	}
 
-	log.Printf("deleted %v documents in the artists collection\n", deleteResult.DeletedCount)
+	logger.FromContext(ctx, c.logger).Debug("deleted documents in the artists collection", zap.Int64("deleted", deleteResult.DeletedCount))
 
-	if deleteResult.DeletedCount != 1 {
-		return errors.New("either no or more than one artist has been matched")
+	if deleteResult.DeletedCount == 0 {
+		return store.ErrNotFound
 	}
 
 	return nil
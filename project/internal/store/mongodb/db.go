@@ -4,22 +4,32 @@ package mongodb
 
 import (
 	"context"
+	"errors"
+	"example/hello/project/internal/models"
 	"example/hello/project/internal/store"
-	"log"
+	"example/hello/project/internal/tracing"
+	"go.mongodb.org/mongo-driver/bson"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 type DB struct {
 	client *mongo.Client
+	logger *zap.Logger
 
-	songs   store.SongsRepository
-	artists store.ArtistsRepository
+	songs       store.SongsRepository
+	artists     store.ArtistsRepository
+	webhooks    store.WebhooksRepository
+	idempotency store.IdempotencyRepository
 }
 
-func NewDB() store.Store {
-	return &DB{}
+func NewDB(logger *zap.Logger) store.Store {
+	return &DB{logger: logger}
 }
 
 func (db *DB) Connect(uri string) error {
@@ -38,12 +48,43 @@ func (db *DB) Connect(uri string) error {
 		return err
 	}
 
-	log.Println("[DB] connected to MongoDB!")
+	db.logger.Info("[DB] connected to MongoDB!")
 
 	db.client = client
 	return nil
 }
 
+func (db *DB) Ping(ctx context.Context) error {
+	if db.client == nil {
+		return errors.New("not connected to MongoDB")
+	}
+
+	return db.client.Ping(ctx, readpref.Primary())
+}
+
+// Transaction requires a replica set (Atlas clusters are), the session is carried by the ctx passed to fn.
+// fn might be called more than once, since the transaction is retried on transient errors.
+// https://docs.mongodb.com/manual/core/transactions/
+func (db *DB) Transaction(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	ctx, span := tracer.Start(ctx, "transaction", trace.WithAttributes(semconv.DBSystemMongoDB))
+	defer func() { tracing.End(span, err) }()
+
+	// collections can't be created within a transaction, so the repositories are set up beforehand
+	db.Songs()
+	db.Artists()
+
+	session, err := db.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	return err
+}
+
 func (db *DB) Close() error {
 	// disconnecting
 	err := db.client.Disconnect(context.TODO())
@@ -51,6 +92,25 @@ func (db *DB) Close() error {
 		return err
 	}
 
-	log.Println("[DB] closed the connection to MongoDB!")
+	db.logger.Info("[DB] closed the connection to MongoDB!")
 	return nil
 }
+
+// pageOptions sorts the documents by ID, so that the pages don't overlap
+func pageOptions(page *models.Page) *options.FindOptions {
+	findOptions := options.Find()
+	findOptions.SetSort(bson.D{{Key: "id", Value: 1}})
+	findOptions.SetSkip(int64(page.Offset))
+	findOptions.SetLimit(int64(page.Limit))
+
+	return findOptions
+}
+
+// notFound turns the error of FindOne matching no document into the one of the store
+func notFound(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return store.ErrNotFound
+	}
+
+	return err
+}
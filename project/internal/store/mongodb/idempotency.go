@@ -0,0 +1,117 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"example/hello/project/internal/logger"
+	"example/hello/project/internal/models"
+	"example/hello/project/internal/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"time"
+)
+
+func (db *DB) Idempotency() store.IdempotencyRepository {
+	if db.idempotency == nil {
+		idempotency, err := NewIdempotencyRepository(db.client, db.logger)
+
+		if err != nil {
+			db.logger.Fatal("got an error while creating a collection with constraints", zap.String("collection", "idempotency_keys"), zap.Error(err))
+			return nil
+		}
+		db.idempotency = tracedIdempotencyRepository{next: idempotency}
+	}
+
+	return db.idempotency
+}
+
+type IdempotencyRepository struct {
+	client *mongo.Client
+	logger *zap.Logger
+
+	collection *mongo.Collection
+}
+
+func NewIdempotencyRepository(client *mongo.Client, logger *zap.Logger) (store.IdempotencyRepository, error) {
+	idempotencyCollection := client.Database("lostify").Collection("idempotency_keys")
+	_, err := idempotencyCollection.Indexes().CreateMany(
+		context.Background(),
+		[]mongo.IndexModel{
+			// only one record per key
+			{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+			// expired records are removed by MongoDB itself (the background task runs every 60 seconds)
+			// https://docs.mongodb.com/manual/core/index-ttl/
+			{Keys: bson.D{{Key: "expiresat", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IdempotencyRepository{
+		client:     client,
+		logger:     logger,
+		collection: idempotencyCollection,
+	}, nil
+}
+
+func (c IdempotencyRepository) Reserve(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	// TTL monitor might not have removed the expired record yet, the lapsed reservation is taken over
+	now := time.Now()
+	_, err := c.collection.DeleteOne(ctx, bson.M{"key": record.Key, "$or": bson.A{
+		bson.M{"expiresat": bson.M{"$lte": now}},
+		bson.M{"completed": false, "lockeduntil": bson.M{"$lte": now}},
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	// inserts the record only if there's none with the same key, the existing one is returned otherwise
+	findOptions := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.Before)
+
+	var existing models.IdempotencyRecord
+	err = c.collection.FindOneAndUpdate(ctx, bson.M{"key": record.Key}, bson.M{"$setOnInsert": record}, findOptions).Decode(&existing)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		logger.FromContext(ctx, c.logger).Debug("reserved an idempotency key", zap.String("key", record.Key))
+		return nil, nil
+	}
+	// the concurrent upserts of the same key might both try to insert, the one losing the race
+	// fails on the unique index and gets the record of the winner
+	if mongo.IsDuplicateKeyError(err) {
+		err = c.collection.FindOne(ctx, bson.M{"key": record.Key}).Decode(&existing)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &existing, nil
+}
+
+func (c IdempotencyRepository) Complete(ctx context.Context, record *models.IdempotencyRecord) error {
+	update := bson.M{"$set": bson.M{
+		"completed":   true,
+		"statuscode":  record.StatusCode,
+		"contenttype": record.ContentType,
+		"body":        record.Body,
+	}}
+
+	updateResult, err := c.collection.UpdateOne(ctx, bson.M{"key": record.Key}, update)
+	if err != nil {
+		return err
+	}
+
+	if updateResult.MatchedCount != 1 {
+		return errors.New("either no or more than one idempotency key has been matched")
+	}
+
+	return nil
+}
+
+func (c IdempotencyRepository) Release(ctx context.Context, key string) error {
+	_, err := c.collection.DeleteOne(ctx, bson.M{"key": key})
+	return err
+}
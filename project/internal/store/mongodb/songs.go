@@ -6,26 +6,27 @@ package mongodb
 import (
 	"context"
 	"errors"
+	"example/hello/project/internal/logger"
 	"example/hello/project/internal/models"
 	"example/hello/project/internal/store"
 	"fmt"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"log"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
 )
 
 func (db *DB) Songs() store.SongsRepository {
 	if db.songs == nil {
-		songs, err := NewSongsRepository(db.client)
+		songs, err := NewSongsRepository(db.client, db.logger)
 
 		if err != nil {
-			log.Fatalf("got an error while creating a %v collection with constraints. Err: %v", "songs", err)
+			db.logger.Fatal("got an error while creating a collection with constraints", zap.String("collection", "songs"), zap.Error(err))
 			return nil
 		}
-		db.songs = songs
+		db.songs = tracedSongsRepository{next: songs}
 	}
 
 	return db.songs
@@ -33,11 +34,12 @@ func (db *DB) Songs() store.SongsRepository {
 
 type SongsRepository struct {
 	client *mongo.Client
+	logger *zap.Logger
 
 	collection *mongo.Collection
 }
 
-func NewSongsRepository(client *mongo.Client) (store.SongsRepository, error) {
+func NewSongsRepository(client *mongo.Client, logger *zap.Logger) (store.SongsRepository, error) {
 	// if either the database or the collection doesn't exist, the following line will create them
 	songsCollection := client.Database("lostify").Collection("songs")
 	// creating an index so that `ID` field is unique
@@ -52,9 +54,20 @@ func NewSongsRepository(client *mongo.Client) (store.SongsRepository, error) {
 	if err != nil {
 		return nil, err
 	}
+	// discography of the artist is looked up by the artist and ordered by ID
+	_, err = songsCollection.Indexes().CreateOne(
+		context.Background(),
+		mongo.IndexModel{
+			Keys: bson.D{{Key: "artistid", Value: 1}, {Key: "id", Value: 1}},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
 
 	return &SongsRepository{
 		client:     client,
+		logger:     logger,
 		collection: client.Database("lostify").Collection("songs"),
 	}, nil
 }
@@ -71,7 +84,7 @@ func (c SongsRepository) GetArtist(ctx context.Context, song *models.Song) (*mod
 		return nil, err
 	}
 
-	log.Printf("found an artist with artistID %v: %+v\n", song.ArtistID, artist)
+	logger.FromContext(ctx, c.logger).Debug("found an artist of the song", zap.Int("artist_id", song.ArtistID))
 
 	return &artist, nil
 }
@@ -87,29 +100,29 @@ func (c SongsRepository) Create(ctx context.Context, song *models.Song) error {
 		return err
 	}
 
-	log.Println("inserted a song:", insertResult.InsertedID)
+	logger.FromContext(ctx, c.logger).Debug("inserted a song", zap.Any("inserted_id", insertResult.InsertedID))
 
 	return nil
 }
 
 func (c SongsRepository) All(ctx context.Context, filter *models.Filter) ([]*models.Song, error) {
 	// pass these options to the Find method
-	findOptions := options.Find()
-	// page size is set to 10
-	findOptions.SetLimit(10)
+	findOptions := pageOptions(&filter.Page)
 
 	// here's an array in which you can store the decoded documents
 	var songs []*models.Song
 
-	// bson.D{{}} corresponds to the filter that matches all documents in the collection
-	bsonFilter := bson.D{{}}
+	// empty bson.D{} corresponds to the filter that matches all documents in the collection
+	bsonFilter := bson.D{}
 	if filter.Query != nil {
 		// finds any songs with title that contains filter.Query (case insensitive search)
 		// https://docs.mongodb.com/v4.4/tutorial/query-documents/
 		// https://stackoverflow.com/questions/3305561/how-to-query-mongodb-with-like
-		bsonFilter = bson.D{
-			{"title", primitive.Regex{Pattern: *filter.Query, Options: "i"}},
-		}
+		bsonFilter = append(bsonFilter, bson.E{Key: "title", Value: primitive.Regex{Pattern: *filter.Query, Options: "i"}})
+	}
+	if filter.ArtistID != nil {
+		// there are no bson tags in models, so the driver stores field names in lowercase
+		bsonFilter = append(bsonFilter, bson.E{Key: "artistid", Value: *filter.ArtistID})
 	}
 	cur, err := c.collection.Find(ctx, bsonFilter, findOptions)
 	if err != nil {
@@ -139,7 +152,7 @@ func (c SongsRepository) All(ctx context.Context, filter *models.Filter) ([]*mod
 		return nil, err
 	}
 
-	log.Printf("found multiple songs (array of pointers): %+v\n", songs)
+	logger.FromContext(ctx, c.logger).Debug("found multiple songs", zap.Int("count", len(songs)))
 
 	return songs, nil
 }
@@ -151,14 +164,31 @@ func (c SongsRepository) ByID(ctx context.Context, id int) (*models.Song, error)
 	filter := bson.M{"id": id}
 
 	if err := c.collection.FindOne(ctx, filter).Decode(&song); err != nil {
-		return nil, err
+		return nil, notFound(err)
 	}
 
-	log.Printf("found a song: %+v\n", song)
+	logger.FromContext(ctx, c.logger).Debug("found a song", zap.Int("id", id))
 
 	return &song, nil
 }
 
+func (c SongsRepository) ByArtistID(ctx context.Context, artistID int, page *models.Page) ([]*models.Song, error) {
+	cur, err := c.collection.Find(ctx, bson.M{"artistid": artistID}, pageOptions(page))
+	if err != nil {
+		return nil, err
+	}
+
+	// decoding all the documents at once, closes the cursor as well
+	var songs []*models.Song
+	if err := cur.All(ctx, &songs); err != nil {
+		return nil, err
+	}
+
+	logger.FromContext(ctx, c.logger).Debug("found songs of the artist", zap.Int("artist_id", artistID), zap.Int("count", len(songs)))
+
+	return songs, nil
+}
+
 func (c SongsRepository) Update(ctx context.Context, song *models.Song) error {
 	_, err := c.GetArtist(ctx, song)
 	if err != nil {
@@ -172,10 +202,13 @@ func (c SongsRepository) Update(ctx context.Context, song *models.Song) error {
 		return err
 	}
 
-	log.Printf("matched %v documents and updated %v documents.\n", updateResult.MatchedCount, updateResult.ModifiedCount)
+	logger.FromContext(ctx, c.logger).Debug("updated song documents",
+		zap.Int64("matched", updateResult.MatchedCount),
+		zap.Int64("modified", updateResult.ModifiedCount),
+	)
 
-	if updateResult.MatchedCount != 1 {
-		return errors.New("either no or more than one song has been matched")
+	if updateResult.MatchedCount == 0 {
+		return store.ErrNotFound
 	}
 
 	return nil
@@ -189,10 +222,10 @@ func (c SongsRepository) Delete(ctx context.Context, id int) error {
 		return err
 	}
 
-	log.Printf("deleted %v documents in the songs collection\n", deleteResult.DeletedCount)
+	logger.FromContext(ctx, c.logger).Debug("deleted documents in the songs collection", zap.Int64("deleted", deleteResult.DeletedCount))
 
-	if deleteResult.DeletedCount != 1 {
-		return errors.New("either no or more than one song has been matched")
+	if deleteResult.DeletedCount == 0 {
+		return store.ErrNotFound
 	}
 
 	return nil
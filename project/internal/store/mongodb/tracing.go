@@ -0,0 +1,230 @@
+package mongodb
+
+import (
+	"context"
+	"example/hello/project/internal/models"
+	"example/hello/project/internal/store"
+	"example/hello/project/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("store/mongodb")
+
+func startSpan(ctx context.Context, collection, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs,
+		semconv.DBSystemMongoDB,
+		semconv.DBNameKey.String("lostify"),
+		semconv.DBMongoDBCollectionKey.String(collection),
+		semconv.DBOperationKey.String(operation),
+	)
+
+	return tracer.Start(ctx, collection+"."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// tracedSongsRepository wraps every call to the underlying repository in a span
+type tracedSongsRepository struct {
+	next store.SongsRepository
+}
+
+func (t tracedSongsRepository) GetArtist(ctx context.Context, song *models.Song) (*models.Artist, error) {
+	ctx, span := startSpan(ctx, "songs", "GetArtist", attribute.Int("artist.id", song.ArtistID))
+	artist, err := t.next.GetArtist(ctx, song)
+	tracing.End(span, err)
+	return artist, err
+}
+
+func (t tracedSongsRepository) Create(ctx context.Context, song *models.Song) error {
+	ctx, span := startSpan(ctx, "songs", "Create", attribute.Int("song.id", song.ID))
+	err := t.next.Create(ctx, song)
+	tracing.End(span, err)
+	return err
+}
+
+func (t tracedSongsRepository) All(ctx context.Context, filter *models.Filter) ([]*models.Song, error) {
+	ctx, span := startSpan(ctx, "songs", "All", attribute.Int("page.limit", filter.Limit), attribute.Int("page.offset", filter.Offset))
+	songs, err := t.next.All(ctx, filter)
+	span.SetAttributes(attribute.Int("songs.count", len(songs)))
+	tracing.End(span, err)
+	return songs, err
+}
+
+func (t tracedSongsRepository) ByID(ctx context.Context, id int) (*models.Song, error) {
+	ctx, span := startSpan(ctx, "songs", "ByID", attribute.Int("song.id", id))
+	song, err := t.next.ByID(ctx, id)
+	tracing.End(span, err)
+	return song, err
+}
+
+func (t tracedSongsRepository) ByArtistID(ctx context.Context, artistID int, page *models.Page) ([]*models.Song, error) {
+	ctx, span := startSpan(ctx, "songs", "ByArtistID",
+		attribute.Int("artist.id", artistID),
+		attribute.Int("page.limit", page.Limit),
+		attribute.Int("page.offset", page.Offset),
+	)
+	songs, err := t.next.ByArtistID(ctx, artistID, page)
+	span.SetAttributes(attribute.Int("songs.count", len(songs)))
+	tracing.End(span, err)
+	return songs, err
+}
+
+func (t tracedSongsRepository) Update(ctx context.Context, song *models.Song) error {
+	ctx, span := startSpan(ctx, "songs", "Update", attribute.Int("song.id", song.ID))
+	err := t.next.Update(ctx, song)
+	tracing.End(span, err)
+	return err
+}
+
+func (t tracedSongsRepository) Delete(ctx context.Context, id int) error {
+	ctx, span := startSpan(ctx, "songs", "Delete", attribute.Int("song.id", id))
+	err := t.next.Delete(ctx, id)
+	tracing.End(span, err)
+	return err
+}
+
+// tracedArtistsRepository wraps every call to the underlying repository in a span
+type tracedArtistsRepository struct {
+	next store.ArtistsRepository
+}
+
+func (t tracedArtistsRepository) Create(ctx context.Context, artist *models.Artist) error {
+	ctx, span := startSpan(ctx, "artists", "Create", attribute.Int("artist.id", artist.ID))
+	err := t.next.Create(ctx, artist)
+	tracing.End(span, err)
+	return err
+}
+
+func (t tracedArtistsRepository) All(ctx context.Context, page *models.Page) ([]*models.Artist, error) {
+	ctx, span := startSpan(ctx, "artists", "All", attribute.Int("page.limit", page.Limit), attribute.Int("page.offset", page.Offset))
+	artists, err := t.next.All(ctx, page)
+	span.SetAttributes(attribute.Int("artists.count", len(artists)))
+	tracing.End(span, err)
+	return artists, err
+}
+
+func (t tracedArtistsRepository) ByID(ctx context.Context, id int) (*models.Artist, error) {
+	ctx, span := startSpan(ctx, "artists", "ByID", attribute.Int("artist.id", id))
+	artist, err := t.next.ByID(ctx, id)
+	tracing.End(span, err)
+	return artist, err
+}
+
+func (t tracedArtistsRepository) ByIDs(ctx context.Context, ids []int) ([]*models.Artist, error) {
+	ctx, span := startSpan(ctx, "artists", "ByIDs", attribute.IntSlice("artist.ids", ids))
+	artists, err := t.next.ByIDs(ctx, ids)
+	tracing.End(span, err)
+	return artists, err
+}
+
+func (t tracedArtistsRepository) Update(ctx context.Context, artist *models.Artist) error {
+	ctx, span := startSpan(ctx, "artists", "Update", attribute.Int("artist.id", artist.ID))
+	err := t.next.Update(ctx, artist)
+	tracing.End(span, err)
+	return err
+}
+
+func (t tracedArtistsRepository) Delete(ctx context.Context, id int) error {
+	ctx, span := startSpan(ctx, "artists", "Delete", attribute.Int("artist.id", id))
+	err := t.next.Delete(ctx, id)
+	tracing.End(span, err)
+	return err
+}
+
+type tracedWebhooksRepository struct {
+	next store.WebhooksRepository
+}
+
+func (t tracedWebhooksRepository) Create(ctx context.Context, webhook *models.Webhook) error {
+	ctx, span := startSpan(ctx, "webhooks", "Create", attribute.Int("webhook.id", webhook.ID))
+	err := t.next.Create(ctx, webhook)
+	tracing.End(span, err)
+	return err
+}
+
+func (t tracedWebhooksRepository) All(ctx context.Context) ([]*models.Webhook, error) {
+	ctx, span := startSpan(ctx, "webhooks", "All")
+	webhooks, err := t.next.All(ctx)
+	span.SetAttributes(attribute.Int("webhooks.count", len(webhooks)))
+	tracing.End(span, err)
+	return webhooks, err
+}
+
+func (t tracedWebhooksRepository) ByID(ctx context.Context, id int) (*models.Webhook, error) {
+	ctx, span := startSpan(ctx, "webhooks", "ByID", attribute.Int("webhook.id", id))
+	webhook, err := t.next.ByID(ctx, id)
+	tracing.End(span, err)
+	return webhook, err
+}
+
+func (t tracedWebhooksRepository) ByEventType(ctx context.Context, eventType models.EventType) ([]*models.Webhook, error) {
+	ctx, span := startSpan(ctx, "webhooks", "ByEventType", attribute.String("event.type", string(eventType)))
+	webhooks, err := t.next.ByEventType(ctx, eventType)
+	span.SetAttributes(attribute.Int("webhooks.count", len(webhooks)))
+	tracing.End(span, err)
+	return webhooks, err
+}
+
+func (t tracedWebhooksRepository) Update(ctx context.Context, webhook *models.Webhook) error {
+	ctx, span := startSpan(ctx, "webhooks", "Update", attribute.Int("webhook.id", webhook.ID))
+	err := t.next.Update(ctx, webhook)
+	tracing.End(span, err)
+	return err
+}
+
+func (t tracedWebhooksRepository) Delete(ctx context.Context, id int) error {
+	ctx, span := startSpan(ctx, "webhooks", "Delete", attribute.Int("webhook.id", id))
+	err := t.next.Delete(ctx, id)
+	tracing.End(span, err)
+	return err
+}
+
+func (t tracedWebhooksRepository) LogDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	ctx, span := startSpan(ctx, "webhook_deliveries", "LogDelivery", attribute.Int("webhook.id", delivery.WebhookID))
+	err := t.next.LogDelivery(ctx, delivery)
+	tracing.End(span, err)
+	return err
+}
+
+func (t tracedWebhooksRepository) Deliveries(ctx context.Context, webhookID int) ([]*models.WebhookDelivery, error) {
+	ctx, span := startSpan(ctx, "webhook_deliveries", "Deliveries", attribute.Int("webhook.id", webhookID))
+	deliveries, err := t.next.Deliveries(ctx, webhookID)
+	tracing.End(span, err)
+	return deliveries, err
+}
+
+func (t tracedWebhooksRepository) DeadLetters(ctx context.Context) ([]*models.WebhookDelivery, error) {
+	ctx, span := startSpan(ctx, "webhook_deliveries", "DeadLetters")
+	deliveries, err := t.next.DeadLetters(ctx)
+	tracing.End(span, err)
+	return deliveries, err
+}
+
+type tracedIdempotencyRepository struct {
+	next store.IdempotencyRepository
+}
+
+func (t tracedIdempotencyRepository) Reserve(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	ctx, span := startSpan(ctx, "idempotency_keys", "Reserve")
+	existing, err := t.next.Reserve(ctx, record)
+	span.SetAttributes(attribute.Bool("idempotency.duplicate", existing != nil))
+	tracing.End(span, err)
+	return existing, err
+}
+
+func (t tracedIdempotencyRepository) Complete(ctx context.Context, record *models.IdempotencyRecord) error {
+	ctx, span := startSpan(ctx, "idempotency_keys", "Complete")
+	err := t.next.Complete(ctx, record)
+	tracing.End(span, err)
+	return err
+}
+
+func (t tracedIdempotencyRepository) Release(ctx context.Context, key string) error {
+	ctx, span := startSpan(ctx, "idempotency_keys", "Release")
+	err := t.next.Release(ctx, key)
+	tracing.End(span, err)
+	return err
+}
@@ -0,0 +1,180 @@
+package mongodb
+
+import (
+	"context"
+	"example/hello/project/internal/logger"
+	"example/hello/project/internal/models"
+	"example/hello/project/internal/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// number of the most recent deliveries returned from the log
+const deliveriesPageSize = 50
+
+func (db *DB) Webhooks() store.WebhooksRepository {
+	if db.webhooks == nil {
+		webhooks, err := NewWebhooksRepository(db.client, db.logger)
+
+		if err != nil {
+			db.logger.Fatal("got an error while creating a collection with constraints", zap.String("collection", "webhooks"), zap.Error(err))
+			return nil
+		}
+		db.webhooks = tracedWebhooksRepository{next: webhooks}
+	}
+
+	return db.webhooks
+}
+
+type WebhooksRepository struct {
+	client *mongo.Client
+	logger *zap.Logger
+
+	collection           *mongo.Collection
+	deliveriesCollection *mongo.Collection
+}
+
+func NewWebhooksRepository(client *mongo.Client, logger *zap.Logger) (store.WebhooksRepository, error) {
+	webhooksCollection := client.Database("lostify").Collection("webhooks")
+	// creating an index so that `ID` field is unique
+	_, err := webhooksCollection.Indexes().CreateOne(
+		context.Background(),
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveriesCollection := client.Database("lostify").Collection("webhook_deliveries")
+	// the log is always read by webhook (or status) starting from the most recent deliveries
+	_, err = deliveriesCollection.Indexes().CreateMany(
+		context.Background(),
+		[]mongo.IndexModel{
+			{Keys: bson.D{{Key: "webhookid", Value: 1}, {Key: "time", Value: -1}}},
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "time", Value: -1}}},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WebhooksRepository{
+		client:               client,
+		logger:               logger,
+		collection:           webhooksCollection,
+		deliveriesCollection: deliveriesCollection,
+	}, nil
+}
+
+func (c WebhooksRepository) Create(ctx context.Context, webhook *models.Webhook) error {
+	insertResult, err := c.collection.InsertOne(ctx, webhook)
+	if err != nil {
+		return err
+	}
+
+	logger.FromContext(ctx, c.logger).Debug("inserted a webhook", zap.Any("inserted_id", insertResult.InsertedID))
+
+	return nil
+}
+
+func (c WebhooksRepository) All(ctx context.Context) ([]*models.Webhook, error) {
+	return c.find(ctx, bson.D{})
+}
+
+func (c WebhooksRepository) ByID(ctx context.Context, id int) (*models.Webhook, error) {
+	var webhook models.Webhook
+
+	filter := bson.M{"id": id}
+
+	if err := c.collection.FindOne(ctx, filter).Decode(&webhook); err != nil {
+		return nil, notFound(err)
+	}
+
+	return &webhook, nil
+}
+
+func (c WebhooksRepository) ByEventType(ctx context.Context, eventType models.EventType) ([]*models.Webhook, error) {
+	// matches the documents whose `eventtypes` array contains the given type
+	return c.find(ctx, bson.D{{Key: "eventtypes", Value: eventType}})
+}
+
+func (c WebhooksRepository) find(ctx context.Context, filter bson.D) ([]*models.Webhook, error) {
+	cur, err := c.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// decoding all the documents at once, closes the cursor as well
+	var webhooks []*models.Webhook
+	if err := cur.All(ctx, &webhooks); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (c WebhooksRepository) Update(ctx context.Context, webhook *models.Webhook) error {
+	filter := bson.M{"id": webhook.ID}
+
+	updateResult, err := c.collection.ReplaceOne(ctx, filter, webhook)
+	if err != nil {
+		return err
+	}
+
+	if updateResult.MatchedCount == 0 {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+func (c WebhooksRepository) Delete(ctx context.Context, id int) error {
+	filter := bson.M{"id": id}
+
+	deleteResult, err := c.collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	if deleteResult.DeletedCount == 0 {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+func (c WebhooksRepository) LogDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	_, err := c.deliveriesCollection.InsertOne(ctx, delivery)
+	return err
+}
+
+func (c WebhooksRepository) Deliveries(ctx context.Context, webhookID int) ([]*models.WebhookDelivery, error) {
+	return c.findDeliveries(ctx, bson.D{{Key: "webhookid", Value: webhookID}})
+}
+
+func (c WebhooksRepository) DeadLetters(ctx context.Context) ([]*models.WebhookDelivery, error) {
+	return c.findDeliveries(ctx, bson.D{{Key: "status", Value: models.DeliveryStatusDead}})
+}
+
+func (c WebhooksRepository) findDeliveries(ctx context.Context, filter bson.D) ([]*models.WebhookDelivery, error) {
+	findOptions := options.Find()
+	findOptions.SetSort(bson.D{{Key: "time", Value: -1}})
+	findOptions.SetLimit(deliveriesPageSize)
+
+	cur, err := c.deliveriesCollection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var deliveries []*models.WebhookDelivery
+	if err := cur.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
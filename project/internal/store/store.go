@@ -2,15 +2,24 @@ package store
 
 import (
 	"context"
+	"errors"
 	"example/hello/project/internal/models"
 )
 
+// ErrNotFound is returned by the repositories when there's nothing with the given id
+var ErrNotFound = errors.New("not found")
+
 type Store interface {
 	Connect(uri string) error
 	Close() error
+	Ping(ctx context.Context) error
+	// Transaction runs fn all-or-nothing, the repositories take part in it when called with the ctx passed to fn
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
 
 	Songs() SongsRepository
 	Artists() ArtistsRepository
+	Webhooks() WebhooksRepository
+	Idempotency() IdempotencyRepository
 }
 
 type SongsRepository interface {
@@ -18,14 +27,45 @@ type SongsRepository interface {
 	Create(ctx context.Context, song *models.Song) error
 	All(ctx context.Context, filter *models.Filter) ([]*models.Song, error)
 	ByID(ctx context.Context, id int) (*models.Song, error)
+	// ByArtistID returns a page of the songs of the artist ordered by ID
+	ByArtistID(ctx context.Context, artistID int, page *models.Page) ([]*models.Song, error)
 	Update(ctx context.Context, song *models.Song) error
 	Delete(ctx context.Context, id int) error
 }
 
 type ArtistsRepository interface {
 	Create(ctx context.Context, song *models.Artist) error
-	All(ctx context.Context) ([]*models.Artist, error)
+	All(ctx context.Context, page *models.Page) ([]*models.Artist, error)
 	ByID(ctx context.Context, id int) (*models.Artist, error)
+	// ByIDs returns all found artists with given ids (in no particular order)
+	ByIDs(ctx context.Context, ids []int) ([]*models.Artist, error)
 	Update(ctx context.Context, artist *models.Artist) error
 	Delete(ctx context.Context, id int) error
 }
+
+type WebhooksRepository interface {
+	Create(ctx context.Context, webhook *models.Webhook) error
+	All(ctx context.Context) ([]*models.Webhook, error)
+	ByID(ctx context.Context, id int) (*models.Webhook, error)
+	// ByEventType returns webhooks subscribed to the given type of events
+	ByEventType(ctx context.Context, eventType models.EventType) ([]*models.Webhook, error)
+	Update(ctx context.Context, webhook *models.Webhook) error
+	Delete(ctx context.Context, id int) error
+
+	// LogDelivery records an attempt of delivery in the log
+	LogDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	// Deliveries returns the most recent attempts of delivery to the webhook
+	Deliveries(ctx context.Context, webhookID int) ([]*models.WebhookDelivery, error)
+	// DeadLetters returns the most recent deliveries that have failed on every attempt
+	DeadLetters(ctx context.Context) ([]*models.WebhookDelivery, error)
+}
+
+type IdempotencyRepository interface {
+	// Reserve saves the record unless there's an unexpired one with the same key, which is returned instead.
+	// The reservations of the requests still being processed are taken over once their LockedUntil has passed
+	Reserve(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, error)
+	// Complete saves the response to the request the key has been reserved for
+	Complete(ctx context.Context, record *models.IdempotencyRecord) error
+	// Release deletes the reservation, so that the request could be retried
+	Release(ctx context.Context, key string) error
+}
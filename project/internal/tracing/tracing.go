@@ -0,0 +1,82 @@
+// OpenTelemetry for Go: https://opentelemetry.io/docs/instrumentation/go/getting-started/
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone   = ""
+	ExporterStdout = "stdout"
+	// ExporterOTLP sends spans over HTTP to the collector configured by the standard
+	// OTEL_EXPORTER_OTLP_ENDPOINT env variable (localhost:4318 by default)
+	ExporterOTLP = "otlp"
+)
+
+// Setup installs a global tracer provider with the given exporter and W3C trace context propagator.
+// The returned function flushes the remaining spans and must be called before exiting.
+func Setup(ctx context.Context, exporter, serviceName string) (func(ctx context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var spanExporter sdktrace.SpanExporter
+	switch exporter {
+	case ExporterNone:
+		// keeping the default no-op provider, but still propagating incoming trace context
+		return func(ctx context.Context) error { return nil }, nil
+	case ExporterStdout:
+		stdoutExporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, err
+		}
+		spanExporter = stdoutExporter
+	case ExporterOTLP:
+		otlpExporter, err := otlptracehttp.New(ctx, otlptracehttp.WithInsecure())
+		if err != nil {
+			return nil, err
+		}
+		spanExporter = otlpExporter
+	default:
+		return nil, fmt.Errorf("tracing exporter %v doesn't exist", exporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(serviceName),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Tracer returns a named tracer of the global provider
+func Tracer(name string) trace.Tracer {
+	return otel.Tracer("example/hello/project/internal/" + name)
+}
+
+// End records err (if any) on the span and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,102 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name     string
+		exporter string
+		wantErr  bool
+	}{
+		{"none", ExporterNone, false},
+		{"stdout", ExporterStdout, false},
+		// the exporter is created without connecting to the collector
+		{"otlp", ExporterOTLP, false},
+		{"unknown", "jaeger", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shutdown, err := Setup(context.Background(), tt.exporter, "lostify-test")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error: %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if err := shutdown(context.Background()); err != nil {
+				t.Errorf("shutdown: %v", err)
+			}
+		})
+	}
+}
+
+func TestStdoutExporter(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	shutdown, err := Setup(context.Background(), ExporterStdout, "lostify-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := Tracer("tracing").Start(context.Background(), "exported span")
+	span.End()
+	// flushes the batch of spans
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Close()
+
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"Name":"exported span"`, "lostify-test"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("exported span doesn't contain %v: %s", want, out)
+		}
+	}
+}
+
+func TestEnd(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	defer func() { _ = provider.Shutdown(context.Background()) }()
+
+	_, ok := Tracer("tracing").Start(context.Background(), "ok")
+	End(ok, nil)
+	_, failed := Tracer("tracing").Start(context.Background(), "failed")
+	End(failed, errors.New("not found"))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %v ended spans, want 2", len(spans))
+	}
+	if status := spans[0].Status(); status.Code != codes.Unset {
+		t.Errorf("span without error got status %v", status.Code)
+	}
+	if status := spans[1].Status(); status.Code != codes.Error || status.Description != "not found" {
+		t.Errorf("span with error got status %v %q", status.Code, status.Description)
+	}
+	if events := spans[1].Events(); len(events) != 1 || events[0].Name != "exception" {
+		t.Errorf("error isn't recorded on the span: %v", events)
+	}
+}
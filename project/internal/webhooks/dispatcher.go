@@ -0,0 +1,283 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"example/hello/project/internal/models"
+	"example/hello/project/internal/store"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// the deliveries are logged even if their context is done, e.g. the dead letters left on shutdown
+const logDeliveryTimeout = time.Second * 5
+
+// headers sent along with every delivery, so that the receivers could verify and deduplicate them
+const (
+	HeaderEvent     = "X-Lostify-Event"
+	HeaderDelivery  = "X-Lostify-Delivery"
+	HeaderTimestamp = "X-Lostify-Timestamp"
+	HeaderSignature = "X-Lostify-Signature"
+)
+
+// Sign returns the value of the signature header: HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook's secret,
+// the timestamp is a part of the signed payload so that the captured deliveries can't be replayed later
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify is the receiver's side of Sign
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Dispatcher delivers the catalog events to the subscribed webhooks
+type Dispatcher struct {
+	store store.Store
+	// every peer receives every event, but only the run of the peer the event has originated from delivers it
+	origin string
+	client *http.Client
+	logger *zap.Logger
+
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+type DispatcherOption func(d *Dispatcher)
+
+// WithHTTPClient replaces the client which refuses to connect to anything but the public addresses,
+// the given one is used as is
+func WithHTTPClient(client *http.Client) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+// WithRetries sets how many times the delivery is attempted before it ends up in the dead letters
+// and the bounds of the exponential backoff between the attempts
+func WithRetries(maxAttempts int, initialBackoff, maxBackoff time.Duration) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.maxAttempts = maxAttempts
+		d.initialBackoff = initialBackoff
+		d.maxBackoff = maxBackoff
+	}
+}
+
+func WithLogger(logger *zap.Logger) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.logger = logger
+	}
+}
+
+func NewDispatcher(store store.Store, origin string, opts ...DispatcherOption) *Dispatcher {
+	d := &Dispatcher{
+		store:          store,
+		origin:         origin,
+		client:         newClient(time.Second * 10),
+		logger:         zap.NewNop(),
+		maxAttempts:    5,
+		initialBackoff: time.Second,
+		maxBackoff:     time.Minute,
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// Run delivers the events until the context is done or the channel is closed, then waits for the attempts in flight.
+// The deliveries aren't retried after that, they're left in the dead letters instead, as the backoff
+// of the retries takes far longer than the peer is given to shut down; the context cancels the attempts too
+func (d *Dispatcher) Run(ctx context.Context, events <-chan *models.Event) {
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	defer func() {
+		close(stop)
+		wg.Wait()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Origin != d.origin {
+				continue
+			}
+
+			webhooks, err := d.store.Webhooks().ByEventType(ctx, event.Type)
+			if err != nil {
+				d.logger.Error("failed to look up webhooks", zap.String("event_type", string(event.Type)), zap.Error(err))
+				continue
+			}
+
+			for _, webhook := range webhooks {
+				wg.Add(1)
+				go func(webhook *models.Webhook) {
+					defer wg.Done()
+					d.deliver(ctx, stop, webhook, event)
+				}(webhook)
+			}
+		}
+	}
+}
+
+// Deliver posts the event to the webhook, retrying with exponential backoff, every attempt is logged in the store.
+// Once the context is done, the delivery is left in the dead letters
+func (d *Dispatcher) Deliver(ctx context.Context, webhook *models.Webhook, event *models.Event) {
+	d.deliver(ctx, ctx.Done(), webhook, event)
+}
+
+// deliver gives up on the retries once stop is closed
+func (d *Dispatcher) deliver(ctx context.Context, stop <-chan struct{}, webhook *models.Webhook, event *models.Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		d.logger.Error("failed to marshal event", zap.Error(err))
+		return
+	}
+
+	deliveryID, err := newDeliveryID()
+	if err != nil {
+		d.logger.Error("failed to generate delivery id", zap.Error(err))
+		return
+	}
+
+	logger := d.logger.With(zap.Int("webhook_id", webhook.ID), zap.String("delivery_id", deliveryID), zap.String("event_type", string(event.Type)))
+
+	backoff := d.initialBackoff
+	for attempt := 1; attempt <= d.maxAttempts; attempt++ {
+		responseStatus, err := d.send(ctx, webhook, event.Type, deliveryID, body)
+
+		delivery := &models.WebhookDelivery{
+			ID:             deliveryID,
+			WebhookID:      webhook.ID,
+			Event:          event,
+			Attempt:        attempt,
+			Status:         models.DeliveryStatusDelivered,
+			ResponseStatus: responseStatus,
+			Time:           time.Now().UTC(),
+		}
+		if err != nil {
+			delivery.Status = models.DeliveryStatusFailed
+			delivery.Error = err.Error()
+			if attempt == d.maxAttempts || stopped(stop) {
+				delivery.Status = models.DeliveryStatusDead
+			}
+		}
+		d.logDelivery(logger, delivery)
+
+		if err == nil {
+			logger.Debug("delivered webhook", zap.Int("attempt", attempt))
+			return
+		}
+		if delivery.Status == models.DeliveryStatusDead {
+			logger.Warn("webhook delivery is dead", zap.Int("attempts", attempt), zap.Error(err))
+			return
+		}
+
+		logger.Info("webhook delivery failed, retrying", zap.Int("attempt", attempt), zap.Duration("backoff", backoff), zap.Error(err))
+		select {
+		case <-stop:
+			d.logDelivery(logger, &models.WebhookDelivery{
+				ID:        deliveryID,
+				WebhookID: webhook.ID,
+				Event:     event,
+				Attempt:   attempt + 1,
+				Status:    models.DeliveryStatusDead,
+				Error:     "not attempted since the peer is shutting down",
+				Time:      time.Now().UTC(),
+			})
+			logger.Warn("webhook delivery is given up on shutdown", zap.Int("attempts", attempt))
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > d.maxBackoff {
+			backoff = d.maxBackoff
+		}
+	}
+}
+
+func (d *Dispatcher) logDelivery(logger *zap.Logger, delivery *models.WebhookDelivery) {
+	ctx, cancel := context.WithTimeout(context.Background(), logDeliveryTimeout)
+	defer cancel()
+
+	if err := d.store.Webhooks().LogDelivery(ctx, delivery); err != nil {
+		logger.Error("failed to log delivery", zap.Error(err))
+	}
+}
+
+func stopped(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// send makes a single attempt, anything but 2xx response is considered as a failure
+func (d *Dispatcher) send(ctx context.Context, webhook *models.Webhook, eventType models.EventType, deliveryID string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	// the webhooks created before the URLs were validated might still be plain http
+	if req.URL.Scheme != "https" {
+		return 0, fmt.Errorf("webhook URL %v isn't https", webhook.URL)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(eventType))
+	req.Header.Set(HeaderDelivery, deliveryID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// draining the body so that the connection could be reused
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %v", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// the same delivery ID is sent on every retry, so that the receivers could deduplicate them
+func newDeliveryID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,314 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"example/hello/project/internal/message_broker"
+	"example/hello/project/internal/message_broker/kafka"
+	"example/hello/project/internal/models"
+	"example/hello/project/internal/store"
+	"example/hello/project/internal/store/inmemory"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receiver is the partner service, it responds with 503 to as many first requests as failures
+type receiver struct {
+	t        *testing.T
+	secret   string
+	failures int
+
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func (rc *receiver) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		rc.t.Error(err)
+	}
+	if !Verify(rc.secret, r.Header.Get(HeaderTimestamp), body, r.Header.Get(HeaderSignature)) {
+		rc.t.Errorf("signature %q doesn't match the body %s", r.Header.Get(HeaderSignature), body)
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests = append(rc.requests, r)
+	if len(rc.requests) <= rc.failures {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+func (rc *receiver) received() []*http.Request {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]*http.Request(nil), rc.requests...)
+}
+
+func newTestWebhook(t *testing.T, db store.Store, url string) *models.Webhook {
+	t.Helper()
+
+	webhook := &models.Webhook{ID: 1, URL: url, Secret: "secret", EventTypes: []models.EventType{models.EventSongCreated}}
+	if err := db.Webhooks().Create(context.Background(), webhook); err != nil {
+		t.Fatal(err)
+	}
+	return webhook
+}
+
+func TestDeliver(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		wantStatuses []models.DeliveryStatus
+	}{
+		{"delivered", 0, []models.DeliveryStatus{models.DeliveryStatusDelivered}},
+		{"delivered on retry", 2, []models.DeliveryStatus{models.DeliveryStatusFailed, models.DeliveryStatusFailed, models.DeliveryStatusDelivered}},
+		{"dead", 3, []models.DeliveryStatus{models.DeliveryStatusFailed, models.DeliveryStatusFailed, models.DeliveryStatusDead}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := &receiver{t: t, secret: "secret", failures: tt.failures}
+			srv := httptest.NewTLSServer(rc)
+			defer srv.Close()
+
+			db := inmemory.NewDB()
+			webhook := newTestWebhook(t, db, srv.URL+"/hook")
+			d := NewDispatcher(db, "peer0", WithHTTPClient(srv.Client()), WithRetries(3, time.Millisecond, time.Millisecond))
+
+			event := &models.Event{Type: models.EventSongCreated, Origin: "peer0", Song: &models.Song{ID: 1}}
+			d.Deliver(context.Background(), webhook, event)
+
+			requests := rc.received()
+			if len(requests) != len(tt.wantStatuses) {
+				t.Fatalf("got %v requests, want %v", len(requests), len(tt.wantStatuses))
+			}
+			// the retries are the same delivery
+			for _, r := range requests {
+				if r.Header.Get(HeaderDelivery) != requests[0].Header.Get(HeaderDelivery) || r.Header.Get(HeaderDelivery) == "" {
+					t.Errorf("got delivery ID %q, want %q", r.Header.Get(HeaderDelivery), requests[0].Header.Get(HeaderDelivery))
+				}
+				if r.Header.Get(HeaderEvent) != string(models.EventSongCreated) || r.URL.Path != "/hook" {
+					t.Errorf("got %v event at %v", r.Header.Get(HeaderEvent), r.URL.Path)
+				}
+			}
+
+			deliveries, err := db.Webhooks().Deliveries(context.Background(), webhook.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(deliveries) != len(tt.wantStatuses) {
+				t.Fatalf("got %v deliveries logged, want %v", len(deliveries), len(tt.wantStatuses))
+			}
+			statuses := make(map[int]models.DeliveryStatus)
+			for _, delivery := range deliveries {
+				statuses[delivery.Attempt] = delivery.Status
+			}
+			for i, want := range tt.wantStatuses {
+				if statuses[i+1] != want {
+					t.Errorf("attempt %v: got status %q, want %q", i+1, statuses[i+1], want)
+				}
+			}
+		})
+	}
+}
+
+// the backoff of the retries is far longer than the peer is given to shut down
+func TestDeliverGivesUpOnShutdown(t *testing.T) {
+	rc := &receiver{t: t, secret: "secret", failures: 100}
+	srv := httptest.NewTLSServer(rc)
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		deliver func(d *Dispatcher, webhook *models.Webhook, event *models.Event)
+	}{
+		{"context done", func(d *Dispatcher, webhook *models.Webhook, event *models.Event) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() {
+				// the first attempt has been made, the delivery is waiting for the retry
+				for len(rc.received()) == 0 {
+					time.Sleep(time.Millisecond)
+				}
+				cancel()
+			}()
+			d.Deliver(ctx, webhook, event)
+		}},
+		{"dispatcher stopped", func(d *Dispatcher, webhook *models.Webhook, event *models.Event) {
+			events := make(chan *models.Event, 1)
+			events <- event
+			close(events)
+			d.Run(context.Background(), events)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc.mu.Lock()
+			rc.requests = nil
+			rc.mu.Unlock()
+
+			db := inmemory.NewDB()
+			webhook := newTestWebhook(t, db, srv.URL)
+			d := NewDispatcher(db, "peer0", WithHTTPClient(srv.Client()), WithRetries(5, time.Hour, time.Hour))
+			event := &models.Event{Type: models.EventSongCreated, Origin: "peer0", Song: &models.Song{ID: 1}}
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				tt.deliver(d, webhook, event)
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("the delivery is still being retried")
+			}
+
+			if requests := rc.received(); len(requests) != 1 {
+				t.Errorf("got %v requests, want 1", len(requests))
+			}
+			deadLetters, err := db.Webhooks().DeadLetters(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(deadLetters) != 1 || deadLetters[0].WebhookID != webhook.ID {
+				t.Errorf("got dead letters %+v, want the delivery of webhook %v", deadLetters, webhook.ID)
+			}
+		})
+	}
+}
+
+// every peer receives every event, only the one it has originated from delivers it
+func TestRunDeliversOwnEvents(t *testing.T) {
+	rc := &receiver{t: t, secret: "secret"}
+	srv := httptest.NewTLSServer(rc)
+	defer srv.Close()
+
+	db := inmemory.NewDB()
+	newTestWebhook(t, db, srv.URL)
+	d := NewDispatcher(db, "peer0", WithHTTPClient(srv.Client()))
+
+	events := make(chan *models.Event, 3)
+	events <- &models.Event{Type: models.EventSongCreated, Origin: "peer1"}
+	events <- &models.Event{Type: models.EventSongCreated, Origin: "peer0"}
+	// nobody is subscribed to this one
+	events <- &models.Event{Type: models.EventArtistCreated, Origin: "peer0"}
+	close(events)
+	d.Run(context.Background(), events)
+
+	if requests := rc.received(); len(requests) != 1 {
+		t.Errorf("got %v deliveries, want 1", len(requests))
+	}
+}
+
+// the peers are often started with the same client ID, e.g. the replicas of a single deployment
+func TestRunDeliversOnceWithSharedClientID(t *testing.T) {
+	rc := &receiver{t: t, secret: "secret"}
+	srv := httptest.NewTLSServer(rc)
+	defer srv.Close()
+
+	db := inmemory.NewDB()
+	newTestWebhook(t, db, srv.URL)
+
+	peers := []message_broker.EventsBroker{
+		kafka.NewEventsBroker("peer0", nil, zap.NewNop()),
+		kafka.NewEventsBroker("peer0", nil, zap.NewNop()),
+	}
+	// stamped by the first peer on publishing, every peer receives it
+	event := &models.Event{Type: models.EventSongCreated, Origin: peers[0].Origin(), Song: &models.Song{ID: 1}}
+	for _, peer := range peers {
+		events := make(chan *models.Event, 1)
+		events <- event
+		close(events)
+		NewDispatcher(db, peer.Origin(), WithHTTPClient(srv.Client())).Run(context.Background(), events)
+	}
+
+	if requests := rc.received(); len(requests) != 1 {
+		t.Errorf("got %v deliveries, want 1", len(requests))
+	}
+}
+
+func TestDeliverRefusesPrivateAddresses(t *testing.T) {
+	rc := &receiver{t: t, secret: "secret"}
+	srv := httptest.NewTLSServer(rc)
+	defer srv.Close()
+	plain := httptest.NewServer(rc)
+	defer plain.Close()
+
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		// the test servers listen on the loopback
+		{"loopback", srv.URL, ErrForbiddenAddress.Error()},
+		{"loopback by name", strings.Replace(srv.URL, "127.0.0.1", "localhost", 1), ErrForbiddenAddress.Error()},
+		{"plain http", plain.URL, "isn't https"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := inmemory.NewDB()
+			webhook := newTestWebhook(t, db, tt.url)
+			d := NewDispatcher(db, "peer0", WithRetries(1, time.Millisecond, time.Millisecond))
+
+			d.Deliver(context.Background(), webhook, &models.Event{Type: models.EventSongCreated, Origin: "peer0"})
+
+			deadLetters, err := db.Webhooks().DeadLetters(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(deadLetters) != 1 || !strings.Contains(deadLetters[0].Error, tt.want) {
+				t.Errorf("got dead letters %+v, want the error %q", deadLetters, tt.want)
+			}
+		})
+	}
+
+	if requests := rc.received(); len(requests) != 0 {
+		t.Errorf("got %v requests to the private addresses", len(requests))
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr error
+	}{
+		{"https://partner.example/hooks?id=1", nil},
+		{"https://93.184.216.34:8443/hooks", nil},
+		{"https://[2606:2800:220:1:248:1893:25c8:1946]/hooks", nil},
+		{"http://partner.example/hooks", errors.New("must be an https URL")},
+		{"ftp://partner.example/hooks", errors.New("must be an https URL")},
+		{"https:///hooks", errors.New("must have a host")},
+		{"https://localhost:8080/admin", ErrForbiddenAddress},
+		{"https://LOCALHOST./admin", ErrForbiddenAddress},
+		{"https://127.0.0.1/admin", ErrForbiddenAddress},
+		{"https://[::1]/admin", ErrForbiddenAddress},
+		{"https://169.254.169.254/latest/meta-data", ErrForbiddenAddress},
+		{"https://10.0.0.8/", ErrForbiddenAddress},
+		{"https://172.16.0.1/", ErrForbiddenAddress},
+		{"https://192.168.1.1/", ErrForbiddenAddress},
+		{"https://100.64.0.1/", ErrForbiddenAddress},
+		{"https://0.0.0.0/", ErrForbiddenAddress},
+		{"https://[fe80::1]/", ErrForbiddenAddress},
+		{"https://[fd00::1]/", ErrForbiddenAddress},
+		{"https://[::ffff:127.0.0.1]/", ErrForbiddenAddress},
+	}
+
+	for _, tt := range tests {
+		err := ValidateURL(tt.url)
+		switch {
+		case tt.wantErr == nil && err != nil:
+			t.Errorf("%v: unexpected error %v", tt.url, err)
+		case tt.wantErr != nil && (err == nil || err.Error() != tt.wantErr.Error()):
+			t.Errorf("%v: got error %v, want %v", tt.url, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned for the webhooks pointing into the network of the peer,
+// e.g. to the admin routes or the metadata service of the cloud (SSRF)
+var ErrForbiddenAddress = errors.New("webhook address is not public")
+
+// ValidateURL accepts only https URLs of the public hosts. The host names are resolved
+// at dial time rather than here, as the records can change after the webhook is created
+func ValidateURL(value interface{}) error {
+	raw, _ := value.(string)
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "https" {
+		return errors.New("must be an https URL")
+	}
+	host := u.Hostname()
+	if host == "" {
+		return errors.New("must have a host")
+	}
+	if host = strings.TrimSuffix(strings.ToLower(host), "."); host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrForbiddenAddress
+	}
+	if ip := net.ParseIP(host); ip != nil && !publicIP(ip) {
+		return ErrForbiddenAddress
+	}
+
+	return nil
+}
+
+// publicIP tells whether the address is reachable from the internet rather than being
+// a loopback, link-local, private or otherwise special one
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsPrivate() ||
+		ip.IsUnspecified() || ip.IsMulticast() || ip.IsInterfaceLocalMulticast() || cgnat.Contains(ip))
+}
+
+// shared address space of the carrier-grade NAT, it isn't covered by IsPrivate
+var cgnat = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// refusePrivate checks the address the dialer is about to connect to, i.e. after the name has been resolved,
+// so that the host names resolving (or rebinding) to the private addresses are refused as well
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+		return fmt.Errorf("%w: %v", ErrForbiddenAddress, host)
+	}
+	return nil
+}
+
+// newClient makes the client which delivers to the public https addresses only, the redirects included.
+// The proxies from the environment aren't used, as they would dial the addresses instead of the client
+func newClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   refusePrivate,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return ValidateURL(req.URL.String())
+		},
+	}
+}
@@ -0,0 +1,66 @@
+package lostifyclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+func (c *Client) CreateArtist(ctx context.Context, artist *Artist) error {
+	return c.do(ctx, http.MethodPost, "/v1/artists", nil, artist, nil)
+}
+
+func (c *Client) Artist(ctx context.Context, id int) (*Artist, error) {
+	artist := new(Artist)
+	if err := c.do(ctx, http.MethodGet, "/v1/artists/"+strconv.Itoa(id), nil, nil, artist); err != nil {
+		return nil, err
+	}
+
+	return artist, nil
+}
+
+// Artists returns an iterator over all the artists, the pages are fetched lazily
+func (c *Client) Artists(ctx context.Context) *ArtistIterator {
+	return &ArtistIterator{
+		pager:  newPager(ctx, nil, c.pageSize),
+		client: c,
+		i:      -1,
+	}
+}
+
+func (c *Client) UpdateArtist(ctx context.Context, artist *Artist) error {
+	return c.do(ctx, http.MethodPut, "/v1/artists", nil, artist, nil)
+}
+
+func (c *Client) DeleteArtist(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, "/v1/artists/"+strconv.Itoa(id), nil, nil, nil)
+}
+
+type ArtistIterator struct {
+	pager
+	client *Client
+
+	artists []*Artist
+	i       int
+}
+
+// Next advances to the next artist, fetching the next page if needed.
+// It returns false once all the artists have been iterated over or an error has occurred (see Err).
+func (it *ArtistIterator) Next() bool {
+	if it.i+1 < len(it.artists) {
+		it.i++
+		return true
+	}
+
+	it.artists, it.i = nil, 0
+	return it.fetch(func(ctx context.Context, query url.Values) (int, error) {
+		err := it.client.do(ctx, http.MethodGet, "/v1/artists", query, nil, &it.artists)
+		return len(it.artists), err
+	})
+}
+
+// Artist returns the current artist
+func (it *ArtistIterator) Artist() *Artist {
+	return it.artists[it.i]
+}
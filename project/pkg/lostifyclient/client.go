@@ -0,0 +1,248 @@
+// Package lostifyclient is a typed client of Lostify HTTP API
+//
+//	client, err := lostifyclient.New("http://localhost:8080", lostifyclient.WithToken(token))
+//	it := client.Songs(ctx, &lostifyclient.SongsQuery{Search: "love"})
+//	for it.Next() {
+//		fmt.Println(it.Song().Title)
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+package lostifyclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageSize = 10
+	// the server doesn't return more than that in a single page
+	maxPageSize = 100
+)
+
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	header     http.Header
+	pageSize   int
+
+	maxRetries     int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+type Option func(c *Client)
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithToken sends the token in "Authorization: Bearer <token>" header
+func WithToken(token string) Option {
+	return WithHeader("Authorization", "Bearer "+token)
+}
+
+// WithHeader sends the header along with every request, e.g. an API key
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.header.Set(key, value)
+	}
+}
+
+// WithRetries sets how many times the request is retried on 5xx and 429 responses (or network errors)
+// and the bounds of the exponential backoff between the attempts, zero disables the retries
+func WithRetries(maxRetries int, initialBackoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.initialBackoff = initialBackoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// WithPageSize sets how many items the iterators fetch at once, up to 100;
+// the default page size is kept for zero or negative values
+func WithPageSize(pageSize int) Option {
+	return func(c *Client) {
+		switch {
+		case pageSize < 1:
+			pageSize = defaultPageSize
+		case pageSize > maxPageSize:
+			pageSize = maxPageSize
+		}
+		c.pageSize = pageSize
+	}
+}
+
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		baseURL:        u,
+		httpClient:     &http.Client{Timeout: time.Second * 30},
+		header:         make(http.Header),
+		pageSize:       defaultPageSize,
+		maxRetries:     3,
+		initialBackoff: time.Millisecond * 200,
+		maxBackoff:     time.Second * 5,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c, nil
+}
+
+// do sends the request with JSON encoded body (if any) and decodes JSON response into out (if any),
+// non-2xx responses are returned as *Error
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	u := *c.baseURL
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	// the same key is sent on every retry, so that the server creates the resource only once
+	var idempotencyKey string
+	if method == http.MethodPost {
+		var err error
+		if idempotencyKey, err = newIdempotencyKey(); err != nil {
+			return err
+		}
+	}
+
+	backoff := c.initialBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, u.String(), payload, idempotencyKey)
+		if err == nil && resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+			defer resp.Body.Close()
+			if out == nil {
+				_, _ = io.Copy(ioutil.Discard, resp.Body)
+				return nil
+			}
+			return json.NewDecoder(resp.Body).Decode(out)
+		}
+
+		var retryAfter time.Duration
+		if err == nil {
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+			err = newError(resp)
+		}
+
+		if attempt >= c.maxRetries || !retryable(err) {
+			return err
+		}
+
+		wait := backoff
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+
+		backoff *= 2
+		if backoff > c.maxBackoff {
+			backoff = c.maxBackoff
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, u string, payload []byte, idempotencyKey string) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range c.header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	return c.httpClient.Do(req)
+}
+
+// retryable tells whether the request could be sent once again,
+// POST requests are safe to retry as well since they carry Idempotency-Key
+func retryable(err error) bool {
+	apiErr, ok := err.(*Error)
+	if !ok {
+		// cancelled context isn't worth retrying, other network errors are
+		return !isContextErr(err)
+	}
+
+	// the rest of 4xx responses won't change on their own, e.g. the song stays missing
+	if apiErr.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return apiErr.StatusCode >= 500
+}
+
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func isContextErr(err error) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+
+	return err == context.Canceled || err == context.DeadlineExceeded
+}
+
+// parseRetryAfter only supports the delay in seconds, not the HTTP date
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// drain reads the rest of the body, so that the connection could be reused
+func drain(body io.ReadCloser) []byte {
+	defer body.Close()
+	b, _ := ioutil.ReadAll(io.LimitReader(body, 1<<16))
+	return b
+}
@@ -0,0 +1,312 @@
+package lostifyclient
+
+import (
+	"context"
+	"errors"
+	"example/hello/project/internal/cache"
+	"example/hello/project/internal/httpserver"
+	inmemorybroker "example/hello/project/internal/message_broker/inmemory"
+	"example/hello/project/internal/store"
+	"example/hello/project/internal/store/inmemory"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testServer is the HTTP server on the in-memory store, it counts the requests and fails the first ones on demand
+type testServer struct {
+	*httptest.Server
+	db store.Store
+
+	mu       sync.Mutex
+	requests []*http.Request
+	failures []int
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	appCache := cache.NewTagged(cache.NewNoop())
+	ts := &testServer{db: inmemory.NewDB()}
+	handler := httpserver.NewServer(context.Background(),
+		httpserver.WithStore(ts.db),
+		httpserver.WithCache(appCache),
+		httpserver.WithBroker(inmemorybroker.NewBroker(appCache, "peer0", nil, zap.NewNop())),
+	).Handler()
+
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ts.mu.Lock()
+		ts.requests = append(ts.requests, r)
+		var status int
+		if len(ts.failures) > 0 {
+			status, ts.failures = ts.failures[0], ts.failures[1:]
+		}
+		ts.mu.Unlock()
+
+		if status != 0 {
+			rw.Header().Set("Retry-After", "0")
+			rw.WriteHeader(status)
+			return
+		}
+		handler.ServeHTTP(rw, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+// fail responds with the statuses to the next requests instead of the server
+func (ts *testServer) fail(statuses ...int) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.failures = append(ts.failures, statuses...)
+}
+
+func (ts *testServer) received() []*http.Request {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	requests := ts.requests
+	ts.requests = nil
+	return requests
+}
+
+func newTestClient(t *testing.T, ts *testServer, opts ...Option) *Client {
+	t.Helper()
+
+	opts = append([]Option{WithRetries(3, time.Millisecond, time.Millisecond)}, opts...)
+	client, err := New(ts.URL+"/", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestArtists(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestServer(t)
+	client := newTestClient(t, ts)
+
+	if err := client.CreateArtist(ctx, &Artist{ID: 1, FullName: "Queen"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.UpdateArtist(ctx, &Artist{ID: 1, FullName: "Queen + Adam Lambert"}); err != nil {
+		t.Fatal(err)
+	}
+	artist, err := client.Artist(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if artist.FullName != "Queen + Adam Lambert" {
+		t.Errorf("got artist %+v", artist)
+	}
+
+	if err := client.DeleteArtist(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Artist(ctx, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v for the deleted artist, want %v", err, ErrNotFound)
+	}
+}
+
+func TestSongsIterator(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestServer(t)
+	client := newTestClient(t, ts, WithPageSize(2))
+
+	if err := ts.db.Artists().Create(ctx, &Artist{ID: 1, FullName: "Queen"}); err != nil {
+		t.Fatal(err)
+	}
+	titles := []string{"Bohemian Rhapsody", "Love of My Life", "Somebody to Love", "Under Pressure", "Crazy Little Thing Called Love"}
+	for i, title := range titles {
+		if err := ts.db.Songs().Create(ctx, &Song{ID: i + 1, Title: title, ArtistID: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		it   *SongIterator
+		want []int
+	}{
+		{"all", client.Songs(ctx, nil), []int{1, 2, 3, 4, 5}},
+		{"search", client.Songs(ctx, &SongsQuery{Search: "LOVE"}), []int{2, 3, 5}},
+		{"of the artist", client.ArtistSongs(ctx, 1), []int{1, 2, 3, 4, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			for tt.it.Next() {
+				got = append(got, tt.it.Song().ID)
+			}
+			if err := tt.it.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got songs %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got songs %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestServer(t)
+	client := newTestClient(t, ts)
+
+	tests := []struct {
+		name   string
+		call   func() error
+		status int
+		want   error
+	}{
+		{"missing song", func() error { _, err := client.Song(ctx, 404); return err }, http.StatusNotFound, ErrNotFound},
+		{"missing artist", func() error { _, err := client.Artist(ctx, 404); return err }, http.StatusNotFound, ErrNotFound},
+		{"deleting missing song", func() error { return client.DeleteSong(ctx, 404) }, http.StatusNotFound, ErrNotFound},
+		{"updating missing artist", func() error {
+			return client.UpdateArtist(ctx, &Artist{ID: 404, FullName: "Nobody"})
+		}, http.StatusNotFound, ErrNotFound},
+		{"invalid artist", func() error { return client.CreateArtist(ctx, &Artist{ID: 2}) }, http.StatusUnprocessableEntity, ErrValidation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+
+			var apiErr *Error
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status || !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			// the client errors are never retried
+			if requests := ts.received(); len(requests) != 1 {
+				t.Errorf("got %v requests, want 1", len(requests))
+			}
+		})
+	}
+}
+
+func TestRetries(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestServer(t)
+	client := newTestClient(t, ts, WithToken("token"))
+
+	if err := ts.db.Artists().Create(ctx, &Artist{ID: 1, FullName: "Queen"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		failures []int
+		wantErr  error
+	}{
+		{"server error", []int{http.StatusInternalServerError, http.StatusBadGateway}, nil},
+		{"rate limited", []int{http.StatusTooManyRequests}, nil},
+		{"retries exhausted", []int{503, 503, 503, 503}, ErrServer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts.fail(tt.failures...)
+
+			_, err := client.Artist(ctx, 1)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+
+			requests := ts.received()
+			if want := len(tt.failures) + 1; tt.wantErr == nil && len(requests) != want {
+				t.Errorf("got %v requests, want %v", len(requests), want)
+			}
+			for _, r := range requests {
+				if got := r.Header.Get("Authorization"); got != "Bearer token" {
+					t.Errorf("got Authorization %q", got)
+				}
+			}
+		})
+	}
+}
+
+// the retries of POST carry the same Idempotency-Key, so that the resource is created once
+func TestRetriedCreateIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestServer(t)
+	client := newTestClient(t, ts)
+
+	ts.fail(http.StatusServiceUnavailable)
+	if err := client.CreateArtist(ctx, &Artist{ID: 1, FullName: "Queen"}); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := ts.received()
+	if len(requests) != 2 {
+		t.Fatalf("got %v requests, want 2", len(requests))
+	}
+	if key := requests[0].Header.Get("Idempotency-Key"); key == "" || key != requests[1].Header.Get("Idempotency-Key") {
+		t.Errorf("got keys %q and %q", key, requests[1].Header.Get("Idempotency-Key"))
+	}
+}
+
+func TestCancelledContext(t *testing.T) {
+	ts := newTestServer(t)
+	client := newTestClient(t, ts, WithRetries(3, time.Hour, time.Hour))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ts.fail(http.StatusServiceUnavailable)
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	if _, err := client.Song(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+	if requests := ts.received(); len(requests) != 1 {
+		t.Errorf("got %v requests, want 1", len(requests))
+	}
+}
+
+func TestNew(t *testing.T) {
+	client, err := New("http://localhost:8080/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.pageSize != defaultPageSize {
+		t.Errorf("got page size %v, want %v", client.pageSize, defaultPageSize)
+	}
+	if got := client.baseURL.String(); got != "http://localhost:8080" {
+		t.Errorf("got base URL %v", got)
+	}
+
+	if _, err := New("http://[::1"); err == nil {
+		t.Error("want error for the invalid URL")
+	}
+}
+
+func TestWithPageSize(t *testing.T) {
+	tests := []struct {
+		pageSize int
+		want     int
+	}{
+		{1, 1},
+		{50, 50},
+		{maxPageSize, maxPageSize},
+		{1000, maxPageSize},
+		{0, defaultPageSize},
+		{-1, defaultPageSize},
+	}
+
+	for _, tt := range tests {
+		client, err := New("http://localhost:8080", WithPageSize(tt.pageSize))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if client.pageSize != tt.want {
+			t.Errorf("%v: got page size %v, want %v", tt.pageSize, client.pageSize, tt.want)
+		}
+	}
+}
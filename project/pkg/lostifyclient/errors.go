@@ -0,0 +1,76 @@
+package lostifyclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// sentinel errors to check *Error against with errors.Is, e.g. errors.Is(err, lostifyclient.ErrNotFound)
+var (
+	ErrBadRequest  = errors.New("bad request")
+	ErrNotFound    = errors.New("not found")
+	ErrConflict    = errors.New("conflict")
+	ErrValidation  = errors.New("validation failed")
+	ErrRateLimited = errors.New("rate limited")
+	ErrServer      = errors.New("server error")
+)
+
+// Error is a non-2xx response of the API. Problem details (RFC 7807) are decoded if the server sends them,
+// otherwise the body of the response is kept as Detail.
+type Error struct {
+	StatusCode int    `json:"-"`
+	Type       string `json:"type,omitempty"`
+	Title      string `json:"title,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+	Instance   string `json:"instance,omitempty"`
+}
+
+func (e *Error) Error() string {
+	title := e.Title
+	if title == "" {
+		title = http.StatusText(e.StatusCode)
+	}
+	if e.Detail == "" {
+		return fmt.Sprintf("lostify: %v %v", e.StatusCode, title)
+	}
+
+	return fmt.Sprintf("lostify: %v %v: %v", e.StatusCode, title, e.Detail)
+}
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrValidation:
+		return e.StatusCode == http.StatusUnprocessableEntity
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return e.StatusCode >= 500
+	}
+
+	return false
+}
+
+func newError(resp *http.Response) *Error {
+	body := drain(resp.Body)
+	apiErr := &Error{StatusCode: resp.StatusCode}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "application/problem+json" || mediaType == "application/json" {
+		if err := json.Unmarshal(body, apiErr); err == nil {
+			return apiErr
+		}
+	}
+
+	apiErr.Detail = strings.TrimSpace(string(body))
+	return apiErr
+}
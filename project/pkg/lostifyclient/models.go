@@ -0,0 +1,16 @@
+package lostifyclient
+
+import "example/hello/project/internal/models"
+
+// the SDK speaks the v1 representation of the API, the callers outside of the module
+// can't import the internal models, so they're re-exported here
+type (
+	Song   = models.Song
+	Artist = models.Artist
+)
+
+// statuses of the lyrics saga, the song has them in place of the lyrics until they're fetched
+const (
+	LyricsStatusFetching = models.LyricsStatusFetching
+	LyricsStatusFailed   = models.LyricsStatusFailed
+)
@@ -0,0 +1,57 @@
+package lostifyclient
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// pager keeps track of the offset while iterating over the pages of a list endpoint
+type pager struct {
+	ctx      context.Context
+	query    url.Values
+	pageSize int
+
+	offset int
+	done   bool
+	err    error
+}
+
+func newPager(ctx context.Context, query url.Values, pageSize int) pager {
+	if query == nil {
+		query = url.Values{}
+	}
+
+	return pager{
+		ctx:      ctx,
+		query:    query,
+		pageSize: pageSize,
+	}
+}
+
+// fetch loads the next page, returns false once there are no more items or an error has occurred
+func (p *pager) fetch(load func(ctx context.Context, query url.Values) (int, error)) bool {
+	if p.done || p.err != nil {
+		return false
+	}
+
+	p.query.Set("limit", strconv.Itoa(p.pageSize))
+	p.query.Set("offset", strconv.Itoa(p.offset))
+
+	n, err := load(p.ctx, p.query)
+	if err != nil {
+		p.err = err
+		return false
+	}
+
+	p.offset += n
+	// a page that isn't full is the last one
+	p.done = n < p.pageSize
+
+	return n > 0
+}
+
+// Err returns the error that has stopped the iteration, if any
+func (p *pager) Err() error {
+	return p.err
+}
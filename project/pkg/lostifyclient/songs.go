@@ -0,0 +1,94 @@
+package lostifyclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+type SongsQuery struct {
+	// songs with title containing Search (case insensitive)
+	Search string
+}
+
+// CreateSong creates the song, its lyrics are fetched by the server in the background
+func (c *Client) CreateSong(ctx context.Context, song *Song) error {
+	return c.do(ctx, http.MethodPost, "/v1/songs", nil, song, nil)
+}
+
+func (c *Client) Song(ctx context.Context, id int) (*Song, error) {
+	song := new(Song)
+	if err := c.do(ctx, http.MethodGet, "/v1/songs/"+strconv.Itoa(id), nil, nil, song); err != nil {
+		return nil, err
+	}
+
+	return song, nil
+}
+
+// Songs returns an iterator over all the songs matching the query, the pages are fetched lazily
+func (c *Client) Songs(ctx context.Context, q *SongsQuery) *SongIterator {
+	query := url.Values{}
+	if q != nil && q.Search != "" {
+		query.Set("searchQuery", q.Search)
+	}
+
+	return &SongIterator{
+		pager:  newPager(ctx, query, c.pageSize),
+		client: c,
+		path:   "/v1/songs",
+		i:      -1,
+	}
+}
+
+// ArtistSongs returns an iterator over the discography of the artist
+func (c *Client) ArtistSongs(ctx context.Context, artistID int) *SongIterator {
+	return &SongIterator{
+		pager:  newPager(ctx, nil, c.pageSize),
+		client: c,
+		path:   "/v1/artists/" + strconv.Itoa(artistID) + "/songs",
+		i:      -1,
+	}
+}
+
+// CreateArtistSong creates the song of the artist, ArtistID of the song is ignored
+func (c *Client) CreateArtistSong(ctx context.Context, artistID int, song *Song) error {
+	return c.do(ctx, http.MethodPost, "/v1/artists/"+strconv.Itoa(artistID)+"/songs", nil, song, nil)
+}
+
+func (c *Client) UpdateSong(ctx context.Context, song *Song) error {
+	return c.do(ctx, http.MethodPut, "/v1/songs", nil, song, nil)
+}
+
+func (c *Client) DeleteSong(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, "/v1/songs/"+strconv.Itoa(id), nil, nil, nil)
+}
+
+type SongIterator struct {
+	pager
+	client *Client
+	path   string
+
+	songs []*Song
+	i     int
+}
+
+// Next advances to the next song, fetching the next page if needed.
+// It returns false once all the songs have been iterated over or an error has occurred (see Err).
+func (it *SongIterator) Next() bool {
+	if it.i+1 < len(it.songs) {
+		it.i++
+		return true
+	}
+
+	it.songs, it.i = nil, 0
+	return it.fetch(func(ctx context.Context, query url.Values) (int, error) {
+		err := it.client.do(ctx, http.MethodGet, it.path, query, nil, &it.songs)
+		return len(it.songs), err
+	})
+}
+
+// Song returns the current song
+func (it *SongIterator) Song() *Song {
+	return it.songs[it.i]
+}